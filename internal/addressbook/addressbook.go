@@ -0,0 +1,359 @@
+// Package addressbook keeps a local address book in the config directory,
+// imports and exports it as vCard files and finds the contact behind a phone
+// number however the number is written.
+package addressbook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"mifi_app/internal/api"
+	"mifi_app/internal/config"
+)
+
+// FileName is the address book file in the config directory
+const FileName = "address_book.json"
+
+// Phone types kept from vCard TEL properties
+const (
+	PhoneCell = "cell"
+	PhoneHome = "home"
+	PhoneWork = "work"
+)
+
+// Phone is one number of a contact
+type Phone struct {
+	Number string `json:"number"`
+	// Type is PhoneCell, PhoneHome, PhoneWork or "" for other numbers
+	Type string `json:"type,omitempty"`
+}
+
+// Contact is an address book entry
+type Contact struct {
+	// ID is the vCard UID, kept so re-importing a file updates its contacts
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Phones       []Phone  `json:"phones"`
+	Emails       []string `json:"emails,omitempty"`
+	Organization string   `json:"organization,omitempty"`
+	Note         string   `json:"note,omitempty"`
+}
+
+// Match is a contact number found by Search
+type Match struct {
+	Name   string
+	Number string
+}
+
+// ImportResult counts what an import did
+type ImportResult struct {
+	Added   int
+	Updated int
+	// Skipped counts vCards without a name or a phone number
+	Skipped int
+}
+
+// Book is the address book. It is safe for concurrent use.
+type Book struct {
+	path string
+
+	mu       sync.Mutex
+	contacts []Contact
+	// byNumber maps api.NumberKey of every number to its contact index
+	byNumber map[string]int
+}
+
+// DefaultPath returns the address book location in the config directory
+func DefaultPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// New returns an empty address book that is not saved to disk
+func New() *Book {
+	return &Book{byNumber: make(map[string]int)}
+}
+
+// Open loads the address book at path. A missing file is an empty book.
+func Open(path string) (*Book, error) {
+	b := New()
+	b.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read address book: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &b.contacts); err != nil {
+		return nil, fmt.Errorf("failed to parse address book %s: %w", path, err)
+	}
+	b.index()
+	return b, nil
+}
+
+// Contacts returns a copy of every contact, ordered by name
+func (b *Book) Contacts() []Contact {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	contacts := make([]Contact, len(b.contacts))
+	copy(contacts, b.contacts)
+	return contacts
+}
+
+// Lookup returns the contact with number, matched with api.NumberKey so
+// that national and international forms of a number agree
+func (b *Book) Lookup(number string) (Contact, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i, ok := b.byNumber[api.NumberKey(number)]
+	if !ok {
+		return Contact{}, false
+	}
+	return b.contacts[i], true
+}
+
+// Search returns up to limit numbers whose contact name contains query, or
+// whose digits contain the digits of query
+func (b *Book) Search(query string, limit int) []Match {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	digits := strings.TrimPrefix(api.NormalizeNumber(query), "+")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var matches []Match
+	for _, c := range b.contacts {
+		nameMatches := strings.Contains(strings.ToLower(c.Name), query)
+		for _, phone := range c.Phones {
+			numberMatches := digits != "" && strings.Contains(api.NormalizeNumber(phone.Number), digits)
+			if !nameMatches && !numberMatches {
+				continue
+			}
+			matches = append(matches, Match{Name: c.Name, Number: phone.Number})
+			if len(matches) == limit {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+// Save adds c, or replaces the contact with the same ID. New contacts get
+// an ID. A contact needs a name and at least one number.
+func (b *Book) Save(c Contact) (Contact, error) {
+	c = clean(c)
+	if c.Name == "" {
+		return c, errors.New("a contact needs a name")
+	}
+	if len(c.Phones) == 0 {
+		return c, errors.New("a contact needs a phone number")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c.ID == "" {
+		c.ID = newID()
+	}
+	if i := b.find(c.ID); i >= 0 {
+		b.contacts[i] = c
+	} else {
+		b.contacts = append(b.contacts, c)
+	}
+	return c, b.save()
+}
+
+// Remove deletes the contact with id
+func (b *Book) Remove(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := b.find(id)
+	if i < 0 {
+		return fmt.Errorf("no contact with ID %s", id)
+	}
+	b.contacts = append(b.contacts[:i], b.contacts[i+1:]...)
+	return b.save()
+}
+
+// Import adds the contacts of a .vcf file. A vCard updates the contact with
+// the same UID, or failing that one with the same name and a shared number,
+// so importing a file twice does not duplicate it.
+func (b *Book) Import(r io.Reader) (ImportResult, error) {
+	cards, err := Parse(r)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var result ImportResult
+	for _, c := range cards {
+		c = clean(c)
+		if c.Name == "" || len(c.Phones) == 0 {
+			result.Skipped++
+			continue
+		}
+
+		i := -1
+		if c.ID != "" {
+			i = b.find(c.ID)
+		}
+		if i < 0 {
+			i = b.findDuplicate(c)
+		}
+
+		if i >= 0 {
+			if c.ID == "" {
+				c.ID = b.contacts[i].ID
+			}
+			b.contacts[i] = c
+			result.Updated++
+		} else {
+			if c.ID == "" {
+				c.ID = newID()
+			}
+			b.contacts = append(b.contacts, c)
+			result.Added++
+		}
+		// Later cards in the file may duplicate this one
+		b.index()
+	}
+
+	return result, b.save()
+}
+
+// Export writes every contact to w as vCards of the given version
+func (b *Book) Export(w io.Writer, version Version) error {
+	return Write(w, b.Contacts(), version)
+}
+
+// Reindex matches the numbers again, as needed after the default country
+// code of api.NumberKey changed
+func (b *Book) Reindex() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.index()
+}
+
+// find returns the index of the contact with id, or -1
+func (b *Book) find(id string) int {
+	for i := range b.contacts {
+		if b.contacts[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// findDuplicate returns the index of a contact with the name of c and one
+// of its numbers, or -1
+func (b *Book) findDuplicate(c Contact) int {
+	for _, phone := range c.Phones {
+		i, ok := b.byNumber[api.NumberKey(phone.Number)]
+		if ok && strings.EqualFold(b.contacts[i].Name, c.Name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// index sorts the contacts by name and rebuilds the number index. When
+// contacts share a number the first one by name wins.
+func (b *Book) index() {
+	sort.SliceStable(b.contacts, func(i, j int) bool {
+		return strings.ToLower(b.contacts[i].Name) < strings.ToLower(b.contacts[j].Name)
+	})
+
+	b.byNumber = make(map[string]int)
+	for i, c := range b.contacts {
+		for _, phone := range c.Phones {
+			key := api.NumberKey(phone.Number)
+			if _, taken := b.byNumber[key]; !taken {
+				b.byNumber[key] = i
+			}
+		}
+	}
+}
+
+func (b *Book) save() error {
+	b.index()
+
+	if b.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(b.contacts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode address book: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return fmt.Errorf("failed to create address book directory: %w", err)
+	}
+
+	// Write a temporary file first so a crash cannot truncate the book
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write address book: %w", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return fmt.Errorf("failed to write address book: %w", err)
+	}
+	return nil
+}
+
+// clean trims the fields of c and drops empty numbers and emails
+func clean(c Contact) Contact {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Organization = strings.TrimSpace(c.Organization)
+
+	phones := make([]Phone, 0, len(c.Phones))
+	for _, phone := range c.Phones {
+		if phone.Number = strings.TrimSpace(phone.Number); phone.Number != "" {
+			phones = append(phones, phone)
+		}
+	}
+	c.Phones = phones
+
+	var emails []string
+	for _, email := range c.Emails {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	c.Emails = emails
+	return c
+}
+
+// newID returns a random UUID for a contact without a vCard UID
+func newID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+
+	s := hex.EncodeToString(u[:])
+	return "urn:uuid:" + s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
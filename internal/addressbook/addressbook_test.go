@@ -0,0 +1,93 @@
+package addressbook
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mifi_app/internal/api"
+)
+
+func TestLookup(t *testing.T) {
+	defer api.SetDefaultCountryCode("")
+
+	b := New()
+	for _, c := range []Contact{
+		{Name: "Chikondi", Phones: []Phone{{Number: "0999 123 456"}}},
+		{Name: "Mercy", Phones: []Phone{{Number: "+265 888 000 222"}}},
+		{Name: "Airtel", Phones: []Phone{{Number: "*100#"}}},
+	} {
+		if _, err := b.Save(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		countryCode string
+		number      string
+		want        string
+	}{
+		{"265", "+265999123456", "Chikondi"},
+		{"265", "00265 999 123 456", "Chikondi"},
+		{"265", "0999123456", "Chikondi"},
+		{"265", "0888000222", "Mercy"},
+		{"265", "265888000222", "Mercy"},
+		{"265", "+27999123456", ""},
+		{"265", "*100#", "Airtel"},
+		{"", "+265999123456", "Chikondi"},
+		{"", "0888000222", "Mercy"},
+		{"", "888-000-222", "Mercy"},
+		{"", "0999000111", ""},
+		{"", "*100#", "Airtel"},
+	}
+
+	for _, tt := range tests {
+		if err := api.SetDefaultCountryCode(tt.countryCode); err != nil {
+			t.Fatal(err)
+		}
+		b.Reindex()
+
+		c, ok := b.Lookup(tt.number)
+		if c.Name != tt.want || ok != (tt.want != "") {
+			t.Errorf("country code %q: Lookup(%q) = %q, %v, want %q", tt.countryCode, tt.number, c.Name, ok, tt.want)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	const vcf = "BEGIN:VCARD\nVERSION:3.0\nFN:Mercy\nTEL:+265888000222\nEND:VCARD\n" +
+		"BEGIN:VCARD\nVERSION:3.0\nFN:Chikondi\nTEL:0999123456\nUID:c-1\nEND:VCARD\n" +
+		"BEGIN:VCARD\nVERSION:3.0\nFN:No Number\nEND:VCARD\n"
+
+	path := filepath.Join(t.TempDir(), FileName)
+	b, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := b.Import(strings.NewReader(vcf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (ImportResult{Added: 2, Skipped: 1}) {
+		t.Errorf("first import = %+v", result)
+	}
+
+	// The same file again updates rather than duplicates
+	result, err = b.Import(strings.NewReader(vcf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (ImportResult{Updated: 2, Skipped: 1}) {
+		t.Errorf("second import = %+v", result)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contacts := reopened.Contacts()
+	if len(contacts) != 2 || contacts[0].Name != "Chikondi" || contacts[0].ID != "c-1" || contacts[1].ID == "" {
+		t.Errorf("saved contacts = %+v", contacts)
+	}
+}
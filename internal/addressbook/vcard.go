@@ -0,0 +1,351 @@
+package addressbook
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"unicode/utf8"
+)
+
+// Version is a vCard format version written by Write
+type Version string
+
+const (
+	Version3 Version = "3.0"
+	Version4 Version = "4.0"
+)
+
+// Versions lists the versions Write supports
+var Versions = []Version{Version3, Version4}
+
+// ParseVersion returns the version named s
+func ParseVersion(s string) (Version, error) {
+	for _, v := range Versions {
+		if s == string(v) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("unknown vCard version %q, use 3.0 or 4.0", s)
+}
+
+// maxLineOctets is where Write folds long lines, as RFC 6350 recommends
+const maxLineOctets = 75
+
+// property is one unfolded content line of a vCard
+type property struct {
+	name   string
+	params map[string][]string
+	value  string
+}
+
+// has reports whether the parameter name lists value, ignoring case. vCard
+// 2.1 writes types as bare parameters such as TEL;CELL.
+func (p *property) has(name, value string) bool {
+	for _, v := range p.params[name] {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse reads the contacts of a .vcf file. vCard 2.1, 3.0 and 4.0 are
+// understood; photos and unknown properties are ignored.
+func Parse(r io.Reader) ([]Contact, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var contacts []Contact
+	var card *Contact
+	var structuredName string
+	found := false
+
+	for _, line := range lines {
+		p, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCARD"):
+			card = &Contact{}
+			structuredName = ""
+			found = true
+		case p.name == "END" && strings.EqualFold(p.value, "VCARD"):
+			if card != nil {
+				if card.Name == "" {
+					card.Name = structuredName
+				}
+				contacts = append(contacts, *card)
+			}
+			card = nil
+		case card == nil:
+		case p.name == "FN":
+			card.Name = strings.TrimSpace(unescapeText(p.value))
+		case p.name == "N":
+			structuredName = formatName(splitValue(p.value))
+		case p.name == "TEL":
+			if number := telNumber(p.value); number != "" {
+				card.Phones = append(card.Phones, Phone{Number: number, Type: phoneType(&p)})
+			}
+		case p.name == "EMAIL":
+			if email := strings.TrimSpace(p.value); email != "" {
+				card.Emails = append(card.Emails, email)
+			}
+		case p.name == "ORG":
+			card.Organization = strings.TrimSpace(splitValue(p.value)[0])
+		case p.name == "NOTE":
+			card.Note = unescapeText(p.value)
+		case p.name == "UID":
+			card.ID = strings.TrimSpace(p.value)
+		}
+	}
+
+	if !found {
+		return nil, errors.New("no vCards found in the file")
+	}
+	return contacts, nil
+}
+
+// unfold reads the content lines of r, joining folded lines and the soft
+// line breaks of quoted-printable values
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var lines []string
+	qpSoftBreak := false
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		last := len(lines) - 1
+		switch {
+		case qpSoftBreak:
+			lines[last] = strings.TrimSuffix(lines[last], "=") + line
+		case last >= 0 && line != "" && (line[0] == ' ' || line[0] == '\t'):
+			lines[last] += line[1:]
+		default:
+			lines = append(lines, line)
+		}
+
+		current := lines[len(lines)-1]
+		qpSoftBreak = strings.HasSuffix(current, "=") &&
+			strings.Contains(strings.ToUpper(current[:max(strings.Index(current, ":"), 0)]), "QUOTED-PRINTABLE")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vCard file: %w", err)
+	}
+	return lines, nil
+}
+
+// parseLine splits a content line into its name, parameters and value. The
+// value of a quoted-printable property is decoded.
+func parseLine(line string) (property, bool) {
+	colon := -1
+	quoted := false
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, false
+	}
+
+	p := property{params: make(map[string][]string), value: line[colon+1:]}
+	parts := strings.Split(line[:colon], ";")
+
+	// Drop the group of grouped properties such as item1.TEL
+	p.name = strings.ToUpper(parts[0])
+	if dot := strings.LastIndex(p.name, "."); dot >= 0 {
+		p.name = p.name[dot+1:]
+	}
+
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			key, value = "TYPE", param
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		for _, v := range strings.Split(strings.Trim(value, `"`), ",") {
+			p.params[key] = append(p.params[key], strings.TrimSpace(v))
+		}
+	}
+
+	if p.has("ENCODING", "QUOTED-PRINTABLE") || p.has("TYPE", "QUOTED-PRINTABLE") {
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(p.value)))
+		if err == nil {
+			p.value = string(decoded)
+		}
+	}
+	return p, true
+}
+
+// telNumber reads a TEL value, which vCard 4.0 may write as a tel: URI
+func telNumber(value string) string {
+	value = strings.TrimSpace(value)
+	if rest, ok := strings.CutPrefix(strings.ToLower(value), "tel:"); ok {
+		value = value[len(value)-len(rest):]
+		value, _, _ = strings.Cut(value, ";")
+	}
+	return value
+}
+
+// phoneType picks the kind of a TEL property, or "" for other numbers
+func phoneType(p *property) string {
+	for _, t := range []string{PhoneCell, PhoneHome, PhoneWork} {
+		if p.has("TYPE", t) {
+			return t
+		}
+	}
+	return ""
+}
+
+// formatName joins the components of an N value in reading order
+func formatName(n []string) string {
+	for len(n) < 5 {
+		n = append(n, "")
+	}
+	// N is family;given;additional;prefix;suffix
+	var parts []string
+	for _, part := range []string{n[3], n[1], n[2], n[0], n[4]} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitValue splits a structured value on unescaped semicolons and
+// unescapes the components
+func splitValue(value string) []string {
+	var parts []string
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			parts = append(parts, unescapeText(b.String()))
+			b.Reset()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return append(parts, unescapeText(b.String()))
+}
+
+// unescapeText undoes the backslash escapes of a text value
+func unescapeText(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if escaped {
+			if r == 'n' || r == 'N' {
+				r = '\n'
+			}
+			b.WriteRune(r)
+			escaped = false
+			continue
+		}
+		if r == '\\' {
+			escaped = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// escapeText escapes a text value for writing
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\r\n", `\n`, "\n", `\n`, ",", `\,`, ";", `\;`).Replace(s)
+}
+
+// Write writes contacts to w as vCards of the given version
+func Write(w io.Writer, contacts []Contact, version Version) error {
+	if _, err := ParseVersion(string(version)); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, c := range contacts {
+		writeLine(bw, "BEGIN:VCARD")
+		writeLine(bw, "VERSION:"+string(version))
+		writeLine(bw, "FN:"+escapeText(c.Name))
+		if version == Version3 {
+			// N is required in 3.0; the full name is kept as the given name
+			writeLine(bw, "N:;"+escapeText(c.Name)+";;;")
+		}
+
+		for _, phone := range c.Phones {
+			name := "TEL"
+			if phone.Type != "" {
+				name += ";TYPE=" + typeParam(phone.Type, version)
+			}
+			writeLine(bw, name+":"+phone.Number)
+		}
+		for _, email := range c.Emails {
+			if version == Version3 {
+				writeLine(bw, "EMAIL;TYPE=INTERNET:"+email)
+			} else {
+				writeLine(bw, "EMAIL:"+email)
+			}
+		}
+
+		if c.Organization != "" {
+			writeLine(bw, "ORG:"+escapeText(c.Organization))
+		}
+		if c.Note != "" {
+			writeLine(bw, "NOTE:"+escapeText(c.Note))
+		}
+		if c.ID != "" {
+			writeLine(bw, "UID:"+c.ID)
+		}
+		writeLine(bw, "END:VCARD")
+	}
+	return bw.Flush()
+}
+
+// typeParam spells a phone type as the version expects
+func typeParam(t string, version Version) string {
+	if version == Version3 {
+		return strings.ToUpper(t)
+	}
+	return t
+}
+
+// writeLine writes a content line, folded at maxLineOctets without
+// splitting a UTF-8 sequence. Errors are reported by the final Flush.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts towards the limit
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
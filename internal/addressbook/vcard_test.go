@@ -0,0 +1,113 @@
+package addressbook
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		vcf  string
+		want []Contact
+	}{
+		{"vCard 2.1", "BEGIN:VCARD\r\nVERSION:2.1\r\nN:Banda;Chikondi;;;\r\nTEL;CELL;PREF:+265 999 123 456\r\nTEL;HOME:01 234 567\r\nEND:VCARD\r\n",
+			[]Contact{{Name: "Chikondi Banda", Phones: []Phone{{"+265 999 123 456", PhoneCell}, {"01 234 567", PhoneHome}}}}},
+		{"vCard 3.0", "BEGIN:VCARD\nVERSION:3.0\nFN:Mercy Phiri\nN:Phiri;Mercy;;;\nitem1.TEL;TYPE=WORK,VOICE:0888000222\nEMAIL;TYPE=INTERNET:mercy@example.com\nORG:Airtel;Sales\nNOTE:Line one\\nLine two\\, more\nUID:abc-1\nEND:VCARD\n",
+			[]Contact{{ID: "abc-1", Name: "Mercy Phiri", Phones: []Phone{{"0888000222", PhoneWork}}, Emails: []string{"mercy@example.com"}, Organization: "Airtel", Note: "Line one\nLine two, more"}}},
+		{"vCard 4.0", "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Dr. Tamanda\r\nTEL;VALUE=uri;TYPE=\"cell,voice\":tel:+265-999-000-111;ext=2\r\nEND:VCARD\r\n",
+			[]Contact{{Name: "Dr. Tamanda", Phones: []Phone{{"+265-999-000-111", PhoneCell}}}}},
+		{"quoted-printable soft breaks", "BEGIN:VCARD\r\nVERSION:2.1\r\nFN;CHARSET=UTF-8;ENCODING=QUOTED-PRINTABLE:Ch=C3=A9ri=\r\ne Mw=\r\nale\r\nTEL:0999123456\r\nEND:VCARD\r\n",
+			[]Contact{{Name: "Chérie Mwale", Phones: []Phone{{"0999123456", ""}}}}},
+		{"folded lines", "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Kondwani\r\n  Nyirenda\r\nTEL:0999\r\n\t123456\r\nEND:VCARD\r\n",
+			[]Contact{{Name: "Kondwani Nyirenda", Phones: []Phone{{"0999123456", ""}}}}},
+		{"several cards", "\ufeffBEGIN:VCARD\nFN:A\nTEL:1\nEND:VCARD\nBEGIN:VCARD\nFN:B\nEND:VCARD\n",
+			[]Contact{{Name: "A", Phones: []Phone{{"1", ""}}}, {Name: "B"}}},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(strings.NewReader(tt.vcf))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Parse = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader("name,number\nA,1\n")); err == nil {
+		t.Error("a file without vCards parsed without an error")
+	}
+}
+
+func TestWriteLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "FN:Chikondi"},
+		{"exactly the limit", "NOTE:" + strings.Repeat("a", maxLineOctets-5)},
+		{"long ASCII", "NOTE:" + strings.Repeat("abcdefghij", 20)},
+		{"multi-byte runes", "NOTE:" + strings.Repeat("Zikomo 👍 ", 20)},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		writeLine(w, tt.line)
+		w.Flush()
+
+		out := buf.String()
+		if !strings.HasSuffix(out, "\r\n") {
+			t.Errorf("%s: line not terminated: %q", tt.name, out)
+			continue
+		}
+		physical := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+		for i, l := range physical {
+			if len(l) > maxLineOctets {
+				t.Errorf("%s: line %d has %d octets", tt.name, i, len(l))
+			}
+			if !utf8.ValidString(l) {
+				t.Errorf("%s: line %d splits a rune: %q", tt.name, i, l)
+			}
+			if i > 0 && !strings.HasPrefix(l, " ") {
+				t.Errorf("%s: continuation line %d does not start with a space", tt.name, i)
+			}
+		}
+
+		lines, err := unfold(strings.NewReader(out))
+		if err != nil || len(lines) != 1 || lines[0] != tt.line {
+			t.Errorf("%s: unfolds to %q, %v", tt.name, lines, err)
+		}
+	}
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	contacts := []Contact{{
+		ID:           "abc-1",
+		Name:         "Mercy Phiri; Sales, Lilongwe",
+		Phones:       []Phone{{"+265888000222", PhoneWork}, {"0999123456", ""}},
+		Emails:       []string{"mercy@example.com"},
+		Organization: "Airtel",
+		Note:         strings.Repeat("A long note with ünïcödé. ", 8),
+	}}
+
+	for _, version := range Versions {
+		var buf bytes.Buffer
+		if err := Write(&buf, contacts, version); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Parse(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, contacts) {
+			t.Errorf("%s: read back %+v, want %+v", version, got, contacts)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// atPrompt is the line reported when the modem asks for SMS text
+const atPrompt = ">"
+
+// atCtrlZ ends the text of an SMS
+const atCtrlZ = "\x1a"
+
+// ATClient talks to USB LTE modems over the AT command port of a serial
+// device such as /dev/ttyUSB2
+type ATClient struct {
+	Port   io.ReadWriter
+	Logger *logrus.Logger
+
+	timeout atomic.Int64
+
+	// mu serializes commands on the port and guards the fields below
+	mu       sync.Mutex
+	ready    bool
+	identity *DeviceStatus
+
+	startOnce sync.Once
+	lines     chan string
+	readErr   error
+}
+
+var _ DeviceAPI = (*ATClient)(nil)
+
+// NewATClient wraps an already configured port
+func NewATClient(port io.ReadWriter, logger *logrus.Logger) *ATClient {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	c := &ATClient{
+		Port:   port,
+		Logger: logger,
+		lines:  make(chan string, 64),
+	}
+	c.SetTimeout(DefaultTimeout)
+
+	return c
+}
+
+// OpenATClient opens the serial device at path in raw mode and wraps it
+func OpenATClient(path string, logger *logrus.Logger) (*ATClient, error) {
+	port, err := openSerial(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return NewATClient(port, logger), nil
+}
+
+// Close closes the port if it can be closed
+func (c *ATClient) Close() error {
+	if closer, ok := c.Port.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// SetTimeout changes the per-command timeout. Values <= 0 restore DefaultTimeout.
+func (c *ATClient) SetTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultTimeout
+	}
+	c.timeout.Store(int64(d))
+}
+
+// Timeout returns the current per-command timeout
+func (c *ATClient) Timeout() time.Duration {
+	return time.Duration(c.timeout.Load())
+}
+
+// readLoop splits the port output into lines. A CRLF pair ends one line,
+// and blank lines are reported too since message text can contain them.
+// The SMS prompt is not followed by a newline, so it is reported as soon as
+// it arrives.
+func (c *ATClient) readLoop() {
+	r := bufio.NewReader(c.Port)
+	var line strings.Builder
+	cr := false
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			c.readErr = err
+			close(c.lines)
+			return
+		}
+
+		switch {
+		case b == '\n' && cr:
+			// The LF of a CRLF pair
+		case b == '\n' || b == '\r':
+			c.lines <- strings.TrimSpace(line.String())
+			line.Reset()
+		case b == ' ' && strings.TrimSpace(line.String()) == atPrompt:
+			c.lines <- atPrompt
+			line.Reset()
+		default:
+			line.WriteByte(b)
+		}
+		cr = b == '\r'
+	}
+}
+
+// command sends cmd and returns the response lines before the final
+// result, leaving out blank ones. The caller must hold mu.
+func (c *ATClient) command(ctx context.Context, cmd string) ([]string, error) {
+	lines, err := c.commandText(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonBlank []string
+	for _, line := range lines {
+		if line != "" {
+			nonBlank = append(nonBlank, line)
+		}
+	}
+	return nonBlank, nil
+}
+
+// commandText is like command but keeps blank lines, which can be part of
+// message text. The caller must hold mu.
+func (c *ATClient) commandText(ctx context.Context, cmd string) ([]string, error) {
+	if err := c.write(cmd + "\r"); err != nil {
+		return nil, err
+	}
+	return c.response(ctx, cmd)
+}
+
+// write sends raw data to the modem, discarding any unsolicited lines that
+// arrived since the last command
+func (c *ATClient) write(data string) error {
+	c.startOnce.Do(func() { go c.readLoop() })
+
+	for drained := false; !drained; {
+		select {
+		case line, ok := <-c.lines:
+			if !ok {
+				return fmt.Errorf("modem port closed: %w", c.readErr)
+			}
+			if line != "" {
+				c.Logger.Debugf("AT unsolicited: %s", line)
+			}
+		default:
+			drained = true
+		}
+	}
+
+	if _, err := io.WriteString(c.Port, data); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+// response collects lines until OK, ERROR or a +CME/+CMS error. The echo of
+// cmd is skipped in case the modem has echo on.
+func (c *ATClient) response(ctx context.Context, cmd string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout())
+	defer cancel()
+
+	var lines []string
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s: %w", cmd, ctx.Err())
+		case line, ok := <-c.lines:
+			if !ok {
+				return nil, fmt.Errorf("modem port closed: %w", c.readErr)
+			}
+
+			switch {
+			case line == cmd:
+				continue
+			case line == "OK":
+				c.Logger.Debugf("AT %s -> %q", cmd, lines)
+				return lines, nil
+			case line == "ERROR":
+				return nil, &DeviceError{Goform: cmd, Result: "ERROR"}
+			case strings.HasPrefix(line, "+CME ERROR:"), strings.HasPrefix(line, "+CMS ERROR:"):
+				_, code, _ := strings.Cut(line, ":")
+				return nil, &DeviceError{Goform: cmd, Result: strings.TrimSpace(code)}
+			}
+
+			lines = append(lines, line)
+		}
+	}
+}
+
+// prompt waits for the modem to ask for SMS text
+func (c *ATClient) prompt(ctx context.Context, cmd string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout())
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", cmd, ctx.Err())
+		case line, ok := <-c.lines:
+			if !ok {
+				return fmt.Errorf("modem port closed: %w", c.readErr)
+			}
+
+			switch {
+			case line == atPrompt:
+				return nil
+			case line == "ERROR":
+				return &DeviceError{Goform: cmd, Result: "ERROR"}
+			case strings.HasPrefix(line, "+CME ERROR:"), strings.HasPrefix(line, "+CMS ERROR:"):
+				_, code, _ := strings.Cut(line, ":")
+				return &DeviceError{Goform: cmd, Result: strings.TrimSpace(code)}
+			}
+		}
+	}
+}
+
+// exec runs cmd after putting the modem in the mode the client expects
+func (c *ATClient) exec(ctx context.Context, cmd string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setup(ctx); err != nil {
+		return nil, err
+	}
+	return c.command(ctx, cmd)
+}
+
+// setup turns echo off and selects text mode SMS once per client. The caller
+// must hold mu.
+func (c *ATClient) setup(ctx context.Context) error {
+	if c.ready {
+		return nil
+	}
+
+	for _, cmd := range []string{"ATE0", "AT+CMGF=1", `AT+CSCS="GSM"`} {
+		if _, err := c.command(ctx, cmd); err != nil {
+			return fmt.Errorf("modem setup failed: %w", err)
+		}
+	}
+
+	c.ready = true
+	return nil
+}
+
+// atValue strips the "+CMD: " prefix from an information response
+func atValue(lines []string, prefix string) (string, bool) {
+	for _, line := range lines {
+		if rest, ok := strings.CutPrefix(line, prefix+":"); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return "", false
+}
+
+// splitATFields splits a comma separated response, keeping commas inside
+// quotes and removing the quotes
+func splitATFields(s string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			fields = append(fields, strings.TrimSpace(field.String()))
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+
+	return append(fields, strings.TrimSpace(field.String()))
+}
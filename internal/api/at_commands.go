@@ -0,0 +1,465 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// atAccessTechnologies names the <AcT> field of +COPS
+var atAccessTechnologies = map[string]string{
+	"0":  "GSM",
+	"1":  "GSM",
+	"2":  "WCDMA",
+	"3":  "EDGE",
+	"4":  "HSDPA",
+	"5":  "HSUPA",
+	"6":  "HSPA",
+	"7":  "LTE",
+	"13": "5G",
+}
+
+// simPIN matches passwords that can be offered to the SIM as a PIN
+var simPIN = regexp.MustCompile(`^[0-9]{4,8}$`)
+
+// PingContext checks that the modem answers AT
+func (c *ATClient) PingContext(ctx context.Context) error {
+	_, err := c.exec(ctx, "AT")
+	return err
+}
+
+// LoginContext checks the SIM state. Modems have no login; if the SIM asks
+// for a PIN and password looks like one it is used to unlock the SIM.
+func (c *ATClient) LoginContext(ctx context.Context, username, password string) error {
+	state, err := c.simState(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch state {
+	case "READY":
+		return nil
+	case "SIM PIN":
+		if !simPIN.MatchString(password) {
+			return ErrSIMLocked
+		}
+		if _, err := c.exec(ctx, fmt.Sprintf(`AT+CPIN="%s"`, password)); err != nil {
+			return fmt.Errorf("%w: %w", ErrBadPassword, err)
+		}
+		return nil
+	case "SIM PUK":
+		return ErrLockedOut
+	}
+
+	return &DeviceError{Goform: "AT+CPIN?", Result: state}
+}
+
+// LogoutContext does nothing; the modem has no session
+func (c *ATClient) LogoutContext(ctx context.Context) error {
+	return nil
+}
+
+// IsAuthenticatedContext reports whether the SIM is unlocked
+func (c *ATClient) IsAuthenticatedContext(ctx context.Context) bool {
+	state, err := c.simState(ctx)
+	return err == nil && state == "READY"
+}
+
+func (c *ATClient) simState(ctx context.Context) (string, error) {
+	lines, err := c.exec(ctx, "AT+CPIN?")
+	if err != nil {
+		return "", err
+	}
+
+	state, ok := atValue(lines, "+CPIN")
+	if !ok {
+		return "", fmt.Errorf("AT+CPIN?: %w", ErrUnexpectedResponse)
+	}
+	return state, nil
+}
+
+// GetDeviceStatusContext fills the status from +CSQ, +COPS and +CGPADDR.
+// Identity fields are read once and cached.
+func (c *ATClient) GetDeviceStatusContext(ctx context.Context) (*DeviceStatus, error) {
+	lines, err := c.exec(ctx, "AT+CSQ")
+	if err != nil {
+		return nil, err
+	}
+
+	status := &DeviceStatus{}
+	if csq, ok := atValue(lines, "+CSQ"); ok {
+		rssi, _ := strconv.Atoi(splitATFields(csq)[0])
+		status.SignalStrength = signalBars(rssi)
+	}
+
+	if lines, err := c.exec(ctx, "AT+COPS?"); err == nil {
+		if cops, ok := atValue(lines, "+COPS"); ok {
+			fields := splitATFields(cops)
+			if len(fields) >= 4 {
+				status.NetworkType = atAccessTechnologies[fields[3]]
+			}
+			if len(fields) >= 3 {
+				status.NetworkProvider = fields[2]
+				if status.NetworkType == "" {
+					status.NetworkType = "GSM"
+				}
+			}
+		}
+	}
+	if status.NetworkType == "" {
+		status.NetworkType = "No Service"
+	}
+
+	if lines, err := c.exec(ctx, "AT+CGPADDR=1"); err == nil {
+		if addr, ok := atValue(lines, "+CGPADDR"); ok {
+			if fields := splitATFields(addr); len(fields) >= 2 {
+				status.WanIPAddress = fields[1]
+			}
+		}
+	}
+
+	identity := c.deviceIdentity(ctx)
+	status.IMEI = identity.IMEI
+	status.ICCID = identity.ICCID
+	status.ModelName = identity.ModelName
+	status.SoftwareVersion = identity.SoftwareVersion
+
+	return status, nil
+}
+
+// signalBars maps an RSSI index from +CSQ onto the 0-5 bars the UI shows
+func signalBars(rssi int) int {
+	if rssi < 2 || rssi == 99 {
+		return 0
+	}
+	return min(5, 1+(rssi-2)/6)
+}
+
+func (c *ATClient) deviceIdentity(ctx context.Context) DeviceStatus {
+	c.mu.Lock()
+	cached := c.identity
+	c.mu.Unlock()
+	if cached != nil {
+		return *cached
+	}
+
+	identity := &DeviceStatus{}
+	if lines, err := c.exec(ctx, "AT+CGSN"); err == nil && len(lines) > 0 {
+		identity.IMEI = strings.TrimPrefix(lines[0], "+CGSN: ")
+	}
+	if lines, err := c.exec(ctx, "AT+CCID"); err == nil && len(lines) > 0 {
+		identity.ICCID = strings.TrimPrefix(lines[0], "+CCID: ")
+	}
+	if lines, err := c.exec(ctx, "AT+CGMM"); err == nil && len(lines) > 0 {
+		identity.ModelName = lines[0]
+	}
+	if lines, err := c.exec(ctx, "AT+CGMR"); err == nil && len(lines) > 0 {
+		identity.SoftwareVersion = lines[0]
+	}
+
+	if identity.IMEI != "" {
+		c.mu.Lock()
+		c.identity = identity
+		c.mu.Unlock()
+	}
+
+	return *identity
+}
+
+// GetWiFiConfigContext is not available on a modem
+func (c *ATClient) GetWiFiConfigContext(ctx context.Context) (*WiFiConfig, error) {
+	return nil, fmt.Errorf("WiFi settings: %w", ErrUnsupported)
+}
+
+// SetWiFiConfigContext is not available on a modem
+func (c *ATClient) SetWiFiConfigContext(ctx context.Context, config *WiFiConfig) error {
+	return fmt.Errorf("WiFi settings: %w", ErrUnsupported)
+}
+
+// GetSMSCountContext returns the number of messages in the read storage
+func (c *ATClient) GetSMSCountContext(ctx context.Context) (int, error) {
+	_, used, _, err := c.storage(ctx)
+	return used, err
+}
+
+// GetSMSCapacityContext reads the used and total slots of the storage
+// messages are read from
+func (c *ATClient) GetSMSCapacityContext(ctx context.Context) (*SMSCapacity, error) {
+	store, used, total, err := c.storage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if store == SMSStoreSIM {
+		return &SMSCapacity{SIMUsed: used, SIMTotal: total}, nil
+	}
+	return &SMSCapacity{DeviceUsed: used, DeviceTotal: total}, nil
+}
+
+// storage reads the storage messages are read from with +CPMS. "SM" is the
+// SIM; "ME" and "MT" count as device memory.
+func (c *ATClient) storage(ctx context.Context) (store SMSStore, used, total int, err error) {
+	lines, err := c.exec(ctx, "AT+CPMS?")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	cpms, ok := atValue(lines, "+CPMS")
+	fields := splitATFields(cpms)
+	if !ok || len(fields) < 3 {
+		return 0, 0, 0, fmt.Errorf("AT+CPMS?: %w", ErrUnexpectedResponse)
+	}
+
+	used, err1 := strconv.Atoi(fields[1])
+	total, err2 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil {
+		return 0, 0, 0, fmt.Errorf("AT+CPMS?: %w", ErrUnexpectedResponse)
+	}
+
+	store = SMSStoreDevice
+	if fields[0] == "SM" {
+		store = SMSStoreSIM
+	}
+	return store, used, total, nil
+}
+
+// GetSMSListContext lists all stored messages with +CMGL and returns one
+// page, newest first
+func (c *ATClient) GetSMSListContext(ctx context.Context, page, pageSize int) ([]SMSMessage, error) {
+	store, _, _, err := c.storage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := c.listSMS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		messages[i].Store = store
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.After(messages[j].Timestamp)
+	})
+
+	start := page * pageSize
+	if start >= len(messages) {
+		return nil, nil
+	}
+	end := min(start+pageSize, len(messages))
+
+	return messages[start:end], nil
+}
+
+// listSMS runs +CMGL in the UCS2 character set, where every message comes
+// as UCS2 hex whatever its coding, so UCS2 messages can be told from text.
+// Modems without UCS2 list in the GSM character set.
+func (c *ATClient) listSMS(ctx context.Context) ([]SMSMessage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setup(ctx); err != nil {
+		return nil, err
+	}
+
+	ucs2 := true
+	if _, err := c.command(ctx, `AT+CSCS="UCS2"`); err != nil {
+		c.Logger.Debugf("Listing messages in the GSM character set: %v", err)
+		ucs2 = false
+	} else {
+		defer c.command(context.WithoutCancel(ctx), `AT+CSCS="GSM"`)
+	}
+
+	lines, err := c.commandText(ctx, `AT+CMGL="ALL"`)
+	if err != nil {
+		return nil, err
+	}
+	return parseCMGL(lines, ucs2), nil
+}
+
+// parseCMGL turns text mode +CMGL output into messages. Each header line is
+// followed by the message text, which may span several lines and contain
+// blank ones; blank lines before the next header are framing. With ucs2 the
+// number and text are UCS2 hex, and are kept as they came if they do not
+// decode.
+func parseCMGL(lines []string, ucs2 bool) []SMSMessage {
+	var messages []SMSMessage
+	var body []string
+
+	flush := func() {
+		for len(body) > 0 && body[len(body)-1] == "" {
+			body = body[:len(body)-1]
+		}
+		if len(messages) > 0 {
+			content := strings.Join(body, "\n")
+			if ucs2 {
+				if decoded, ok := decodeUCS2Hex(strings.Join(body, "")); ok {
+					content = decoded
+				}
+			}
+			messages[len(messages)-1].Content = content
+		}
+		body = nil
+	}
+
+	for _, line := range lines {
+		header, ok := strings.CutPrefix(line, "+CMGL:")
+		if !ok {
+			body = append(body, line)
+			continue
+		}
+		flush()
+
+		fields := splitATFields(strings.TrimSpace(header))
+		if len(fields) < 3 {
+			continue
+		}
+
+		sms := SMSMessage{ID: fields[0], Number: fields[2], Type: "inbox"}
+		if ucs2 {
+			if number, ok := decodeUCS2Hex(sms.Number); ok {
+				sms.Number = number
+			}
+		}
+		switch fields[1] {
+		case "REC UNREAD":
+			sms.Status = SMSUnread
+		case "REC READ":
+			sms.Status = SMSRead
+		case "STO UNSENT":
+			sms.Status = SMSDraft
+			sms.Type = "draft"
+		default:
+			sms.Status = SMSSent
+			sms.Type = "sent"
+		}
+		if len(fields) >= 5 {
+			sms.Timestamp, _ = parseATTime(fields[4])
+		}
+
+		messages = append(messages, sms)
+	}
+	flush()
+
+	return messages
+}
+
+// parseATTime parses a "yy/MM/dd,hh:mm:ss±zz" service centre timestamp,
+// where zz is the offset in quarter hours
+func parseATTime(s string) (time.Time, error) {
+	if len(s) < 17 {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	loc := time.Local
+	if len(s) > 17 {
+		quarters, err := strconv.Atoi(s[17:])
+		if err == nil {
+			loc = time.FixedZone(quarterHourZoneName(quarters), quarters*15*60)
+		}
+	}
+
+	return time.ParseInLocation("06/01/02,15:04:05", s[:17], loc)
+}
+
+// SendSMSContext sends content with +CMGS, writing the text after the
+// prompt. UNICODE messages are sent with the UCS2 character set and data
+// coding scheme 8. Text mode cannot concatenate, so messages longer than one
+// segment are refused.
+func (c *ATClient) SendSMSContext(ctx context.Context, phoneNumber, content string) (SMSInfo, error) {
+	info := AnalyzeSMS(content)
+	if info.Segments > 1 {
+		return info, fmt.Errorf("%w: modems in text mode send a single segment", ErrMessageTooLong)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setup(ctx); err != nil {
+		return info, err
+	}
+
+	if info.Encoding == EncodingUnicode {
+		if err := c.unicodeMode(ctx, true); err != nil {
+			return info, err
+		}
+		defer c.unicodeMode(ctx, false)
+
+		phoneNumber = encodeUCS2Hex(phoneNumber)
+		content = encodeUCS2Hex(content)
+	}
+
+	cmd := fmt.Sprintf(`AT+CMGS="%s"`, phoneNumber)
+	if err := c.write(cmd + "\r"); err != nil {
+		return info, err
+	}
+	if err := c.prompt(ctx, cmd); err != nil {
+		return info, err
+	}
+
+	if err := c.write(content + atCtrlZ); err != nil {
+		return info, err
+	}
+	_, err := c.response(ctx, cmd)
+	return info, err
+}
+
+// unicodeMode switches the character set and data coding scheme between
+// UCS2 and the GSM default. The caller must hold mu.
+func (c *ATClient) unicodeMode(ctx context.Context, on bool) error {
+	charset, dcs := `AT+CSCS="GSM"`, "AT+CSMP=17,167,0,0"
+	if on {
+		charset, dcs = `AT+CSCS="UCS2"`, "AT+CSMP=17,167,0,8"
+	}
+
+	for _, cmd := range []string{charset, dcs} {
+		if _, err := c.command(ctx, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSMSContext deletes each message with +CMGD
+func (c *ATClient) DeleteSMSContext(ctx context.Context, messageIDs []string) error {
+	for _, id := range messageIDs {
+		if _, err := c.exec(ctx, "AT+CMGD="+id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetConnectedDevicesContext returns no devices; a modem serves one host
+func (c *ATClient) GetConnectedDevicesContext(ctx context.Context) ([]ConnectedDevice, error) {
+	return []ConnectedDevice{}, nil
+}
+
+// ConnectNetworkContext activates the first PDP context
+func (c *ATClient) ConnectNetworkContext(ctx context.Context) error {
+	_, err := c.exec(ctx, "AT+CGACT=1,1")
+	return err
+}
+
+// DisconnectNetworkContext deactivates the first PDP context
+func (c *ATClient) DisconnectNetworkContext(ctx context.Context) error {
+	_, err := c.exec(ctx, "AT+CGACT=0,1")
+	return err
+}
+
+// RebootDeviceContext resets the modem. The port disappears until it is
+// enumerated again.
+func (c *ATClient) RebootDeviceContext(ctx context.Context) error {
+	_, err := c.exec(ctx, "AT+CFUN=1,1")
+	return err
+}
+
+// ShutdownDeviceContext switches the modem off
+func (c *ATClient) ShutdownDeviceContext(ctx context.Context) error {
+	_, err := c.exec(ctx, "AT+CPOF")
+	return err
+}
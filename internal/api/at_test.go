@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"mifi_app/internal/mifisim"
+)
+
+// newTestATClient serves a simulated modem on a pseudo-terminal and opens
+// it the way the app opens a real serial port
+func newTestATClient(t *testing.T, modem *mifisim.ATModem) *ATClient {
+	t.Helper()
+
+	path, closer, err := modem.ServePTY()
+	if err != nil {
+		t.Skipf("no pseudo-terminal: %v", err)
+	}
+	t.Cleanup(func() { closer.Close() })
+
+	client, err := OpenATClient(path, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	client.SetTimeout(5 * time.Second)
+
+	return client
+}
+
+func TestATClient(t *testing.T) {
+	modem := mifisim.NewATModem(nil)
+	modem.PIN = "1234"
+	device := modem.Device()
+	client := newTestATClient(t, modem)
+	ctx := context.Background()
+
+	if err := client.LoginContext(ctx, "", ""); !errors.Is(err, ErrSIMLocked) {
+		t.Fatalf("login without a PIN = %v, want ErrSIMLocked", err)
+	}
+	if err := client.LoginContext(ctx, "", "1234"); err != nil {
+		t.Fatalf("login with the PIN: %v", err)
+	}
+
+	status, err := client.GetDeviceStatusContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.IMEI != device.IMEI || status.ModelName != device.ModelName {
+		t.Errorf("identity = %q %q, want %q %q", status.IMEI, status.ModelName, device.IMEI, device.ModelName)
+	}
+	if status.NetworkProvider != device.NetworkProvider || status.NetworkType != "LTE" {
+		t.Errorf("network = %q %q", status.NetworkProvider, status.NetworkType)
+	}
+	if status.SignalStrength == 0 {
+		t.Error("no signal reported")
+	}
+
+	modem.DeliverSMS("+265999000111", "Your bundle is active")
+	messages, err := client.GetSMSListContext(ctx, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) == 0 {
+		t.Fatal("no messages listed")
+	}
+	latest := messages[0]
+	if latest.Number != "+265999000111" || latest.Content != "Your bundle is active" || !latest.IsUnread() {
+		t.Errorf("newest message = %+v, want the delivered unread one", latest)
+	}
+	if latest.Timestamp.IsZero() {
+		t.Error("newest message has no time")
+	}
+
+	// UCS2 and multi-line messages come through whole
+	modem.DeliverSMS("+265999000111", "Moni 👋\n\nZikomo")
+	listed, err := client.GetSMSListContext(ctx, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != len(messages)+1 {
+		t.Fatalf("%d messages after one was delivered, want %d", len(listed), len(messages)+1)
+	}
+	found := false
+	for _, m := range listed {
+		found = found || m.Content == "Moni 👋\n\nZikomo" && m.Number == "+265999000111"
+	}
+	if !found {
+		t.Error("UCS2 message with blank lines not listed whole")
+	}
+	messages = listed
+
+	for _, content := range []string{"Hello from the test", "Zikomo 👍"} {
+		if _, err := client.SendSMSContext(ctx, "+265888000222", content); err != nil {
+			t.Fatalf("send %q: %v", content, err)
+		}
+	}
+
+	after, err := client.GetSMSListContext(ctx, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(messages)+2 {
+		t.Fatalf("%d messages after sending two, want %d", len(after), len(messages)+2)
+	}
+	sent := map[string]SMSMessage{}
+	for _, m := range after {
+		if m.IsSent() {
+			sent[m.Content] = m
+		}
+	}
+	for _, content := range []string{"Hello from the test", "Zikomo 👍"} {
+		if m, ok := sent[content]; !ok || m.Number != "+265888000222" {
+			t.Errorf("sent message %q not listed", content)
+		}
+	}
+
+	if err := client.DeleteSMSContext(ctx, []string{latest.ID}); err != nil {
+		t.Fatal(err)
+	}
+	remaining, err := client.GetSMSListContext(ctx, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range remaining {
+		if m.ID == latest.ID {
+			t.Errorf("message %s still listed after deleting it", latest.ID)
+		}
+	}
+	if len(remaining) != len(after)-1 {
+		t.Errorf("%d messages after deleting one, want %d", len(remaining), len(after)-1)
+	}
+}
+
+func TestParseCMGL(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		ucs2    bool
+		numbers []string
+		want    []string
+	}{
+		{"single lines", []string{
+			`+CMGL: 1,"REC READ","+265999000111",,"24/03/15,10:30:00+08"`, "Hello",
+			`+CMGL: 2,"REC UNREAD","+265888000222",,"24/03/15,10:31:00+08"`, "World",
+		}, false, []string{"+265999000111", "+265888000222"}, []string{"Hello", "World"}},
+		{"blank lines kept inside the text", []string{
+			"",
+			`+CMGL: 1,"REC READ","+265999000111",,"24/03/15,10:30:00+08"`, "Line one", "", "", "Line four", "",
+			`+CMGL: 2,"REC READ","+265999000111",,"24/03/15,10:31:00+08"`, "", "After a blank", "",
+		}, false, []string{"+265999000111", "+265999000111"}, []string{"Line one\n\n\nLine four", "\nAfter a blank"}},
+		{"empty message", []string{
+			`+CMGL: 3,"STO UNSENT","+265999000111",,`, "",
+		}, false, []string{"+265999000111"}, []string{""}},
+		{"UCS2", []string{
+			`+CMGL: 1,"REC READ","002B003200360035003900390039003000300030003100310031",,"24/03/15,10:30:00+08"`,
+			"004D006F006E00690020D83DDC4B000A000A005A0069006B006F006D006F",
+			`+CMGL: 2,"REC READ","00410049005200540045004C",,"24/03/15,10:31:00+08"`,
+			"0042",
+		}, true, []string{"+265999000111", "AIRTEL"}, []string{"Moni 👋\n\nZikomo", "B"}},
+		{"UCS2 that does not decode", []string{
+			`+CMGL: 1,"REC READ","+265999000111",,"24/03/15,10:30:00+08"`, "Not hex",
+		}, true, []string{"+265999000111"}, []string{"Not hex"}},
+	}
+
+	for _, tt := range tests {
+		messages := parseCMGL(tt.lines, tt.ucs2)
+		if len(messages) != len(tt.want) {
+			t.Errorf("%s: %d messages, want %d", tt.name, len(messages), len(tt.want))
+			continue
+		}
+		for i, m := range messages {
+			if m.Number != tt.numbers[i] || m.Content != tt.want[i] {
+				t.Errorf("%s: message %d = %q %q, want %q %q", tt.name, i, m.Number, m.Content, tt.numbers[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -1,8 +1,9 @@
 package api
 
 import (
-	"encoding/base64"
+	"context"
 	"fmt"
+	"strconv"
 )
 
 const (
@@ -10,37 +11,130 @@ const (
 	StatusEndpoint = "/goform/goform_get_cmd_process"
 )
 
-func (c *Client) Login(username, password string) error {
-	encodedPassword := base64.StdEncoding.EncodeToString([]byte(password))
+// LoginInfo holds the login-related fields reported by the device before
+// authenticating. AttemptsLeft is -1 when the firmware does not report it.
+type LoginInfo struct {
+	LD           string
+	LockTime     int
+	AttemptsLeft int
+}
 
-	data := map[string]string{
-		"isTest":   "false",
-		"goformId": "LOGIN",
-		"password": encodedPassword,
+// ProbeLogin asks the device which login scheme it expects
+func (c *Client) ProbeLogin() (*LoginInfo, error) {
+	return c.ProbeLoginContext(context.Background())
+}
+
+// ProbeLoginContext is like ProbeLogin but uses ctx for cancellation.
+func (c *Client) ProbeLoginContext(ctx context.Context) (*LoginInfo, error) {
+	params := map[string]string{
+		"cmd":        "LD,login_lock_time,psw_fail_num_str",
+		"multi_data": "1",
+		"isTest":     "false",
 	}
 
-	resp, err := c.Post(LoginEndpoint, data)
+	resp, err := c.get(ctx, StatusEndpoint, params)
 	if err != nil {
-		return fmt.Errorf("login request failed: %w", err)
+		return nil, fmt.Errorf("login probe failed: %w", err)
 	}
 
-	if result, ok := resp["result"].(string); ok {
-		if result == "0" || result == "4" {
-			return nil
+	info := &LoginInfo{AttemptsLeft: -1}
+	if val, ok := resp["LD"].(string); ok {
+		info.LD = val
+	}
+	if val, ok := resp["login_lock_time"].(string); ok && val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			info.LockTime = i
+		}
+	}
+	if val, ok := resp["psw_fail_num_str"].(string); ok && val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			info.AttemptsLeft = i
+		}
+	}
+
+	return info, nil
+}
+
+// Login authenticates with the device, picking the login scheme the firmware
+// supports. The scheme that worked is remembered for later logins, and the
+// credentials are kept so an expired session can be renewed transparently.
+func (c *Client) Login(username, password string) error {
+	return c.LoginContext(context.Background(), username, password)
+}
+
+// LoginContext is like Login but uses ctx for cancellation.
+func (c *Client) LoginContext(ctx context.Context, username, password string) error {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	return c.login(ctx, username, password)
+}
+
+// login performs the login with authMu held
+func (c *Client) login(ctx context.Context, username, password string) error {
+	info, err := c.ProbeLoginContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if info.AttemptsLeft == 0 && info.LockTime > 0 {
+		return fmt.Errorf("%w: retry in %d seconds", ErrLockedOut, info.LockTime)
+	}
+
+	candidates := loginSchemesFor(info, c.scheme)
+
+	var lastResult string
+	for _, scheme := range candidates {
+		resp, err := scheme.login(ctx, c, username, password)
+		if err != nil {
+			return fmt.Errorf("login request failed: %w", err)
 		}
+
+		result, ok := resp["result"].(string)
+		if !ok {
+			return fmt.Errorf("login: %w", ErrUnexpectedResponse)
+		}
+
 		switch result {
+		case "0", "4":
+			c.scheme = scheme
+			c.username = username
+			c.password = password
+			c.sessionGen++
+			c.signer.reset()
+			c.Logger.Debugf("Logged in using %s scheme", scheme.name())
+
+			if loginfo, err := c.loginfo(ctx); err == nil {
+				c.loginfoSupported = loginfo == "ok"
+			}
+			return nil
 		case "1":
-			return fmt.Errorf("login failed: general failure")
+			return &DeviceError{Goform: "LOGIN", Result: result}
 		case "2":
-			return fmt.Errorf("login failed: duplicate user (already logged in)")
+			return ErrDuplicateUser
 		case "3":
-			return fmt.Errorf("login failed: bad password")
-		default:
-			return fmt.Errorf("login failed: %s", result)
+			return c.badPasswordError(ctx)
 		}
+
+		// Anything else means this firmware does not know the goform
+		c.Logger.Debugf("Login scheme %s rejected: %s", scheme.name(), result)
+		lastResult = result
 	}
 
-	return fmt.Errorf("unexpected response format")
+	return fmt.Errorf("%w: no login scheme accepted (last result %s)", ErrUnsupported, lastResult)
+}
+
+// badPasswordError wraps ErrBadPassword with the remaining attempts, or
+// returns ErrLockedOut if the last attempt used up the allowance.
+func (c *Client) badPasswordError(ctx context.Context) error {
+	info, err := c.ProbeLoginContext(ctx)
+	if err != nil || info.AttemptsLeft < 0 {
+		return ErrBadPassword
+	}
+	if info.AttemptsLeft == 0 {
+		return fmt.Errorf("%w: retry in %d seconds", ErrLockedOut, info.LockTime)
+	}
+	return fmt.Errorf("%w: %d attempts left", ErrBadPassword, info.AttemptsLeft)
 }
 
 // Helper function to get map keys for debugging
@@ -52,23 +146,50 @@ func getKeys(m map[string]interface{}) []string {
 	return keys
 }
 
-// Logout ends the current session
+// Logout ends the current session and forgets the stored credentials
 func (c *Client) Logout() error {
+	return c.LogoutContext(context.Background())
+}
+
+// LogoutContext is like Logout but uses ctx for cancellation.
+func (c *Client) LogoutContext(ctx context.Context) error {
+	c.authMu.Lock()
+	c.username = ""
+	c.password = ""
+	c.authMu.Unlock()
+
 	data := map[string]string{
 		"goformId": "LOGOUT",
 	}
 
-	_, err := c.Post(LoginEndpoint, data)
+	_, err := c.post(ctx, LoginEndpoint, data)
 	if err != nil {
 		return fmt.Errorf("logout request failed: %w", err)
 	}
 
-	c.sessionID = ""
+	c.SetSessionID("")
+	c.signer.reset()
 	return nil
 }
 
-// IsAuthenticated checks if the client has a valid session
+// IsAuthenticated checks if the client has a valid session, renewing an
+// expired one if credentials are stored
 func (c *Client) IsAuthenticated() bool {
-	_, err := c.GetDeviceStatus()
-	return err == nil
+	return c.IsAuthenticatedContext(context.Background())
+}
+
+// IsAuthenticatedContext is like IsAuthenticated but uses ctx for cancellation.
+func (c *Client) IsAuthenticatedContext(ctx context.Context) bool {
+	gen := c.sessionGeneration()
+
+	expired, err := c.sessionExpired(ctx)
+	if err != nil {
+		return false
+	}
+	if !expired {
+		_, err := c.GetDeviceStatusContext(ctx)
+		return err == nil
+	}
+
+	return c.relogin(ctx, gen) == nil
 }
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Backend names accepted in the device configuration
+const (
+	BackendAuto   = "auto"
+	BackendZTE    = "zte"
+	BackendHiLink = "hilink"
+	BackendAT     = "at"
+)
+
+// DetectBackend works out which API the router at baseURL speaks. Serial
+// modems are never detected; BackendAT must be configured explicitly. HiLink
+// routers answer SesTokInfo with an XML token document; ZTE routers answer
+// the goform status endpoint with JSON. BackendZTE is returned, along with
+// the error, when neither can be reached.
+func DetectBackend(ctx context.Context, baseURL string, httpClient *http.Client) (string, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	body, err := probeBody(ctx, httpClient, baseURL, hilinkTokenEndpoint)
+	if err == nil && strings.Contains(body, "<TokInfo>") {
+		return BackendHiLink, nil
+	}
+
+	body, err = probeBody(ctx, httpClient, baseURL, StatusEndpoint+"?multi_data=1&cmd=loginfo")
+	if err != nil {
+		return BackendZTE, err
+	}
+
+	var v map[string]interface{}
+	if json.Unmarshal([]byte(body), &v) != nil {
+		return BackendZTE, ErrUnexpectedResponse
+	}
+
+	return BackendZTE, nil
+}
+
+func probeBody(ctx context.Context, httpClient *http.Client, baseURL, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Referer", baseURL+"/index.html")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
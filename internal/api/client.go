@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,6 +9,8 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -23,7 +26,29 @@ type Client struct {
 	BaseURL    string
 	HTTPClient *http.Client
 	Logger     *logrus.Logger
-	sessionID  string
+
+	// timeout bounds each request; it can be changed while requests are in flight
+	timeout atomic.Int64
+
+	sessionMu sync.RWMutex
+	sessionID string
+
+	signer adSigner
+
+	profileMu sync.Mutex
+	profile   *DeviceProfile
+
+	// ussdMu serializes USSD requests from posting one to reading its
+	// answer, as the device keeps a single session and answer
+	ussdMu sync.Mutex
+
+	// authMu serializes logins and guards the fields below
+	authMu           sync.Mutex
+	scheme           loginScheme
+	username         string
+	password         string
+	sessionGen       uint64
+	loginfoSupported bool
 }
 
 func NewClient(baseURL string, logger *logrus.Logger) *Client {
@@ -39,11 +64,10 @@ func NewClient(baseURL string, logger *logrus.Logger) *Client {
 		logger.Warnf("Failed to create cookie jar: %v", err)
 	}
 
-	return &Client{
+	c := &Client{
 		BaseURL: baseURL,
 		HTTPClient: &http.Client{
-			Timeout: DefaultTimeout,
-			Jar:     jar,
+			Jar: jar,
 			Transport: &http.Transport{
 				MaxIdleConns:        10,
 				MaxIdleConnsPerHost: 10,
@@ -52,17 +76,94 @@ func NewClient(baseURL string, logger *logrus.Logger) *Client {
 		},
 		Logger: logger,
 	}
+	c.SetTimeout(DefaultTimeout)
+
+	return c
+}
+
+// SetTimeout changes the per-request timeout. Values <= 0 restore DefaultTimeout.
+func (c *Client) SetTimeout(d time.Duration) {
+	if d <= 0 {
+		d = DefaultTimeout
+	}
+	c.timeout.Store(int64(d))
+}
+
+// Timeout returns the current per-request timeout
+func (c *Client) Timeout() time.Duration {
+	return time.Duration(c.timeout.Load())
 }
 
 func (c *Client) SetSessionID(sessionID string) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	c.sessionID = sessionID
 }
 
 func (c *Client) GetSessionID() string {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
 	return c.sessionID
 }
 
+// Get sends a get command. If the device has silently dropped the session,
+// the client logs in again with the stored credentials and retries once.
 func (c *Client) Get(endpoint string, params map[string]string) (map[string]interface{}, error) {
+	return c.GetContext(context.Background(), endpoint, params)
+}
+
+// GetContext is like Get but uses ctx for cancellation.
+func (c *Client) GetContext(ctx context.Context, endpoint string, params map[string]string) (map[string]interface{}, error) {
+	gen := c.sessionGeneration()
+
+	resp, err := c.get(ctx, endpoint, params)
+	if err != nil || !looksLoggedOut(resp) {
+		return resp, err
+	}
+
+	if expired, err := c.sessionExpired(ctx); err != nil || !expired {
+		return resp, nil
+	}
+
+	if err := c.relogin(ctx, gen); err != nil {
+		return nil, err
+	}
+
+	return c.get(ctx, endpoint, params)
+}
+
+// Post sends a set command, re-authenticating and retrying once if the
+// command failed because the session expired.
+func (c *Client) Post(endpoint string, data map[string]string) (map[string]interface{}, error) {
+	return c.PostContext(context.Background(), endpoint, data)
+}
+
+// PostContext is like Post but uses ctx for cancellation.
+func (c *Client) PostContext(ctx context.Context, endpoint string, data map[string]string) (map[string]interface{}, error) {
+	gen := c.sessionGeneration()
+
+	resp, err := c.post(ctx, endpoint, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if result, _ := resp["result"].(string); result != "failure" {
+		return resp, nil
+	}
+
+	if expired, err := c.sessionExpired(ctx); err != nil || !expired {
+		return resp, nil
+	}
+
+	if err := c.relogin(ctx, gen); err != nil {
+		return nil, err
+	}
+
+	return c.post(ctx, endpoint, data)
+}
+
+// get performs a single get command without session recovery
+func (c *Client) get(ctx context.Context, endpoint string, params map[string]string) (map[string]interface{}, error) {
 	u, err := url.Parse(c.BaseURL + endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
@@ -74,7 +175,10 @@ func (c *Client) Get(endpoint string, params map[string]string) (map[string]inte
 	}
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -83,10 +187,10 @@ func (c *Client) Get(endpoint string, params map[string]string) (map[string]inte
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
 	req.Header.Set("Connection", "keep-alive")
 
-	if c.sessionID != "" {
+	if sessionID := c.GetSessionID(); sessionID != "" {
 		req.AddCookie(&http.Cookie{
 			Name:  "PHPSESSID",
-			Value: c.sessionID,
+			Value: sessionID,
 		})
 	}
 
@@ -113,15 +217,30 @@ func (c *Client) Get(endpoint string, params map[string]string) (map[string]inte
 	return result, nil
 }
 
-func (c *Client) Post(endpoint string, data map[string]string) (map[string]interface{}, error) {
+// post performs a single set command without session recovery
+func (c *Client) post(ctx context.Context, endpoint string, data map[string]string) (map[string]interface{}, error) {
 	formData := url.Values{}
 	for key, value := range data {
 		formData.Set(key, value)
 	}
 
+	// Newer firmware rejects set commands that are not signed with AD
+	if _, ok := data["goformId"]; ok {
+		ad, err := c.commandToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign command: %w", err)
+		}
+		if ad != "" {
+			formData.Set("AD", ad)
+		}
+	}
+
 	encodedData := formData.Encode()
 
-	req, err := http.NewRequest("POST", c.BaseURL+endpoint, strings.NewReader(encodedData))
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+endpoint, strings.NewReader(encodedData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -131,10 +250,10 @@ func (c *Client) Post(endpoint string, data map[string]string) (map[string]inter
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
 	req.Header.Set("Connection", "keep-alive")
 
-	if c.sessionID != "" {
+	if sessionID := c.GetSessionID(); sessionID != "" {
 		req.AddCookie(&http.Cookie{
 			Name:  "PHPSESSID",
-			Value: c.sessionID,
+			Value: sessionID,
 		})
 	}
 
@@ -162,7 +281,15 @@ func (c *Client) Post(endpoint string, data map[string]string) (map[string]inter
 }
 
 func (c *Client) Ping() error {
-	req, err := http.NewRequest("GET", c.BaseURL, nil)
+	return c.PingContext(context.Background())
+}
+
+// PingContext is like Ping but uses ctx for cancellation.
+func (c *Client) PingContext(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL, nil)
 	if err != nil {
 		return err
 	}
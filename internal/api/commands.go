@@ -1,7 +1,7 @@
 package api
 
 import (
-	"encoding/hex"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -9,16 +9,22 @@ import (
 )
 
 func (c *Client) GetDeviceStatus() (*DeviceStatus, error) {
+	return c.GetDeviceStatusContext(context.Background())
+}
+
+// GetDeviceStatusContext is like GetDeviceStatus but uses ctx for cancellation.
+func (c *Client) GetDeviceStatusContext(ctx context.Context) (*DeviceStatus, error) {
 	params := map[string]string{
 		"cmd": "modem_main_state,pin_status,network_type,signalbar,battery_value," +
 			"battery_charging,wifi_status,ssid1,station_mac,network_provider," +
 			"wan_ipaddr,wan_apn,ppp_status,realtime_tx_bytes,realtime_rx_bytes," +
-			"realtime_time,realtime_tx_thrpt,realtime_rx_thrpt,sta_count",
+			"realtime_time,realtime_tx_thrpt,realtime_rx_thrpt,sta_count," +
+			"imei,iccid,model_name,hardware_version,software_version",
 		"multi_data": "1",
 		"isTest":     "false",
 	}
 
-	resp, err := c.Get(StatusEndpoint, params)
+	resp, err := c.GetContext(ctx, StatusEndpoint, params)
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +72,9 @@ func (c *Client) GetDeviceStatus() (*DeviceStatus, error) {
 			status.RxBytes = u
 		}
 	}
+	if val, ok := resp["network_provider"].(string); ok {
+		status.NetworkProvider = val
+	}
 	if val, ok := resp["imei"].(string); ok {
 		status.IMEI = val
 	}
@@ -86,35 +95,46 @@ func (c *Client) GetDeviceStatus() (*DeviceStatus, error) {
 }
 
 func (c *Client) GetWiFiConfig() (*WiFiConfig, error) {
+	return c.GetWiFiConfigContext(context.Background())
+}
+
+// GetWiFiConfigContext is like GetWiFiConfig but uses ctx for cancellation.
+func (c *Client) GetWiFiConfigContext(ctx context.Context) (*WiFiConfig, error) {
+	profile := c.Profile(ctx)
+
 	params := map[string]string{
-		"cmd": "wifi_ssid,wifi_password,security_mode,hide_ssid,wifi_channel,max_client_num",
+		"cmd": strings.Join([]string{
+			profile.SSIDKey, profile.PasswordKey, profile.SecurityKey,
+			profile.HideSSIDKey, profile.ChannelKey, profile.MaxClientsKey,
+		}, ","),
+		"multi_data": "1",
 	}
 
-	resp, err := c.Get(StatusEndpoint, params)
+	resp, err := c.GetContext(ctx, StatusEndpoint, params)
 	if err != nil {
 		return nil, err
 	}
 
 	config := &WiFiConfig{}
 
-	if val, ok := resp["wifi_ssid"].(string); ok {
+	if val, ok := resp[profile.SSIDKey].(string); ok {
 		config.SSID = val
 	}
-	if val, ok := resp["wifi_password"].(string); ok {
-		config.Password = val
+	if val, ok := resp[profile.PasswordKey].(string); ok {
+		config.Password = profile.decodeWiFiPassword(val)
 	}
-	if val, ok := resp["security_mode"].(string); ok {
+	if val, ok := resp[profile.SecurityKey].(string); ok {
 		config.SecurityMode = val
 	}
-	if val, ok := resp["hide_ssid"].(string); ok {
+	if val, ok := resp[profile.HideSSIDKey].(string); ok {
 		config.HideSSID = val == "1"
 	}
-	if val, ok := resp["wifi_channel"].(string); ok {
+	if val, ok := resp[profile.ChannelKey].(string); ok {
 		if i, err := strconv.Atoi(val); err == nil {
 			config.Channel = i
 		}
 	}
-	if val, ok := resp["max_client_num"].(string); ok {
+	if val, ok := resp[profile.MaxClientsKey].(string); ok {
 		if i, err := strconv.Atoi(val); err == nil {
 			config.MaxClients = i
 		}
@@ -124,110 +144,116 @@ func (c *Client) GetWiFiConfig() (*WiFiConfig, error) {
 }
 
 func (c *Client) SetWiFiConfig(config *WiFiConfig) error {
+	return c.SetWiFiConfigContext(context.Background(), config)
+}
+
+// SetWiFiConfigContext is like SetWiFiConfig but uses ctx for cancellation.
+func (c *Client) SetWiFiConfigContext(ctx context.Context, config *WiFiConfig) error {
+	profile := c.Profile(ctx)
+
 	data := map[string]string{
-		"goformId":      "SET_WIFI_SSID_PASSWORD",
-		"wifi_ssid":     config.SSID,
-		"wifi_password": config.Password,
-		"security_mode": config.SecurityMode,
+		"goformId":            profile.WiFiGoform,
+		profile.SSIDParam:     config.SSID,
+		profile.PasswordParam: profile.encodeWiFiPassword(config.Password),
+		profile.SecurityParam: config.SecurityMode,
 	}
 
-	if config.HideSSID {
-		data["hide_ssid"] = "1"
-	} else {
-		data["hide_ssid"] = "0"
+	if profile.HideSSIDParam != "" {
+		if config.HideSSID {
+			data[profile.HideSSIDParam] = "1"
+		} else {
+			data[profile.HideSSIDParam] = "0"
+		}
 	}
 
-	if config.Channel > 0 {
-		data["wifi_channel"] = strconv.Itoa(config.Channel)
+	if config.Channel > 0 && profile.ChannelParam != "" {
+		data[profile.ChannelParam] = strconv.Itoa(config.Channel)
 	}
 
-	if config.MaxClients > 0 {
-		data["max_client_num"] = strconv.Itoa(config.MaxClients)
+	if config.MaxClients > 0 && profile.MaxClientsParam != "" {
+		data[profile.MaxClientsParam] = strconv.Itoa(config.MaxClients)
 	}
 
-	resp, err := c.Post(LoginEndpoint, data)
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
 	if err != nil {
 		return err
 	}
 
-	if result, ok := resp["result"].(string); ok {
-		if result == "0" || strings.ToLower(result) == "success" {
-			return nil
-		}
-		return fmt.Errorf("failed to update WiFi config: %s", result)
-	}
-
-	return fmt.Errorf("unexpected response format")
+	return checkResult(data["goformId"], resp)
 }
 
 // ConnectNetwork attempts to connect to the network
 func (c *Client) ConnectNetwork() error {
+	return c.ConnectNetworkContext(context.Background())
+}
+
+// ConnectNetworkContext is like ConnectNetwork but uses ctx for cancellation.
+func (c *Client) ConnectNetworkContext(ctx context.Context) error {
 	data := map[string]string{
 		"goformId": "CONNECT_NETWORK",
 	}
 
-	resp, err := c.Post(LoginEndpoint, data)
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
 	if err != nil {
 		return err
 	}
 
-	if result, ok := resp["result"].(string); ok {
-		if result == "0" || strings.ToLower(result) == "success" {
-			return nil
-		}
-		return fmt.Errorf("failed to connect: %s", result)
-	}
-
-	return fmt.Errorf("unexpected response format")
+	return checkResult(data["goformId"], resp)
 }
 
 // DisconnectNetwork disconnects from the network
 func (c *Client) DisconnectNetwork() error {
+	return c.DisconnectNetworkContext(context.Background())
+}
+
+// DisconnectNetworkContext is like DisconnectNetwork but uses ctx for cancellation.
+func (c *Client) DisconnectNetworkContext(ctx context.Context) error {
 	data := map[string]string{
 		"goformId": "DISCONNECT_NETWORK",
 	}
 
-	resp, err := c.Post(LoginEndpoint, data)
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
 	if err != nil {
 		return err
 	}
 
-	if result, ok := resp["result"].(string); ok {
-		if result == "0" || strings.ToLower(result) == "success" {
-			return nil
-		}
-		return fmt.Errorf("failed to disconnect: %s", result)
-	}
-
-	return fmt.Errorf("unexpected response format")
+	return checkResult(data["goformId"], resp)
 }
 
 // GetConnectedDevices retrieves the list of connected devices
 func (c *Client) GetConnectedDevices() ([]ConnectedDevice, error) {
-	params := map[string]string{
-		"cmd": "station_list",
-	}
-
-	resp, err := c.Get(StatusEndpoint, params)
-	if err != nil {
-		return nil, err
-	}
+	return c.GetConnectedDevicesContext(context.Background())
+}
 
-	// The actual format may vary - this is a placeholder
+// GetConnectedDevicesContext is like GetConnectedDevices but uses ctx for cancellation.
+func (c *Client) GetConnectedDevicesContext(ctx context.Context) ([]ConnectedDevice, error) {
 	devices := []ConnectedDevice{}
 
-	// Parse station list if available
-	if stationList, ok := resp["station_list"].([]interface{}); ok {
+	for _, key := range c.Profile(ctx).StationListKeys {
+		params := map[string]string{
+			"cmd": key,
+		}
+
+		resp, err := c.GetContext(ctx, StatusEndpoint, params)
+		if err != nil {
+			return nil, err
+		}
+
+		stationList, ok := resp[key].([]interface{})
+		if !ok {
+			continue
+		}
+
 		for _, station := range stationList {
 			if s, ok := station.(map[string]interface{}); ok {
 				device := ConnectedDevice{}
 				if val, ok := s["hostname"].(string); ok {
 					device.Hostname = val
 				}
-				if val, ok := s["ipaddress"].(string); ok {
+				if val := firstString(s, "ipaddress", "ip_addr"); val != "" {
 					device.IPAddress = val
 				}
-				if val, ok := s["macaddress"].(string); ok {
+				if val := firstString(s, "macaddress", "mac_addr"); val != "" {
 					device.MACAddress = val
 				}
 				devices = append(devices, device)
@@ -238,14 +264,54 @@ func (c *Client) GetConnectedDevices() ([]ConnectedDevice, error) {
 	return devices, nil
 }
 
+// smsStatusForTag maps a message tag: 0 read, 1 unread, 2 and 3 sent, 4 draft
+func smsStatusForTag(tag string) SMSStatus {
+	switch tag {
+	case "1":
+		return SMSUnread
+	case "2", "3":
+		return SMSSent
+	case "4":
+		return SMSDraft
+	}
+	return SMSRead
+}
+
+// smsTypeForTag maps a message tag onto the SMSMessage Type: 2 and 3 are
+// sent (delivered or not), 4 is a draft
+func smsTypeForTag(tag string) string {
+	switch tag {
+	case "2", "3":
+		return "sent"
+	case "4":
+		return "draft"
+	}
+	return "inbox"
+}
+
+// firstString returns the first non-empty string value among keys
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if val, ok := m[key].(string); ok && val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
 func (c *Client) GetSMSCount() (int, error) {
+	return c.GetSMSCountContext(context.Background())
+}
+
+// GetSMSCountContext is like GetSMSCount but uses ctx for cancellation.
+func (c *Client) GetSMSCountContext(ctx context.Context) (int, error) {
 	params := map[string]string{
 		"cmd":        "sms_data_total",
 		"multi_data": "1",
 		"isTest":     "false",
 	}
 
-	resp, err := c.Get(StatusEndpoint, params)
+	resp, err := c.GetContext(ctx, StatusEndpoint, params)
 	if err != nil {
 		return 0, err
 	}
@@ -259,17 +325,75 @@ func (c *Client) GetSMSCount() (int, error) {
 	return 0, nil
 }
 
+// GetSMSCapacity returns how many messages the device memory and SIM hold
+// and can hold
+func (c *Client) GetSMSCapacity() (*SMSCapacity, error) {
+	return c.GetSMSCapacityContext(context.Background())
+}
+
+// GetSMSCapacityContext is like GetSMSCapacity but uses ctx for cancellation.
+func (c *Client) GetSMSCapacityContext(ctx context.Context) (*SMSCapacity, error) {
+	params := map[string]string{
+		"cmd": "sms_capacity_info",
+	}
+
+	resp, err := c.GetContext(ctx, StatusEndpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
+	total, ok := resp["sms_nv_total"].(string)
+	if !ok || total == "" {
+		return nil, fmt.Errorf("sms_capacity_info: %w", ErrUnsupported)
+	}
+
+	field := func(name string) int {
+		n, _ := strconv.Atoi(firstString(resp, name))
+		return n
+	}
+
+	// The SIM has no used total, only per box counts
+	return &SMSCapacity{
+		DeviceUsed:  field("sms_nvused_total"),
+		DeviceTotal: field("sms_nv_total"),
+		SIMUsed:     field("sms_sim_rev_total") + field("sms_sim_send_total") + field("sms_sim_draftbox_total"),
+		SIMTotal:    field("sms_sim_total"),
+	}, nil
+}
+
 func (c *Client) GetSMSList(page, pageSize int) ([]SMSMessage, error) {
+	return c.GetSMSListContext(context.Background(), page, pageSize)
+}
+
+// GetSMSListContext is like GetSMSList but uses ctx for cancellation.
+func (c *Client) GetSMSListContext(ctx context.Context, page, pageSize int) ([]SMSMessage, error) {
+	return c.GetStoredSMSListContext(ctx, SMSStoreDevice, page, pageSize)
+}
+
+// GetStoredSMSList returns one page of the inbox, sentbox and drafts kept in
+// store, newest first
+func (c *Client) GetStoredSMSList(store SMSStore, page, pageSize int) ([]SMSMessage, error) {
+	return c.GetStoredSMSListContext(context.Background(), store, page, pageSize)
+}
+
+// GetStoredSMSListContext is like GetStoredSMSList but uses ctx for cancellation.
+func (c *Client) GetStoredSMSListContext(ctx context.Context, store SMSStore, page, pageSize int) ([]SMSMessage, error) {
+	// mem_store 0 is the SIM and 1 the device memory; tags 10 is every box
+	memStore := "1"
+	if store == SMSStoreSIM {
+		memStore = "0"
+	}
+
 	params := map[string]string{
 		"cmd":           "sms_data_total",
 		"page":          strconv.Itoa(page),
 		"data_per_page": strconv.Itoa(pageSize),
-		"mem_store":     "1",
+		"mem_store":     memStore,
 		"tags":          "10",
 		"order_by":      "order+by+id+desc",
 	}
 
-	resp, err := c.Get(StatusEndpoint, params)
+	resp, err := c.GetContext(ctx, StatusEndpoint, params)
 	if err != nil {
 		return nil, err
 	}
@@ -279,44 +403,7 @@ func (c *Client) GetSMSList(page, pageSize int) ([]SMSMessage, error) {
 	if msgList, ok := resp["messages"].([]interface{}); ok {
 		for _, msg := range msgList {
 			if m, ok := msg.(map[string]interface{}); ok {
-				sms := SMSMessage{}
-				if val, ok := m["id"].(string); ok {
-					sms.ID = val
-				}
-				if val, ok := m["number"].(string); ok {
-					sms.Number = val
-				}
-				if val, ok := m["content"].(string); ok {
-					decoded, err := decodeHexSMS(val)
-					if err != nil {
-						sms.Content = val
-					} else {
-						sms.Content = decoded
-					}
-				}
-				if val, ok := m["tag"].(string); ok {
-					sms.Status = val
-				}
-				if val, ok := m["date"].(string); ok {
-					// Parse date string - device returns format: YY,MM,DD,HH,MM,SS,+TZ
-					// Example: "25,12,20,18,38,02,+8" means 2025-12-20 18:38:02 +8
-					parts := strings.Split(val, ",")
-					if len(parts) >= 6 {
-						// Convert to standard format
-						year := "20" + parts[0]
-						month := parts[1]
-						day := parts[2]
-						hour := parts[3]
-						minute := parts[4]
-						second := parts[5]
-						dateStr := fmt.Sprintf("%s-%s-%s %s:%s:%s", year, month, day, hour, minute, second)
-
-						if t, err := time.Parse("2006-01-02 15:04:05", dateStr); err == nil {
-							sms.Timestamp = t
-						}
-					}
-				}
-				messages = append(messages, sms)
+				messages = append(messages, parseSMSEntry(m, store))
 			}
 		}
 	}
@@ -324,103 +411,187 @@ func (c *Client) GetSMSList(page, pageSize int) ([]SMSMessage, error) {
 	return messages, nil
 }
 
-func (c *Client) SendSMS(phoneNumber, content string) error {
+// parseSMSEntry reads one message of an sms_data_total page. Content is
+// decoded with decodeStoredSMS; content that does not decode is kept as it
+// came.
+func parseSMSEntry(m map[string]interface{}, store SMSStore) SMSMessage {
+	sms := SMSMessage{
+		Store:  store,
+		ID:     firstString(m, "id"),
+		Number: firstString(m, "number"),
+	}
+	if val, ok := m["content"].(string); ok {
+		decoded, err := decodeStoredSMS(val, firstString(m, "encode_type"))
+		if err != nil {
+			sms.Content = val
+		} else {
+			sms.Content = decoded
+		}
+	}
+	if val, ok := m["tag"].(string); ok {
+		sms.Status = smsStatusForTag(val)
+		sms.Type = smsTypeForTag(val)
+	}
+	if val, ok := m["date"].(string); ok {
+		if t, err := parseSMSDate(val); err == nil {
+			sms.Timestamp = t
+		}
+	}
+	if total, err := strconv.Atoi(firstString(m, "concat_sms_total")); err == nil && total > 1 {
+		sms.ConcatTotal = total
+		sms.ConcatRef = firstString(m, "concat_sms_ref", "sms_concat_ref")
+		sms.ConcatPart, _ = strconv.Atoi(firstString(m, "concat_sms_seq", "sms_concat_seq"))
+	}
+	return sms
+}
+
+// SendSMS sends content, choosing GSM-7 or UNICODE encoding, and returns how
+// the message was encoded and split. Messages longer than MaxSMSSegments are
+// refused with ErrMessageTooLong.
+func (c *Client) SendSMS(phoneNumber, content string) (SMSInfo, error) {
+	return c.SendSMSContext(context.Background(), phoneNumber, content)
+}
+
+// SendSMSContext is like SendSMS but uses ctx for cancellation.
+func (c *Client) SendSMSContext(ctx context.Context, phoneNumber, content string) (SMSInfo, error) {
+	info, err := checkSMSLength(content)
+	if err != nil {
+		return info, err
+	}
+
 	data := map[string]string{
 		"goformId":    "SEND_SMS",
 		"notCallback": "true",
 		"Number":      phoneNumber,
-		"sms_time":    time.Now().Format("2006-01-02 15:04:05"),
-		"MessageBody": content,
+		"sms_time":    formatSMSTime(time.Now()),
+		"MessageBody": encodeUCS2Hex(content),
 		"ID":          "-1",
-		"encode_type": "GSM7_default",
+		"encode_type": string(info.Encoding),
 		"isTest":      "false",
 	}
 
-	resp, err := c.Post(LoginEndpoint, data)
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
 	if err != nil {
-		return err
+		return info, err
 	}
 
-	if result, ok := resp["result"].(string); ok {
-		if result == "success" {
-			return nil
-		}
-		return fmt.Errorf("failed to send SMS: %s", result)
+	return info, checkResult(data["goformId"], resp)
+}
+
+// GetSMSSendStatus returns the outcome of the last SEND_SMS. The goform
+// answers success once the message is queued; the modem reports the real
+// result here afterwards.
+func (c *Client) GetSMSSendStatus() (SMSSendStatus, error) {
+	return c.GetSMSSendStatusContext(context.Background())
+}
+
+// GetSMSSendStatusContext is like GetSMSSendStatus but uses ctx for cancellation.
+func (c *Client) GetSMSSendStatusContext(ctx context.Context) (SMSSendStatus, error) {
+	// sms_cmd 4 is the send command
+	params := map[string]string{
+		"cmd":     "sms_cmd_status_info",
+		"sms_cmd": "4",
+		"isTest":  "false",
+	}
+
+	resp, err := c.GetContext(ctx, StatusEndpoint, params)
+	if err != nil {
+		return SMSSendUnknown, err
 	}
 
-	return fmt.Errorf("unexpected response format")
+	switch firstString(resp, "sms_cmd_status_result") {
+	case "1":
+		return SMSSendProcessing, nil
+	case "2":
+		return SMSSendFailed, nil
+	case "3":
+		return SMSSendSucceeded, nil
+	}
+	return SMSSendUnknown, nil
 }
 
-func (c *Client) DeleteSMS(messageIDs []string) error {
+// MarkSMSRead sets the read state of messages with SET_MSG_READ, which also
+// clears the device's unread indicator
+func (c *Client) MarkSMSRead(messageIDs []string, read bool) error {
+	return c.MarkSMSReadContext(context.Background(), messageIDs, read)
+}
+
+// MarkSMSReadContext is like MarkSMSRead but uses ctx for cancellation.
+func (c *Client) MarkSMSReadContext(ctx context.Context, messageIDs []string, read bool) error {
+	tag := "0"
+	if !read {
+		tag = "1"
+	}
+
 	data := map[string]string{
-		"goformId":    "DELETE_SMS",
-		"msg_id":      strings.Join(messageIDs, ";"),
+		"goformId":    "SET_MSG_READ",
+		"msg_id":      strings.Join(messageIDs, ";") + ";",
+		"tag":         tag,
 		"notCallback": "true",
 		"isTest":      "false",
 	}
 
-	resp, err := c.Post(LoginEndpoint, data)
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
 	if err != nil {
 		return err
 	}
 
-	if result, ok := resp["result"].(string); ok {
-		if result == "success" {
-			return nil
-		}
-		return fmt.Errorf("failed to delete SMS: %s", result)
-	}
+	return checkResult(data["goformId"], resp)
+}
 
-	return fmt.Errorf("unexpected response format")
+func (c *Client) DeleteSMS(messageIDs []string) error {
+	return c.DeleteSMSContext(context.Background(), messageIDs)
 }
 
-func (c *Client) RebootDevice() error {
+// DeleteSMSContext is like DeleteSMS but uses ctx for cancellation.
+func (c *Client) DeleteSMSContext(ctx context.Context, messageIDs []string) error {
 	data := map[string]string{
-		"goformId": "REBOOT_DEVICE",
+		"goformId":    "DELETE_SMS",
+		"msg_id":      strings.Join(messageIDs, ";"),
+		"notCallback": "true",
+		"isTest":      "false",
 	}
 
-	resp, err := c.Post(LoginEndpoint, data)
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
 	if err != nil {
-		return fmt.Errorf("failed to send reboot command: %w", err)
+		return err
 	}
 
-	if result, ok := resp["result"].(string); ok {
-		if result == "success" {
-			return nil
-		}
-		return fmt.Errorf("failed to reboot device: %s", result)
-	}
+	return checkResult(data["goformId"], resp)
+}
 
-	return fmt.Errorf("unexpected response format")
+func (c *Client) RebootDevice() error {
+	return c.RebootDeviceContext(context.Background())
 }
 
-func (c *Client) ShutdownDevice() error {
+// RebootDeviceContext is like RebootDevice but uses ctx for cancellation.
+func (c *Client) RebootDeviceContext(ctx context.Context) error {
 	data := map[string]string{
-		"goformId": "POWEROFF_DEVICE",
+		"goformId": "REBOOT_DEVICE",
 	}
 
-	resp, err := c.Post(LoginEndpoint, data)
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
 	if err != nil {
-		return fmt.Errorf("failed to send shutdown command: %w", err)
+		return fmt.Errorf("failed to send reboot command: %w", err)
 	}
 
-	if result, ok := resp["result"].(string); ok {
-		if result == "success" {
-			return nil
-		}
-		return fmt.Errorf("failed to shutdown device: %s", result)
-	}
+	return checkResult(data["goformId"], resp)
+}
 
-	return fmt.Errorf("unexpected response format")
+func (c *Client) ShutdownDevice() error {
+	return c.ShutdownDeviceContext(context.Background())
 }
 
-func decodeHexSMS(hexContent string) (string, error) {
-	hexContent = strings.ReplaceAll(hexContent, " ", "")
+// ShutdownDeviceContext is like ShutdownDevice but uses ctx for cancellation.
+func (c *Client) ShutdownDeviceContext(ctx context.Context) error {
+	data := map[string]string{
+		"goformId": "POWEROFF_DEVICE",
+	}
 
-	decoded, err := hex.DecodeString(hexContent)
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
 	if err != nil {
-		return "", fmt.Errorf("invalid hex string: %w", err)
+		return fmt.Errorf("failed to send shutdown command: %w", err)
 	}
 
-	return string(decoded), nil
+	return checkResult(data["goformId"], resp)
 }
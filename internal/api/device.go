@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// DeviceAPI is the set of operations the UI needs from a device backend.
+// The ZTE goform Client is one implementation.
+type DeviceAPI interface {
+	// Session
+	PingContext(ctx context.Context) error
+	LoginContext(ctx context.Context, username, password string) error
+	LogoutContext(ctx context.Context) error
+	IsAuthenticatedContext(ctx context.Context) bool
+	SetTimeout(d time.Duration)
+
+	// Status
+	GetDeviceStatusContext(ctx context.Context) (*DeviceStatus, error)
+
+	// WiFi
+	GetWiFiConfigContext(ctx context.Context) (*WiFiConfig, error)
+	SetWiFiConfigContext(ctx context.Context, config *WiFiConfig) error
+
+	// SMS
+	GetSMSCountContext(ctx context.Context) (int, error)
+	GetSMSListContext(ctx context.Context, page, pageSize int) ([]SMSMessage, error)
+	SendSMSContext(ctx context.Context, phoneNumber, content string) (SMSInfo, error)
+	DeleteSMSContext(ctx context.Context, messageIDs []string) error
+
+	// Connected devices
+	GetConnectedDevicesContext(ctx context.Context) ([]ConnectedDevice, error)
+
+	// Network and power
+	ConnectNetworkContext(ctx context.Context) error
+	DisconnectNetworkContext(ctx context.Context) error
+	RebootDeviceContext(ctx context.Context) error
+	ShutdownDeviceContext(ctx context.Context) error
+}
+
+// SMSReadMarker is implemented by backends that can change the read state of
+// stored messages. Check for it with a type assertion.
+type SMSReadMarker interface {
+	MarkSMSReadContext(ctx context.Context, messageIDs []string, read bool) error
+}
+
+// SMSStoreLister is implemented by backends that keep messages in separate
+// SIM and device memory and can list each one
+type SMSStoreLister interface {
+	GetStoredSMSListContext(ctx context.Context, store SMSStore, page, pageSize int) ([]SMSMessage, error)
+}
+
+// SMSCapacityReader is implemented by backends that report how full message
+// storage is
+type SMSCapacityReader interface {
+	GetSMSCapacityContext(ctx context.Context) (*SMSCapacity, error)
+}
+
+// SMSSendTracker is implemented by backends that report whether an accepted
+// message was actually sent
+type SMSSendTracker interface {
+	GetSMSSendStatusContext(ctx context.Context) (SMSSendStatus, error)
+}
+
+// USSDSender is implemented by backends that can run USSD sessions, as used
+// for balance checks and operator menus. One session runs at a time.
+type USSDSender interface {
+	SendUSSDContext(ctx context.Context, code string) (*USSDResponse, error)
+	ReplyUSSDContext(ctx context.Context, reply string) (*USSDResponse, error)
+	CancelUSSDContext(ctx context.Context) error
+}
+
+// Phonebook is implemented by backends that manage the contacts kept on the
+// SIM and in device memory
+type Phonebook interface {
+	GetContactsContext(ctx context.Context) ([]Contact, error)
+	AddContactContext(ctx context.Context, contact *Contact) error
+	UpdateContactContext(ctx context.Context, contact *Contact) error
+	DeleteContactsContext(ctx context.Context, ids []string) error
+}
+
+var (
+	_ DeviceAPI         = (*Client)(nil)
+	_ SMSReadMarker     = (*Client)(nil)
+	_ SMSReadMarker     = (*HiLinkClient)(nil)
+	_ SMSStoreLister    = (*Client)(nil)
+	_ SMSCapacityReader = (*Client)(nil)
+	_ SMSCapacityReader = (*HiLinkClient)(nil)
+	_ SMSCapacityReader = (*ATClient)(nil)
+	_ SMSSendTracker    = (*Client)(nil)
+	_ USSDSender        = (*Client)(nil)
+	_ Phonebook         = (*Client)(nil)
+)
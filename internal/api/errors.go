@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors returned by the client. Use errors.Is to test for them.
+var (
+	ErrBadPassword        = errors.New("bad password")
+	ErrDuplicateUser      = errors.New("another user is already logged in")
+	ErrSessionExpired     = errors.New("session expired")
+	ErrUnsupported        = errors.New("not supported by this device")
+	ErrLockedOut          = errors.New("login locked after too many failed attempts")
+	ErrUnexpectedResponse = errors.New("unexpected response format")
+	ErrSIMLocked          = errors.New("SIM is locked with a PIN")
+	ErrMessageTooLong     = errors.New("message is too long")
+	ErrUSSDNoService      = errors.New("no network service for USSD")
+	ErrUSSDFailed         = errors.New("USSD request failed")
+)
+
+// DeviceError is returned when the device answers a goform command with a
+// result code other than success.
+type DeviceError struct {
+	Goform string
+	Result string
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Goform, e.Result)
+}
+
+// checkResult converts the result field of a set command response into an error
+func checkResult(goform string, resp map[string]interface{}) error {
+	result, ok := resp["result"].(string)
+	if !ok {
+		return fmt.Errorf("%s: %w", goform, ErrUnexpectedResponse)
+	}
+
+	if result == "0" || strings.EqualFold(result, "success") {
+		return nil
+	}
+
+	return &DeviceError{Goform: goform, Result: result}
+}
@@ -0,0 +1,445 @@
+package api
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// hilinkDateLayout is the timestamp format used in HiLink SMS documents
+const hilinkDateLayout = "2006-01-02 15:04:05"
+
+// hilinkNetworkTypes names the CurrentNetworkType codes
+var hilinkNetworkTypes = map[string]string{
+	"0":    "No Service",
+	"1":    "GSM",
+	"2":    "GPRS",
+	"3":    "EDGE",
+	"4":    "WCDMA",
+	"5":    "HSDPA",
+	"6":    "HSUPA",
+	"7":    "HSPA",
+	"9":    "HSPA+",
+	"19":   "LTE",
+	"101":  "LTE",
+	"1011": "LTE+",
+}
+
+type hilinkStatus struct {
+	ConnectionStatus   string `xml:"ConnectionStatus"`
+	SignalIcon         string `xml:"SignalIcon"`
+	CurrentNetworkType string `xml:"CurrentNetworkType"`
+	BatteryPercent     string `xml:"BatteryPercent"`
+	WanIPAddress       string `xml:"WanIPAddress"`
+	CurrentWifiUser    string `xml:"CurrentWifiUser"`
+}
+
+type hilinkTraffic struct {
+	CurrentUpload       string `xml:"CurrentUpload"`
+	CurrentDownload     string `xml:"CurrentDownload"`
+	CurrentUploadRate   string `xml:"CurrentUploadRate"`
+	CurrentDownloadRate string `xml:"CurrentDownloadRate"`
+}
+
+type hilinkInformation struct {
+	DeviceName      string `xml:"DeviceName"`
+	Imei            string `xml:"Imei"`
+	Iccid           string `xml:"Iccid"`
+	HardwareVersion string `xml:"HardwareVersion"`
+	SoftwareVersion string `xml:"SoftwareVersion"`
+}
+
+// GetDeviceStatusContext combines the monitoring status, traffic statistics
+// and device information documents
+func (c *HiLinkClient) GetDeviceStatusContext(ctx context.Context) (*DeviceStatus, error) {
+	var st hilinkStatus
+	if err := c.get(ctx, "/api/monitoring/status", &st); err != nil {
+		return nil, err
+	}
+
+	status := &DeviceStatus{
+		NetworkType:  st.CurrentNetworkType,
+		WanIPAddress: st.WanIPAddress,
+	}
+	if name, ok := hilinkNetworkTypes[st.CurrentNetworkType]; ok {
+		status.NetworkType = name
+	}
+	if i, err := strconv.Atoi(st.SignalIcon); err == nil {
+		status.SignalStrength = i
+	}
+	if i, err := strconv.Atoi(st.BatteryPercent); err == nil {
+		status.BatteryLevel = i
+	}
+	if i, err := strconv.Atoi(st.CurrentWifiUser); err == nil {
+		status.ConnectedDevs = i
+	}
+
+	var traffic hilinkTraffic
+	if err := c.get(ctx, "/api/monitoring/traffic-statistics", &traffic); err == nil {
+		if f, err := strconv.ParseFloat(traffic.CurrentUploadRate, 64); err == nil {
+			status.TxSpeed = f
+		}
+		if f, err := strconv.ParseFloat(traffic.CurrentDownloadRate, 64); err == nil {
+			status.RxSpeed = f
+		}
+		if u, err := strconv.ParseUint(traffic.CurrentUpload, 10, 64); err == nil {
+			status.TxBytes = u
+		}
+		if u, err := strconv.ParseUint(traffic.CurrentDownload, 10, 64); err == nil {
+			status.RxBytes = u
+		}
+	}
+
+	var info hilinkInformation
+	if err := c.get(ctx, "/api/device/information", &info); err == nil {
+		status.IMEI = info.Imei
+		status.ICCID = info.Iccid
+		status.ModelName = info.DeviceName
+		status.HardwareVersion = info.HardwareVersion
+		status.SoftwareVersion = info.SoftwareVersion
+	}
+
+	return status, nil
+}
+
+// hilinkBasicSettings and hilinkSecuritySettings are posted as <request>;
+// the router answers reads with <response>, decoded by the *Response types
+type hilinkBasicSettings struct {
+	XMLName     xml.Name `xml:"request"`
+	WifiSsid    string   `xml:"WifiSsid"`
+	WifiHide    string   `xml:"WifiHide"`
+	WifiChannel string   `xml:"WifiChannel,omitempty"`
+	WifiRestart string   `xml:"WifiRestart"`
+}
+
+type hilinkSecuritySettings struct {
+	XMLName                xml.Name `xml:"request"`
+	WifiAuthmode           string   `xml:"WifiAuthmode"`
+	WifiWpaencryptionmodes string   `xml:"WifiWpaencryptionmodes"`
+	WifiWpapsk             string   `xml:"WifiWpapsk"`
+	WifiRestart            string   `xml:"WifiRestart"`
+}
+
+type hilinkBasicSettingsResponse struct {
+	WifiSsid    string `xml:"WifiSsid"`
+	WifiHide    string `xml:"WifiHide"`
+	WifiChannel string `xml:"WifiChannel"`
+}
+
+type hilinkSecuritySettingsResponse struct {
+	WifiAuthmode string `xml:"WifiAuthmode"`
+	WifiWpapsk   string `xml:"WifiWpapsk"`
+}
+
+// GetWiFiConfigContext reads the basic and security WLAN settings
+func (c *HiLinkClient) GetWiFiConfigContext(ctx context.Context) (*WiFiConfig, error) {
+	var basic hilinkBasicSettingsResponse
+	if err := c.get(ctx, "/api/wlan/basic-settings", &basic); err != nil {
+		return nil, err
+	}
+
+	var security hilinkSecuritySettingsResponse
+	if err := c.get(ctx, "/api/wlan/security-settings", &security); err != nil {
+		return nil, err
+	}
+
+	config := &WiFiConfig{
+		SSID:         basic.WifiSsid,
+		Password:     security.WifiWpapsk,
+		SecurityMode: security.WifiAuthmode,
+		HideSSID:     basic.WifiHide == "1",
+	}
+	if i, err := strconv.Atoi(basic.WifiChannel); err == nil {
+		config.Channel = i
+	}
+
+	return config, nil
+}
+
+// SetWiFiConfigContext writes the basic settings, then the security settings.
+// MaxClients is not configurable through HiLink and is ignored.
+func (c *HiLinkClient) SetWiFiConfigContext(ctx context.Context, config *WiFiConfig) error {
+	basic := hilinkBasicSettings{
+		WifiSsid:    config.SSID,
+		WifiHide:    "0",
+		WifiRestart: "1",
+	}
+	if config.HideSSID {
+		basic.WifiHide = "1"
+	}
+	if config.Channel > 0 {
+		basic.WifiChannel = strconv.Itoa(config.Channel)
+	}
+
+	if err := c.post(ctx, "/api/wlan/basic-settings", basic, nil); err != nil {
+		return err
+	}
+
+	security := hilinkSecuritySettings{
+		WifiAuthmode:           config.SecurityMode,
+		WifiWpaencryptionmodes: "AES",
+		WifiWpapsk:             config.Password,
+		WifiRestart:            "1",
+	}
+
+	return c.post(ctx, "/api/wlan/security-settings", security, nil)
+}
+
+type hilinkSMSCount struct {
+	LocalInbox  string `xml:"LocalInbox"`
+	LocalOutbox string `xml:"LocalOutbox"`
+	LocalDraft  string `xml:"LocalDraft"`
+	LocalMax    string `xml:"LocalMax"`
+	SimInbox    string `xml:"SimInbox"`
+	SimOutbox   string `xml:"SimOutbox"`
+	SimDraft    string `xml:"SimDraft"`
+	SimMax      string `xml:"SimMax"`
+}
+
+// GetSMSCountContext returns the number of inbox messages on the device and SIM
+func (c *HiLinkClient) GetSMSCountContext(ctx context.Context) (int, error) {
+	var count hilinkSMSCount
+	if err := c.get(ctx, "/api/sms/sms-count", &count); err != nil {
+		return 0, err
+	}
+
+	local, _ := strconv.Atoi(count.LocalInbox)
+	sim, _ := strconv.Atoi(count.SimInbox)
+	return local + sim, nil
+}
+
+// GetSMSCapacityContext sums the boxes of each store from the SMS count
+func (c *HiLinkClient) GetSMSCapacityContext(ctx context.Context) (*SMSCapacity, error) {
+	var count hilinkSMSCount
+	if err := c.get(ctx, "/api/sms/sms-count", &count); err != nil {
+		return nil, err
+	}
+
+	sum := func(values ...string) int {
+		total := 0
+		for _, v := range values {
+			n, _ := strconv.Atoi(v)
+			total += n
+		}
+		return total
+	}
+
+	return &SMSCapacity{
+		DeviceUsed:  sum(count.LocalInbox, count.LocalOutbox, count.LocalDraft),
+		DeviceTotal: sum(count.LocalMax),
+		SIMUsed:     sum(count.SimInbox, count.SimOutbox, count.SimDraft),
+		SIMTotal:    sum(count.SimMax),
+	}, nil
+}
+
+type hilinkSMSListRequest struct {
+	XMLName         xml.Name `xml:"request"`
+	PageIndex       int      `xml:"PageIndex"`
+	ReadCount       int      `xml:"ReadCount"`
+	BoxType         int      `xml:"BoxType"`
+	SortType        int      `xml:"SortType"`
+	Ascending       int      `xml:"Ascending"`
+	UnreadPreferred int      `xml:"UnreadPreferred"`
+}
+
+type hilinkSMSList struct {
+	Count    string `xml:"Count"`
+	Messages []struct {
+		Smstat  string `xml:"Smstat"`
+		Index   string `xml:"Index"`
+		Phone   string `xml:"Phone"`
+		Content string `xml:"Content"`
+		Date    string `xml:"Date"`
+	} `xml:"Messages>Message"`
+}
+
+// HiLink message boxes in device memory
+const (
+	hilinkBoxInbox = 1
+	hilinkBoxSent  = 2
+	hilinkBoxDraft = 3
+)
+
+// hilinkBoxes lists the boxes GetSMSListContext reads, with the status and
+// type their messages get
+var hilinkBoxes = []struct {
+	box    int
+	status SMSStatus
+	typ    string
+}{
+	{hilinkBoxInbox, SMSRead, "inbox"},
+	{hilinkBoxSent, SMSSent, "sent"},
+	{hilinkBoxDraft, SMSDraft, "draft"},
+}
+
+// GetSMSListContext returns one page of the inbox, sent and draft boxes,
+// newest first. The router pages each box separately, so a page holds up to
+// pageSize messages of every box. Pages are numbered from 0 as on the ZTE
+// client.
+func (c *HiLinkClient) GetSMSListContext(ctx context.Context, page, pageSize int) ([]SMSMessage, error) {
+	var messages []SMSMessage
+	for _, b := range hilinkBoxes {
+		request := hilinkSMSListRequest{
+			PageIndex: page + 1,
+			ReadCount: pageSize,
+			BoxType:   b.box,
+		}
+
+		var list hilinkSMSList
+		if err := c.post(ctx, "/api/sms/sms-list", request, &list); err != nil {
+			return nil, fmt.Errorf("%s box: %w", b.typ, err)
+		}
+
+		for _, m := range list.Messages {
+			sms := SMSMessage{
+				ID:      m.Index,
+				Number:  m.Phone,
+				Content: m.Content,
+				Status:  b.status,
+				Type:    b.typ,
+			}
+
+			// Smstat 0 is unread
+			if b.box == hilinkBoxInbox && m.Smstat == "0" {
+				sms.Status = SMSUnread
+			}
+
+			if t, err := time.ParseInLocation(hilinkDateLayout, m.Date, time.Local); err == nil {
+				sms.Timestamp = t
+			}
+
+			messages = append(messages, sms)
+		}
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.After(messages[j].Timestamp)
+	})
+	return messages, nil
+}
+
+type hilinkSendSMS struct {
+	XMLName  xml.Name `xml:"request"`
+	Index    int      `xml:"Index"`
+	Phones   []string `xml:"Phones>Phone"`
+	Sca      string   `xml:"Sca"`
+	Content  string   `xml:"Content"`
+	Length   int      `xml:"Length"`
+	Reserved int      `xml:"Reserved"`
+	Date     string   `xml:"Date"`
+}
+
+// SendSMSContext sends content to phoneNumber. The router picks the
+// encoding itself; the returned info is the expected result.
+func (c *HiLinkClient) SendSMSContext(ctx context.Context, phoneNumber, content string) (SMSInfo, error) {
+	info, err := checkSMSLength(content)
+	if err != nil {
+		return info, err
+	}
+
+	request := hilinkSendSMS{
+		Index:    -1,
+		Phones:   []string{phoneNumber},
+		Content:  content,
+		Length:   len([]rune(content)),
+		Reserved: 1,
+		Date:     time.Now().Format(hilinkDateLayout),
+	}
+
+	return info, c.post(ctx, "/api/sms/send-sms", request, nil)
+}
+
+// DeleteSMSContext deletes the messages with the given indexes
+func (c *HiLinkClient) DeleteSMSContext(ctx context.Context, messageIDs []string) error {
+	request := struct {
+		XMLName xml.Name `xml:"request"`
+		Index   []string `xml:"Index"`
+	}{Index: messageIDs}
+
+	return c.post(ctx, "/api/sms/delete-sms", request, nil)
+}
+
+// MarkSMSReadContext marks messages read. HiLink cannot mark them unread.
+func (c *HiLinkClient) MarkSMSReadContext(ctx context.Context, messageIDs []string, read bool) error {
+	if !read {
+		return fmt.Errorf("mark unread: %w", ErrUnsupported)
+	}
+
+	for _, id := range messageIDs {
+		request := struct {
+			XMLName xml.Name `xml:"request"`
+			Index   string   `xml:"Index"`
+		}{Index: id}
+
+		if err := c.post(ctx, "/api/sms/set-read", request, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type hilinkHostList struct {
+	Hosts []struct {
+		MacAddress     string `xml:"MacAddress"`
+		IpAddress      string `xml:"IpAddress"`
+		HostName       string `xml:"HostName"`
+		AssociatedTime string `xml:"AssociatedTime"`
+	} `xml:"Hosts>Host"`
+}
+
+// GetConnectedDevicesContext lists the WLAN clients
+func (c *HiLinkClient) GetConnectedDevicesContext(ctx context.Context) ([]ConnectedDevice, error) {
+	var hosts hilinkHostList
+	if err := c.get(ctx, "/api/wlan/host-list", &hosts); err != nil {
+		return nil, err
+	}
+
+	devices := []ConnectedDevice{}
+	now := time.Now()
+	for _, h := range hosts.Hosts {
+		device := ConnectedDevice{
+			Hostname:   h.HostName,
+			IPAddress:  h.IpAddress,
+			MACAddress: h.MacAddress,
+		}
+		if secs, err := strconv.Atoi(h.AssociatedTime); err == nil {
+			device.ConnectedTime = now.Add(-time.Duration(secs) * time.Second)
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+type hilinkAction struct {
+	XMLName xml.Name `xml:"request"`
+	Action  int      `xml:"Action"`
+}
+
+// ConnectNetworkContext starts the mobile data connection
+func (c *HiLinkClient) ConnectNetworkContext(ctx context.Context) error {
+	return c.post(ctx, "/api/dialup/dial", hilinkAction{Action: 1}, nil)
+}
+
+// DisconnectNetworkContext stops the mobile data connection
+func (c *HiLinkClient) DisconnectNetworkContext(ctx context.Context) error {
+	return c.post(ctx, "/api/dialup/dial", hilinkAction{Action: 0}, nil)
+}
+
+type hilinkControl struct {
+	XMLName xml.Name `xml:"request"`
+	Control int      `xml:"Control"`
+}
+
+// RebootDeviceContext restarts the router
+func (c *HiLinkClient) RebootDeviceContext(ctx context.Context) error {
+	return c.post(ctx, "/api/device/control", hilinkControl{Control: 1}, nil)
+}
+
+// ShutdownDeviceContext powers the router off
+func (c *HiLinkClient) ShutdownDeviceContext(ctx context.Context) error {
+	return c.post(ctx, "/api/device/control", hilinkControl{Control: 4}, nil)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// loginScheme is one of the password encodings used by ZTE firmware
+type loginScheme interface {
+	name() string
+	login(ctx context.Context, c *Client, username, password string) (map[string]interface{}, error)
+}
+
+// loginSchemesFor returns the schemes to try, in order, for a device that
+// reported info. The previously successful scheme, if any, is tried first.
+func loginSchemesFor(info *LoginInfo, preferred loginScheme) []loginScheme {
+	var schemes []loginScheme
+	if info.LD != "" {
+		schemes = []loginScheme{saltedLogin{}, multiUserLogin{}}
+	} else {
+		schemes = []loginScheme{base64Login{}}
+	}
+
+	if preferred == nil {
+		return schemes
+	}
+
+	ordered := []loginScheme{preferred}
+	for _, s := range schemes {
+		if s.name() != preferred.name() {
+			ordered = append(ordered, s)
+		}
+	}
+	return ordered
+}
+
+// base64Login is the original LOGIN goform used by the MF927U and older units
+type base64Login struct{}
+
+func (base64Login) name() string { return "base64" }
+
+func (base64Login) login(ctx context.Context, c *Client, username, password string) (map[string]interface{}, error) {
+	data := map[string]string{
+		"isTest":   "false",
+		"goformId": "LOGIN",
+		"password": base64.StdEncoding.EncodeToString([]byte(password)),
+	}
+	return c.post(ctx, LoginEndpoint, data)
+}
+
+// saltedLogin sends SHA256(SHA256(pw)+LD) with the LOGIN goform
+type saltedLogin struct{}
+
+func (saltedLogin) name() string { return "sha256" }
+
+func (saltedLogin) login(ctx context.Context, c *Client, username, password string) (map[string]interface{}, error) {
+	hashed, err := c.saltedPassword(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{
+		"isTest":   "false",
+		"goformId": "LOGIN",
+		"password": hashed,
+	}
+	return c.post(ctx, LoginEndpoint, data)
+}
+
+// multiUserLogin is the salted scheme for firmware that also takes a username
+type multiUserLogin struct{}
+
+func (multiUserLogin) name() string { return "multi-user" }
+
+func (multiUserLogin) login(ctx context.Context, c *Client, username, password string) (map[string]interface{}, error) {
+	hashed, err := c.saltedPassword(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{
+		"isTest":   "false",
+		"goformId": "LOGIN_MULTI_USER",
+		"user":     username,
+		"password": hashed,
+	}
+	return c.post(ctx, LoginEndpoint, data)
+}
+
+// saltedPassword fetches a fresh LD salt and hashes password with it. The
+// device compares upper-case hex digests.
+func (c *Client) saltedPassword(ctx context.Context, password string) (string, error) {
+	resp, err := c.get(ctx, StatusEndpoint, map[string]string{
+		"cmd":    "LD",
+		"isTest": "false",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read LD salt: %w", err)
+	}
+
+	ld, ok := resp["LD"].(string)
+	if !ok || ld == "" {
+		return "", fmt.Errorf("device returned no LD salt")
+	}
+
+	return sha256Hex(sha256Hex(password) + ld), nil
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -1,10 +1,14 @@
 package api
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // DeviceStatus represents the current status of the MiFi device
 type DeviceStatus struct {
 	NetworkType     string  `json:"network_type"`
+	NetworkProvider string  `json:"network_provider"`
 	SignalStrength  int     `json:"signalbar"`
 	BatteryLevel    int     `json:"battery_value"`
 	WanIPAddress    string  `json:"wan_ipaddr"`
@@ -36,8 +40,177 @@ type SMSMessage struct {
 	Number    string    `json:"number"`
 	Content   string    `json:"content"`
 	Timestamp time.Time `json:"date"`
-	Status    string    `json:"status"` // read/unread
-	Type      string    `json:"type"`   // inbox/sent
+	Status    SMSStatus `json:"status"`
+	Type      string    `json:"type"` // inbox/sent
+	Store     SMSStore  `json:"store"`
+
+	// Concatenated message parts reported separately by the device share
+	// a reference; StitchConcat joins them
+	ConcatRef   string   `json:"concat_ref,omitempty"`
+	ConcatPart  int      `json:"concat_part,omitempty"`
+	ConcatTotal int      `json:"concat_total,omitempty"`
+	PartIDs     []string `json:"part_ids,omitempty"`
+}
+
+// SMSStatus is the read state of a message, mapped from the device's tag
+type SMSStatus int
+
+const (
+	SMSRead SMSStatus = iota
+	SMSUnread
+	SMSSent
+	SMSDraft
+)
+
+func (s SMSStatus) String() string {
+	switch s {
+	case SMSRead:
+		return "read"
+	case SMSUnread:
+		return "unread"
+	case SMSSent:
+		return "sent"
+	case SMSDraft:
+		return "draft"
+	}
+	return "unknown"
+}
+
+// MarshalText stores the status by name, as String returns it
+func (s SMSStatus) MarshalText() ([]byte, error) {
+	if s < SMSRead || s > SMSDraft {
+		return nil, fmt.Errorf("invalid SMS status %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText reads a status name written by MarshalText
+func (s *SMSStatus) UnmarshalText(text []byte) error {
+	for status := SMSRead; status <= SMSDraft; status++ {
+		if string(text) == status.String() {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown SMS status %q", text)
+}
+
+// SMSStore is the memory a message or phonebook contact is kept in
+type SMSStore int
+
+const (
+	SMSStoreDevice SMSStore = iota
+	SMSStoreSIM
+)
+
+func (s SMSStore) String() string {
+	if s == SMSStoreSIM {
+		return "sim"
+	}
+	return "device"
+}
+
+// SMSSendStatus is the outcome of the last send as reported by the device,
+// which may fail a message after accepting it
+type SMSSendStatus int
+
+const (
+	SMSSendUnknown SMSSendStatus = iota
+	SMSSendProcessing
+	SMSSendFailed
+	SMSSendSucceeded
+)
+
+func (s SMSSendStatus) String() string {
+	switch s {
+	case SMSSendProcessing:
+		return "processing"
+	case SMSSendFailed:
+		return "failed"
+	case SMSSendSucceeded:
+		return "succeeded"
+	}
+	return "unknown"
+}
+
+// SMSCapacity is how many messages each store holds and can hold
+type SMSCapacity struct {
+	DeviceUsed  int `json:"device_used"`
+	DeviceTotal int `json:"device_total"`
+	SIMUsed     int `json:"sim_used"`
+	SIMTotal    int `json:"sim_total"`
+}
+
+// Used returns the number of messages in store
+func (c *SMSCapacity) Used(store SMSStore) int {
+	if store == SMSStoreSIM {
+		return c.SIMUsed
+	}
+	return c.DeviceUsed
+}
+
+// Total returns the number of messages store can hold
+func (c *SMSCapacity) Total(store SMSStore) int {
+	if store == SMSStoreSIM {
+		return c.SIMTotal
+	}
+	return c.DeviceTotal
+}
+
+// Percent returns how full store is, or 0 if it holds nothing
+func (c *SMSCapacity) Percent(store SMSStore) int {
+	total := c.Total(store)
+	if total <= 0 {
+		return 0
+	}
+	return c.Used(store) * 100 / total
+}
+
+// USSDAction is what the network expects after answering a USSD request
+type USSDAction int
+
+const (
+	// USSDDone means no further action; the session is over
+	USSDDone USSDAction = iota
+	// USSDReplyExpected means the answer is a menu waiting for a reply
+	USSDReplyExpected
+	// USSDTerminated means the network ended the session
+	USSDTerminated
+)
+
+// USSDResponse is the network's answer to a USSD code or reply
+type USSDResponse struct {
+	Text   string     `json:"text"`
+	Action USSDAction `json:"action"`
+}
+
+// ExpectsReply reports whether the session is still open for a reply
+func (r *USSDResponse) ExpectsReply() bool {
+	return r.Action == USSDReplyExpected
+}
+
+// Contact is a phonebook entry kept on the SIM or in device memory. SIM
+// entries hold only a name and a mobile number.
+type Contact struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Number       string   `json:"number"`
+	HomeNumber   string   `json:"home_number,omitempty"`
+	OfficeNumber string   `json:"office_number,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Group        string   `json:"group,omitempty"`
+	Store        SMSStore `json:"store"`
+}
+
+// Numbers returns every number of the contact
+func (c *Contact) Numbers() []string {
+	var numbers []string
+	for _, n := range []string{c.Number, c.HomeNumber, c.OfficeNumber} {
+		if n != "" {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
 }
 
 // ConnectedDevice represents a device connected to the MiFi
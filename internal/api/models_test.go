@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSMSStatusJSON(t *testing.T) {
+	for _, status := range []SMSStatus{SMSRead, SMSUnread, SMSSent, SMSDraft} {
+		data, err := json.Marshal(SMSMessage{ID: "1", Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `"status":"` + status.String() + `"`; !strings.Contains(string(data), want) {
+			t.Errorf("%v encodes as %s, want %s", status, data, want)
+		}
+
+		var m SMSMessage
+		if err := json.Unmarshal(data, &m); err != nil || m.Status != status {
+			t.Errorf("%s decodes as %v, %v", data, m.Status, err)
+		}
+	}
+
+	var m SMSMessage
+	for _, bad := range []string{`{"status":"archived"}`, `{"status":2}`, `{"status":true}`} {
+		if err := json.Unmarshal([]byte(bad), &m); err == nil {
+			t.Errorf("%s decoded without an error", bad)
+		}
+	}
+	if _, err := json.Marshal(SMSMessage{Status: SMSStatus(7)}); err == nil {
+		t.Error("invalid status encoded without an error")
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// contactsPageSize is how many contacts one pbm_data_info page holds
+	contactsPageSize = 100
+
+	// maxContactPages bounds paging should the device repeat a page forever
+	maxContactPages = 50
+)
+
+// pbm_location values in pbm_data_info
+const (
+	pbmLocationSIM    = "pbm_sim"
+	pbmLocationDevice = "pbm_native"
+)
+
+// GetContacts returns every phonebook contact on the SIM and in device
+// memory, ordered by name
+func (c *Client) GetContacts() ([]Contact, error) {
+	return c.GetContactsContext(context.Background())
+}
+
+// GetContactsContext is like GetContacts but uses ctx for cancellation.
+func (c *Client) GetContactsContext(ctx context.Context) ([]Contact, error) {
+	var contacts []Contact
+
+	for page := 0; page < maxContactPages; page++ {
+		params := map[string]string{
+			"cmd":           "pbm_data_info",
+			"page":          strconv.Itoa(page),
+			"data_per_page": strconv.Itoa(contactsPageSize),
+			"orderBy":       "name",
+			"isAsc":         "true",
+			"isTest":        "false",
+		}
+
+		resp, err := c.GetContext(ctx, StatusEndpoint, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read contacts: %w", err)
+		}
+
+		entries, _ := resp["pbm_data"].([]interface{})
+		for _, entry := range entries {
+			if m, ok := entry.(map[string]interface{}); ok {
+				contacts = append(contacts, parseContact(m))
+			}
+		}
+
+		if len(entries) < contactsPageSize {
+			break
+		}
+	}
+
+	return contacts, nil
+}
+
+// parseContact reads one pbm_data entry. Names arrive as UCS-2 hex.
+func parseContact(m map[string]interface{}) Contact {
+	contact := Contact{
+		ID:           firstString(m, "pbm_id"),
+		Number:       firstString(m, "pbm_number"),
+		HomeNumber:   firstString(m, "pbm_anr"),
+		OfficeNumber: firstString(m, "pbm_anr1"),
+		Email:        firstString(m, "pbm_email"),
+		Group:        firstString(m, "pbm_group"),
+	}
+
+	// Names always arrive as UCS-2 hex
+	name := firstString(m, "pbm_name")
+	if decoded, ok := decodeUCS2Hex(name); ok {
+		name = decoded
+	}
+	contact.Name = name
+
+	if firstString(m, "pbm_location") == pbmLocationSIM {
+		contact.Store = SMSStoreSIM
+	}
+	return contact
+}
+
+// AddContact saves a new contact in contact.Store. The device assigns the
+// ID, so reload the contacts to learn it.
+func (c *Client) AddContact(contact *Contact) error {
+	return c.AddContactContext(context.Background(), contact)
+}
+
+// AddContactContext is like AddContact but uses ctx for cancellation.
+func (c *Client) AddContactContext(ctx context.Context, contact *Contact) error {
+	return c.saveContact(ctx, contact, "-1")
+}
+
+// UpdateContact replaces the contact with contact.ID. A contact cannot move
+// between the SIM and device memory.
+func (c *Client) UpdateContact(contact *Contact) error {
+	return c.UpdateContactContext(context.Background(), contact)
+}
+
+// UpdateContactContext is like UpdateContact but uses ctx for cancellation.
+func (c *Client) UpdateContactContext(ctx context.Context, contact *Contact) error {
+	if contact.ID == "" {
+		return errors.New("the contact has no ID")
+	}
+	return c.saveContact(ctx, contact, contact.ID)
+}
+
+// saveContact adds (editIndex -1) or replaces a contact with PBM_CONTACT_ADD
+func (c *Client) saveContact(ctx context.Context, contact *Contact, editIndex string) error {
+	if strings.TrimSpace(contact.Name) == "" || strings.TrimSpace(contact.Number) == "" {
+		return errors.New("a contact needs a name and a number")
+	}
+
+	// location 0 is the SIM and 1 the device memory
+	data := map[string]string{
+		"goformId":        "PBM_CONTACT_ADD",
+		"notCallback":     "true",
+		"location":        "1",
+		"name":            encodeUCS2Hex(contact.Name),
+		"mobilephone_num": contact.Number,
+		"edit_index":      editIndex,
+		"isTest":          "false",
+	}
+	if contact.Store == SMSStoreSIM {
+		data["location"] = "0"
+	} else {
+		data["homephone_num"] = contact.HomeNumber
+		data["officephone_num"] = contact.OfficeNumber
+		data["email"] = contact.Email
+		data["groupchoose"] = contact.Group
+	}
+
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
+	if err != nil {
+		return err
+	}
+
+	return checkResult(data["goformId"], resp)
+}
+
+// DeleteContacts removes the contacts with the given IDs
+func (c *Client) DeleteContacts(ids []string) error {
+	return c.DeleteContactsContext(context.Background(), ids)
+}
+
+// DeleteContactsContext is like DeleteContacts but uses ctx for cancellation.
+func (c *Client) DeleteContactsContext(ctx context.Context, ids []string) error {
+	data := map[string]string{
+		"goformId":    "PBM_CONTACT_DEL",
+		"notCallback": "true",
+		"del_option":  "delete_num",
+		"delete_id":   strings.Join(ids, ",") + ",",
+		"isTest":      "false",
+	}
+
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
+	if err != nil {
+		return err
+	}
+
+	return checkResult(data["goformId"], resp)
+}
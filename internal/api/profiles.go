@@ -0,0 +1,295 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DeviceProfile maps the fields the client needs onto the cmd and parameter
+// names used by one firmware family
+type DeviceProfile struct {
+	Name string
+
+	// Models are model_name values, matched case-insensitively
+	Models []string
+	// CRVersions are cr_version prefixes
+	CRVersions []string
+
+	// Get cmd names for the WiFi configuration
+	SSIDKey       string
+	PasswordKey   string
+	SecurityKey   string
+	HideSSIDKey   string
+	ChannelKey    string
+	MaxClientsKey string
+
+	// PasswordBase64 is set when the firmware stores and expects the WiFi
+	// passphrase base64-encoded
+	PasswordBase64 bool
+
+	// Set goform and parameter names for the WiFi configuration. An empty
+	// parameter name means the firmware cannot change that setting here.
+	WiFiGoform      string
+	SSIDParam       string
+	PasswordParam   string
+	SecurityParam   string
+	HideSSIDParam   string
+	ChannelParam    string
+	MaxClientsParam string
+
+	// StationListKeys are queried in turn and their entries combined
+	StationListKeys []string
+}
+
+// DefaultProfile matches the MF927U and other firmware using the original
+// web UI field names
+var DefaultProfile = DeviceProfile{
+	Name:   "zte-classic",
+	Models: []string{"MF927U"},
+
+	SSIDKey:       "wifi_ssid",
+	PasswordKey:   "wifi_password",
+	SecurityKey:   "security_mode",
+	HideSSIDKey:   "hide_ssid",
+	ChannelKey:    "wifi_channel",
+	MaxClientsKey: "max_client_num",
+
+	WiFiGoform:      "SET_WIFI_SSID_PASSWORD",
+	SSIDParam:       "wifi_ssid",
+	PasswordParam:   "wifi_password",
+	SecurityParam:   "security_mode",
+	HideSSIDParam:   "hide_ssid",
+	ChannelParam:    "wifi_channel",
+	MaxClientsParam: "max_client_num",
+
+	StationListKeys: []string{"station_list"},
+}
+
+// SSID1Profile matches newer firmware (MF971, MF79U, MC801) that names the
+// primary network SSID1 and reports LAN clients separately
+var SSID1Profile = DeviceProfile{
+	Name:   "zte-ssid1",
+	Models: []string{"MF971", "MF971R", "MF79U", "MF79S", "MC801", "MC801A"},
+
+	SSIDKey:        "SSID1",
+	PasswordKey:    "WPAPSK1",
+	SecurityKey:    "AuthMode",
+	HideSSIDKey:    "HideSSID",
+	ChannelKey:     "Channel",
+	MaxClientsKey:  "MAX_Access_num",
+	PasswordBase64: true,
+
+	WiFiGoform:      "SET_WIFI_SSID1_SETTINGS",
+	SSIDParam:       "ssid",
+	PasswordParam:   "passphrase",
+	SecurityParam:   "security_mode",
+	HideSSIDParam:   "broadcastSsidEnabled",
+	MaxClientsParam: "MAX_Access_num",
+
+	StationListKeys: []string{"station_list", "lan_station_list"},
+}
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = []DeviceProfile{DefaultProfile, SSID1Profile}
+)
+
+// RegisterProfile adds a profile to the registry. Profiles registered later
+// take precedence over built-in ones.
+func RegisterProfile(p DeviceProfile) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	profiles = append([]DeviceProfile{p}, profiles...)
+}
+
+// isProfilePasswordKey reports whether a registered profile reads or posts
+// the WiFi password under name
+func isProfilePasswordKey(name string) bool {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+
+	for _, p := range profiles {
+		if name != "" && (name == p.PasswordKey || name == p.PasswordParam) {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupProfile finds the registered profile for a model name or cr_version
+func LookupProfile(modelName, crVersion string) (DeviceProfile, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+
+	for _, p := range profiles {
+		for _, m := range p.Models {
+			if modelName != "" && strings.EqualFold(m, modelName) {
+				return p, true
+			}
+		}
+		for _, v := range p.CRVersions {
+			if crVersion != "" && strings.HasPrefix(crVersion, v) {
+				return p, true
+			}
+		}
+	}
+
+	return DeviceProfile{}, false
+}
+
+// Capabilities records which candidate cmd names the attached device answered
+type Capabilities struct {
+	ModelName string
+	CRVersion string
+	Available map[string]bool
+}
+
+// Has reports whether the device returned data for cmd
+func (caps *Capabilities) Has(cmd string) bool {
+	return caps.Available[cmd]
+}
+
+// candidateFields are the alternative names probed for each WiFi setting
+var candidateFields = []string{
+	"wifi_ssid", "SSID1",
+	"wifi_password", "WPAPSK1",
+	"security_mode", "AuthMode",
+	"hide_ssid", "HideSSID",
+	"wifi_channel", "Channel",
+	"max_client_num", "MAX_Access_num",
+}
+
+// candidateLists are the alternative names probed for the station list
+var candidateLists = []string{"station_list", "lan_station_list"}
+
+// ProbeCapabilities asks for every candidate cmd name and records which ones
+// return data. The device must be logged in for the answers to be meaningful.
+func (c *Client) ProbeCapabilities() (*Capabilities, error) {
+	return c.ProbeCapabilitiesContext(context.Background())
+}
+
+// ProbeCapabilitiesContext is like ProbeCapabilities but uses ctx for cancellation.
+func (c *Client) ProbeCapabilitiesContext(ctx context.Context) (*Capabilities, error) {
+	params := map[string]string{
+		"cmd":        "model_name,cr_version," + strings.Join(candidateFields, ","),
+		"multi_data": "1",
+		"isTest":     "false",
+	}
+
+	resp, err := c.GetContext(ctx, StatusEndpoint, params)
+	if err != nil {
+		return nil, fmt.Errorf("capability probe failed: %w", err)
+	}
+
+	caps := &Capabilities{Available: make(map[string]bool)}
+	caps.ModelName, _ = resp["model_name"].(string)
+	caps.CRVersion, _ = resp["cr_version"].(string)
+
+	for _, key := range candidateFields {
+		if val, ok := resp[key].(string); ok && val != "" {
+			caps.Available[key] = true
+		}
+	}
+
+	for _, key := range candidateLists {
+		resp, err := c.GetContext(ctx, StatusEndpoint, map[string]string{"cmd": key})
+		if err != nil {
+			return nil, fmt.Errorf("capability probe failed: %w", err)
+		}
+		if _, ok := resp[key].([]interface{}); ok {
+			caps.Available[key] = true
+		}
+	}
+
+	return caps, nil
+}
+
+// profileFromCapabilities picks the registered profile for the probed model,
+// or assembles one from whichever candidate names the device answered
+func profileFromCapabilities(caps *Capabilities) DeviceProfile {
+	if p, ok := LookupProfile(caps.ModelName, caps.CRVersion); ok {
+		return p
+	}
+
+	if caps.Has("SSID1") && !caps.Has("wifi_ssid") {
+		p := SSID1Profile
+		p.Name = "zte-ssid1-probed"
+		p.StationListKeys = probedStationLists(caps, p.StationListKeys)
+		return p
+	}
+
+	p := DefaultProfile
+	p.Name = "zte-classic-probed"
+	p.StationListKeys = probedStationLists(caps, p.StationListKeys)
+	return p
+}
+
+func probedStationLists(caps *Capabilities, fallback []string) []string {
+	var keys []string
+	for _, key := range candidateLists {
+		if caps.Has(key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return fallback
+	}
+	return keys
+}
+
+// SetProfile forces the profile used for this device, skipping the probe
+func (c *Client) SetProfile(p DeviceProfile) {
+	c.profileMu.Lock()
+	defer c.profileMu.Unlock()
+	c.profile = &p
+}
+
+// Profile returns the profile in use, probing the device the first time.
+// Until a probe succeeds DefaultProfile is used.
+func (c *Client) Profile(ctx context.Context) DeviceProfile {
+	c.profileMu.Lock()
+	defer c.profileMu.Unlock()
+
+	if c.profile != nil {
+		return *c.profile
+	}
+
+	caps, err := c.ProbeCapabilitiesContext(ctx)
+	if err != nil {
+		c.Logger.Debugf("Capability probe failed, using default profile: %v", err)
+		return DefaultProfile
+	}
+
+	// A logged-out probe answers nothing; try again later
+	if caps.ModelName == "" && len(caps.Available) == 0 {
+		return DefaultProfile
+	}
+
+	p := profileFromCapabilities(caps)
+	c.Logger.Infof("Using device profile %s for model %q", p.Name, caps.ModelName)
+	c.profile = &p
+
+	return p
+}
+
+// encodeWiFiPassword and decodeWiFiPassword apply the profile's passphrase encoding
+func (p DeviceProfile) encodeWiFiPassword(password string) string {
+	if p.PasswordBase64 {
+		return base64.StdEncoding.EncodeToString([]byte(password))
+	}
+	return password
+}
+
+func (p DeviceProfile) decodeWiFiPassword(password string) string {
+	if !p.PasswordBase64 {
+		return password
+	}
+	decoded, err := base64.StdEncoding.DecodeString(password)
+	if err != nil {
+		return password
+	}
+	return string(decoded)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// cbaud masks the speed bits of c_cflag; syscall does not export it
+const cbaud = 0x100f
+
+// openSerial opens a tty and puts it in raw 115200 8N1 mode so AT responses
+// are not echoed or translated by the line discipline
+func openSerial(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var t syscall.Termios
+	if err := termiosIoctl(f, syscall.TCGETS, &t); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read line settings: %w", err)
+	}
+
+	// cfmakeraw
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB | cbaud
+	t.Cflag |= syscall.CS8 | syscall.CREAD | syscall.CLOCAL | syscall.B115200
+	t.Ispeed = syscall.B115200
+	t.Ospeed = syscall.B115200
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+
+	if err := termiosIoctl(f, syscall.TCSETS, &t); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to set line settings: %w", err)
+	}
+
+	return f, nil
+}
+
+func termiosIoctl(f *os.File, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
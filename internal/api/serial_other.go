@@ -0,0 +1,11 @@
+//go:build !linux
+
+package api
+
+import "os"
+
+// openSerial opens a tty as is. Line settings are not changed on this
+// platform, so the port must already be in raw mode.
+func openSerial(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
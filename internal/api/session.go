@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"fmt"
+)
+
+// sessionGeneration returns a counter that increases on every successful
+// login. Callers record it before a request so that concurrent requests
+// which all notice the same expired session only log in once.
+func (c *Client) sessionGeneration() uint64 {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	return c.sessionGen
+}
+
+// publicFields are readable without logging in, so they say nothing about
+// whether the session is still alive
+var publicFields = map[string]bool{
+	"loginfo":          true,
+	"modem_main_state": true,
+	"LD":               true,
+	"RD":               true,
+	"wa_inner_version": true,
+	"cr_version":       true,
+	"login_lock_time":  true,
+	"psw_fail_num_str": true,
+	"Language":         true,
+}
+
+// looksLoggedOut reports whether a get response has the shape the device
+// returns for an unauthenticated session: every protected field blank.
+func looksLoggedOut(resp map[string]interface{}) bool {
+	protected := 0
+	for k, v := range resp {
+		if publicFields[k] {
+			continue
+		}
+		if s, ok := v.(string); !ok || s != "" {
+			return false
+		}
+		protected++
+	}
+	return protected > 0
+}
+
+// sessionExpired asks the device whether the current session is still
+// logged in. It only reports expiry for firmware that has been seen to
+// answer loginfo, and only while credentials are stored.
+func (c *Client) sessionExpired(ctx context.Context) (bool, error) {
+	c.authMu.Lock()
+	supported := c.loginfoSupported
+	loggedIn := c.password != ""
+	c.authMu.Unlock()
+
+	if !supported || !loggedIn {
+		return false, nil
+	}
+
+	loginfo, err := c.loginfo(ctx)
+	if err != nil {
+		return false, err
+	}
+	return loginfo != "ok", nil
+}
+
+// loginfo returns the device's loginfo field, "ok" while logged in
+func (c *Client) loginfo(ctx context.Context) (string, error) {
+	resp, err := c.get(ctx, StatusEndpoint, map[string]string{
+		"cmd":        "loginfo",
+		"multi_data": "1",
+		"isTest":     "false",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	loginfo, _ := resp["loginfo"].(string)
+	return loginfo, nil
+}
+
+// relogin logs in again with the stored credentials unless another caller
+// already did so since gen was read.
+func (c *Client) relogin(ctx context.Context, gen uint64) error {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.sessionGen != gen {
+		return nil
+	}
+
+	if c.password == "" {
+		return ErrSessionExpired
+	}
+
+	c.Logger.Info("Session expired, logging in again")
+	if err := c.login(ctx, c.username, c.password); err != nil {
+		return fmt.Errorf("%w: re-login failed: %w", ErrSessionExpired, err)
+	}
+
+	return nil
+}
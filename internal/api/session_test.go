@@ -0,0 +1,55 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"mifi_app/internal/mifisim"
+)
+
+func TestLooksLoggedOut(t *testing.T) {
+	tests := []struct {
+		name string
+		resp map[string]interface{}
+		want bool
+	}{
+		{"protected fields blank", map[string]interface{}{"network_type": "", "signalbar": ""}, true},
+		{"public fields answered", map[string]interface{}{"modem_main_state": "modem_init_complete", "network_type": "", "pin_status": ""}, true},
+		{"pin status answered", map[string]interface{}{"pin_status": "0", "network_type": ""}, false},
+		{"logged in", map[string]interface{}{"modem_main_state": "modem_init_complete", "network_type": "LTE"}, false},
+		{"only public fields", map[string]interface{}{"loginfo": "", "LD": ""}, false},
+		{"lists are not blank", map[string]interface{}{"station_list": []interface{}{}}, false},
+		{"empty", map[string]interface{}{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := looksLoggedOut(tt.resp); got != tt.want {
+			t.Errorf("%s: looksLoggedOut = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpiredSessionLogsInAgain(t *testing.T) {
+	sim := mifisim.New(mifisim.Options{})
+	url, err := sim.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+
+	client := NewClient(url, logrus.New())
+	if err := client.Login("admin", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	sim.ExpireSessions()
+
+	status, err := client.GetDeviceStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.NetworkType != sim.Device().NetworkType {
+		t.Errorf("network type after the session expired = %q, want %q", status.NetworkType, sim.Device().NetworkType)
+	}
+}
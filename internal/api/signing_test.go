@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"mifi_app/internal/mifisim"
+)
+
+func TestVersionProbedOncePerLogin(t *testing.T) {
+	tests := []struct {
+		name   string
+		modern bool
+	}{
+		{"unsigned firmware", false},
+		{"signed firmware", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := mifisim.New(mifisim.Options{Modern: tt.modern})
+			url, err := sim.Start("127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sim.Close()
+
+			var probes atomic.Int32
+			client := NewClient(url, logrus.New())
+			client.HTTPClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				if strings.Contains(req.URL.Query().Get("cmd"), "wa_inner_version") {
+					probes.Add(1)
+				}
+				return http.DefaultTransport.RoundTrip(req)
+			})
+
+			ctx := context.Background()
+			deleteTwice := func() {
+				for i := 0; i < 2; i++ {
+					if err := client.DeleteSMSContext(ctx, []string{"1"}); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			if err := client.LoginContext(ctx, "admin", "admin"); err != nil {
+				t.Fatal(err)
+			}
+			deleteTwice()
+			afterLogin := probes.Load()
+
+			deleteTwice()
+			if n := probes.Load(); n != afterLogin {
+				t.Errorf("version asked %d more times without a new login", n-afterLogin)
+			}
+
+			// A new login asks again, as firmware may hide the version until then
+			if err := client.LoginContext(ctx, "admin", "admin"); err != nil {
+				t.Fatal(err)
+			}
+			deleteTwice()
+			if n := probes.Load(); !tt.modern && n <= afterLogin {
+				t.Error("version not asked again after logging in")
+			}
+		})
+	}
+}
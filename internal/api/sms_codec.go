@@ -0,0 +1,396 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// gsm7Escape switches the next septet to the extension table
+const gsm7Escape = 0x1b
+
+// gsm7Alphabet is the GSM 03.38 default alphabet, indexed by septet. The
+// escape position holds a placeholder that is never emitted.
+var gsm7Alphabet = []rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// gsm7Extension is the GSM 03.38 extension table reached through gsm7Escape
+var gsm7Extension = map[byte]rune{
+	0x0a: '\f',
+	0x14: '^',
+	0x28: '{',
+	0x29: '}',
+	0x2f: '\\',
+	0x3c: '[',
+	0x3d: '~',
+	0x3e: ']',
+	0x40: '|',
+	0x65: '€',
+}
+
+// hexCoding is how hex-encoded text from the device is to be read
+type hexCoding int
+
+const (
+	codingUCS2 hexCoding = iota
+	codingGSM7
+	coding8Bit
+)
+
+// codingForEncodeType maps the encode_type of a message to its coding. ZTE
+// firmware stores content as UTF-16BE unless it says otherwise, so an empty
+// or unknown type is UCS-2.
+func codingForEncodeType(encodeType string) hexCoding {
+	switch strings.ToUpper(strings.TrimSpace(encodeType)) {
+	case "GSM7_DEFAULT", "GSM7", "GSM7_TURKEY", "GSM7_SPANISH", "GSM7_PORTUGUESE":
+		return codingGSM7
+	case "8BIT":
+		return coding8Bit
+	}
+	return codingUCS2
+}
+
+// codingForDCS maps a cell broadcast / USSD data coding scheme (3GPP TS
+// 23.038 section 5) to its coding
+func codingForDCS(dcs int) hexCoding {
+	switch {
+	case dcs == 0x11:
+		// UCS-2 preceded by a two character language
+		return codingUCS2
+	case dcs&0xc0 == 0x40:
+		// General data coding; bits 3-2 are the alphabet
+		switch (dcs >> 2) & 0x03 {
+		case 1:
+			return coding8Bit
+		case 2:
+			return codingUCS2
+		}
+	case dcs&0xf0 == 0xf0:
+		if dcs&0x04 != 0 {
+			return coding8Bit
+		}
+	}
+	return codingGSM7
+}
+
+// decodeStoredSMS decodes the content of a stored message. ZTE firmware
+// keeps UTF-16BE hex even under a GSM7_default encode_type, so like the
+// stock web UI it reads content as UCS-2 whenever that decodes and only
+// falls back to the encode_type otherwise.
+func decodeStoredSMS(hexContent, encodeType string) (string, error) {
+	if s, ok := decodeUCS2Hex(hexContent); ok {
+		return s, nil
+	}
+	return decodeHexSMS(hexContent, codingForEncodeType(encodeType))
+}
+
+// decodeHexSMS decodes hex-encoded content from the device with the given
+// coding. GSM-7 content is normally one septet per byte; a byte with the top
+// bit set can only occur in packed septets, so such content is unpacked
+// first.
+func decodeHexSMS(hexContent string, coding hexCoding) (string, error) {
+	hexContent = strings.ReplaceAll(hexContent, " ", "")
+
+	decoded, err := hex.DecodeString(hexContent)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex string: %w", err)
+	}
+
+	switch coding {
+	case codingGSM7:
+		if s, ok := decodeGSM7(decoded); ok {
+			return s, nil
+		}
+		if s, ok := decodeGSM7(unpackSeptets(decoded)); ok {
+			return s, nil
+		}
+		return "", errors.New("invalid GSM-7 content")
+	case coding8Bit:
+		return decodeLatin1(decoded), nil
+	}
+
+	if s, ok := decodeUCS2(decoded); ok {
+		return s, nil
+	}
+	return "", errors.New("invalid UCS-2 content")
+}
+
+// decodeUCS2Hex decodes hex-encoded UTF-16BE, such as phonebook names
+func decodeUCS2Hex(hexContent string) (string, bool) {
+	decoded, err := hex.DecodeString(strings.ReplaceAll(hexContent, " ", ""))
+	if err != nil {
+		return "", false
+	}
+	return decodeUCS2(decoded)
+}
+
+// decodeUCS2 decodes UTF-16BE, joining surrogate pairs. It fails on odd
+// lengths and unpaired surrogates.
+func decodeUCS2(b []byte) (string, bool) {
+	if len(b)%2 != 0 {
+		return "", false
+	}
+
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+
+	for i := 0; i < len(units); i++ {
+		u := units[i]
+		switch {
+		case u >= 0xd800 && u < 0xdc00:
+			if i+1 >= len(units) || units[i+1] < 0xdc00 || units[i+1] >= 0xe000 {
+				return "", false
+			}
+			i++
+		case u >= 0xdc00 && u < 0xe000:
+			return "", false
+		}
+	}
+
+	return string(utf16.Decode(units)), true
+}
+
+// decodeGSM7 decodes unpacked GSM-7 septets, applying the extension table
+// after each escape. It fails if any byte is outside the 7-bit range.
+func decodeGSM7(b []byte) (string, bool) {
+	var sb strings.Builder
+
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if c >= 0x80 {
+			return "", false
+		}
+
+		if c == gsm7Escape {
+			i++
+			if i >= len(b) {
+				break
+			}
+			if r, ok := gsm7Extension[b[i]]; ok {
+				sb.WriteRune(r)
+				continue
+			}
+			// Unknown extensions fall back to the default table
+			c = b[i]
+			if c >= 0x80 {
+				return "", false
+			}
+		}
+
+		sb.WriteRune(gsm7Alphabet[c])
+	}
+
+	return sb.String(), true
+}
+
+// unpackSeptets spreads GSM-7 septets packed eight to seven bytes into one
+// septet per byte. A trailing zero septet left by the padding is dropped.
+func unpackSeptets(b []byte) []byte {
+	count := len(b) * 8 / 7
+	septets := make([]byte, 0, count)
+	for i := 0; i < count; i++ {
+		bit := i * 7
+		v := uint16(b[bit/8])
+		if bit/8+1 < len(b) {
+			v |= uint16(b[bit/8+1]) << 8
+		}
+		septets = append(septets, byte(v>>(bit%8))&0x7f)
+	}
+	if len(septets) > 0 && len(b)%7 == 0 && septets[len(septets)-1] == 0 {
+		septets = septets[:len(septets)-1]
+	}
+	return septets
+}
+
+// decodeLatin1 decodes 8-bit data, which is UTF-8 when valid and ISO 8859-1
+// otherwise
+func decodeLatin1(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// parseSMSDate parses the device's "YY,MM,DD,HH,MM,SS,+TZ" date, where TZ
+// is the UTC offset in quarter hours. Without a TZ field the local zone is
+// assumed.
+func parseSMSDate(s string) (time.Time, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) < 6 {
+		return time.Time{}, fmt.Errorf("invalid SMS date %q", s)
+	}
+
+	fields := make([]int, 6)
+	for i := range fields {
+		n, err := strconv.Atoi(strings.TrimSpace(parts[i]))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid SMS date %q", s)
+		}
+		fields[i] = n
+	}
+
+	loc := time.Local
+	if len(parts) >= 7 {
+		if quarters, err := strconv.Atoi(strings.TrimSpace(parts[6])); err == nil {
+			loc = time.FixedZone(quarterHourZoneName(quarters), quarters*15*60)
+		}
+	}
+
+	return time.Date(2000+fields[0], time.Month(fields[1]), fields[2],
+		fields[3], fields[4], fields[5], 0, loc), nil
+}
+
+// quarterHourZoneName renders an offset in quarter hours as "+05:45"
+func quarterHourZoneName(quarters int) string {
+	sign := "+"
+	if quarters < 0 {
+		sign = "-"
+		quarters = -quarters
+	}
+	return fmt.Sprintf("%s%02d:%02d", sign, quarters/4, quarters%4*15)
+}
+
+// SMSEncoding is the encode_type sent with a message
+type SMSEncoding string
+
+const (
+	EncodingGSM7    SMSEncoding = "GSM7_default"
+	EncodingUnicode SMSEncoding = "UNICODE"
+)
+
+// Segment capacities in septets (GSM-7) or UTF-16 units (UNICODE). Multipart
+// messages lose room to the concatenation header.
+const (
+	gsm7SingleSegment = 160
+	gsm7MultiSegment  = 153
+	ucs2SingleSegment = 70
+	ucs2MultiSegment  = 67
+)
+
+// MaxSMSSegments is the longest concatenated message the ZTE web UI allows
+// (765 GSM-7 or 335 UNICODE characters)
+const MaxSMSSegments = 5
+
+// SMSInfo describes how a message will be encoded and split
+type SMSInfo struct {
+	Encoding SMSEncoding
+	// Length is in septets for GSM-7 (extension characters count twice) and
+	// in UTF-16 units for UNICODE
+	Length   int
+	Segments int
+	// Remaining is the room left in the last segment
+	Remaining int
+}
+
+// TooLong reports whether the message exceeds MaxSMSSegments
+func (info SMSInfo) TooLong() bool {
+	return info.Segments > MaxSMSSegments
+}
+
+// AnalyzeSMS picks GSM-7 if every character is in the default alphabet or
+// extension table, UNICODE otherwise, and counts the segments needed
+func AnalyzeSMS(content string) SMSInfo {
+	info := SMSInfo{Encoding: EncodingGSM7}
+	single, multi := gsm7SingleSegment, gsm7MultiSegment
+
+	if length, ok := gsm7Length(content); ok {
+		info.Length = length
+	} else {
+		info.Encoding = EncodingUnicode
+		info.Length = len(utf16.Encode([]rune(content)))
+		single, multi = ucs2SingleSegment, ucs2MultiSegment
+	}
+
+	switch {
+	case info.Length == 0:
+		info.Remaining = single
+	case info.Length <= single:
+		info.Segments = 1
+		info.Remaining = single - info.Length
+	default:
+		info.Segments = (info.Length + multi - 1) / multi
+		info.Remaining = info.Segments*multi - info.Length
+	}
+
+	return info
+}
+
+// gsm7Length returns the number of septets content needs, or false if it
+// has a character GSM-7 cannot represent
+func gsm7Length(content string) (int, bool) {
+	length := 0
+	for _, r := range content {
+		switch {
+		case gsm7Index(r) >= 0:
+			length++
+		case gsm7ExtensionIndex(r) >= 0:
+			length += 2
+		default:
+			return 0, false
+		}
+	}
+	return length, true
+}
+
+func gsm7Index(r rune) int {
+	if r == gsm7Escape {
+		return -1
+	}
+	for i, c := range gsm7Alphabet {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func gsm7ExtensionIndex(r rune) int {
+	for b, c := range gsm7Extension {
+		if c == r {
+			return int(b)
+		}
+	}
+	return -1
+}
+
+// checkSMSLength returns info for content, or ErrMessageTooLong if it needs
+// more than MaxSMSSegments
+func checkSMSLength(content string) (SMSInfo, error) {
+	info := AnalyzeSMS(content)
+	if info.TooLong() {
+		return info, fmt.Errorf("%w: %d segments, the limit is %d", ErrMessageTooLong, info.Segments, MaxSMSSegments)
+	}
+	return info, nil
+}
+
+// encodeUCS2Hex hex-encodes s as UTF-16BE, as the web UI does for MessageBody
+func encodeUCS2Hex(s string) string {
+	units := utf16.Encode([]rune(s))
+	buf := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		buf = append(buf, byte(u>>8), byte(u))
+	}
+	return strings.ToUpper(hex.EncodeToString(buf))
+}
+
+// formatSMSTime renders t as the web UI's "YY;MM;DD;HH;mm;ss;+TZ" sms_time,
+// with TZ in hours
+func formatSMSTime(t time.Time) string {
+	_, offset := t.Zone()
+	zone := strconv.FormatFloat(float64(offset)/3600, 'f', -1, 64)
+	if offset >= 0 {
+		zone = "+" + zone
+	}
+	return t.Format("06;01;02;15;04;05;") + zone
+}
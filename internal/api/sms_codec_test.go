@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDecodeHexSMS(t *testing.T) {
+	tests := []struct {
+		name    string
+		hex     string
+		coding  hexCoding
+		want    string
+		wantErr bool
+	}{
+		{"ucs2 ascii", "00480065006C006C006F", codingUCS2, "Hello", false},
+		{"ucs2 accents", "005A006F00EB0020004D00770061006C0065", codingUCS2, "Zoë Mwale", false},
+		{"ucs2 unpaired surrogate", "D83D0041", codingUCS2, "", true},
+		{"ucs2 emoji", "00480069D83DDE00", codingUCS2, "Hi😀", false},
+		{"ucs2 even length hex is not guessed", "4869", codingUCS2, "䡩", false},
+		{"ucs2 odd length", "004800", codingUCS2, "", true},
+		{"gsm7 unpacked", "4869", codingGSM7, "Hi", false},
+		{"gsm7 packed", "C834", codingGSM7, "Hi", false},
+		{"gsm7 packed eight septets", "C8329BFD06DDDF", codingGSM7, "Hello wo", false},
+		{"gsm7 packed seven septets", "C8329BFD06DD01", codingGSM7, "Hello w", false},
+		{"gsm7 extension", "1B6520313030", codingGSM7, "€ 100", false},
+		{"gsm7 alphabet", "00010203", codingGSM7, "@£$¥", false},
+		{"8bit", "48692021", coding8Bit, "Hi !", false},
+		{"8bit latin1", "4361E7E3", coding8Bit, "Caçã", false},
+		{"spaces are ignored", "0048 0069", codingUCS2, "Hi", false},
+		{"not hex", "Hello", codingUCS2, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeHexSMS(tt.hex, tt.coding)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeHexSMS(%q) error = %v, want error %v", tt.hex, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("decodeHexSMS(%q) = %q, want %q", tt.hex, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStoredSMS(t *testing.T) {
+	tests := []struct {
+		name       string
+		hex        string
+		encodeType string
+		want       string
+	}{
+		{"ucs2 under GSM7_default", "00480065006C006C006F", "GSM7_default", "Hello"},
+		{"ucs2 without a type", "00480069", "", "Hi"},
+		{"ucs2 under UNICODE", "005A006F00EB", "UNICODE", "Zoë"},
+		{"packed gsm7 that is not UTF-16", "C8329BFD06DD01", "GSM7_default", "Hello w"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeStoredSMS(tt.hex, tt.encodeType)
+			if err != nil {
+				t.Fatalf("decodeStoredSMS(%q, %q): %v", tt.hex, tt.encodeType, err)
+			}
+			if got != tt.want {
+				t.Errorf("decodeStoredSMS(%q, %q) = %q, want %q", tt.hex, tt.encodeType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodingForEncodeType(t *testing.T) {
+	tests := map[string]hexCoding{
+		"":             codingUCS2,
+		"UNICODE":      codingUCS2,
+		"GSM7_default": codingGSM7,
+		"8bit":         coding8Bit,
+		"something":    codingUCS2,
+	}
+	for encodeType, want := range tests {
+		if got := codingForEncodeType(encodeType); got != want {
+			t.Errorf("codingForEncodeType(%q) = %v, want %v", encodeType, got, want)
+		}
+	}
+}
+
+func TestDecodeUSSD(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		dcs  string
+		want string
+	}{
+		{"ucs2", "00420061006C0061006E00630065003A0020004D0057004B002000310032", "72", "Balance: MWK 12"},
+		{"ucs2 with language", "0031", "17", "1"},
+		{"gsm7 plain text", "1234", "15", "1234"},
+		{"gsm7 general coding", "Your balance is 5", "64", "Your balance is 5"},
+		{"no scheme", "1234", "", "1234"},
+		{"ucs2 that is not hex", "Menu", "72", "Menu"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeUSSD(tt.data, tt.dcs); got != tt.want {
+				t.Errorf("decodeUSSD(%q, %q) = %q, want %q", tt.data, tt.dcs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSMSDate(t *testing.T) {
+	tests := []struct {
+		date       string
+		wantUTC    time.Time
+		wantOffset int
+	}{
+		// East Africa, +3 hours
+		{"24,03,15,10,30,00,+12", time.Date(2024, 3, 15, 7, 30, 0, 0, time.UTC), 3 * 3600},
+		// Nepal, +5:45
+		{"24,03,15,10,30,00,+23", time.Date(2024, 3, 15, 4, 45, 0, 0, time.UTC), 5*3600 + 45*60},
+		// Newfoundland, -3:30
+		{"24,11,02,08,05,09,-14", time.Date(2024, 11, 2, 11, 35, 9, 0, time.UTC), -(3*3600 + 30*60)},
+		{"25,01,01,00,00,00,+0", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), 0},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSMSDate(tt.date)
+		if err != nil {
+			t.Errorf("parseSMSDate(%q): %v", tt.date, err)
+			continue
+		}
+		if !got.Equal(tt.wantUTC) {
+			t.Errorf("parseSMSDate(%q) = %v, want %v", tt.date, got.UTC(), tt.wantUTC)
+		}
+		if _, offset := got.Zone(); offset != tt.wantOffset {
+			t.Errorf("parseSMSDate(%q) offset = %d, want %d", tt.date, offset, tt.wantOffset)
+		}
+	}
+
+	if _, err := parseSMSDate("24,03,15"); err == nil {
+		t.Error("parseSMSDate accepted a date without a time")
+	}
+}
+
+// smsDataTotalPage is an sms_data_total answer in the device's format: a
+// two-part concatenated UCS-2 message reported as separate parts, a message
+// tagged GSM7_default whose content is UCS-2 hex like every other and a
+// sent message
+const smsDataTotalPage = `{"messages":[
+	{"id":"12","number":"+265999000111","content":"0020007400680065002000720065007300740020006F00660020006900740020002D00200062007900650021","tag":"1","date":"24,03,15,10,30,05,+8","draft_group_id":"","concat_sms_total":"2","concat_sms_received":"2","concat_sms_seq":"2","sms_concat_ref":"47","sms_class":"4"},
+	{"id":"11","number":"+265999000111","content":"0054006800690073002000690073002000700061007200740020006F006E0065002C","tag":"1","date":"24,03,15,10,30,04,+8","draft_group_id":"","concat_sms_total":"2","concat_sms_received":"2","concat_sms_seq":"1","sms_concat_ref":"47","sms_class":"4"},
+	{"id":"10","number":"AIRTEL","content":"0059006F00750072002000620061006C0061006E006300650020006900730020004D0057004B0020003100300030","tag":"0","date":"24,03,14,18,00,00,+8","encode_type":"GSM7_default","sms_class":"4"},
+	{"id":"9","number":"+265888000222","content":"004F006B0020D83DDC4D","tag":"2","date":"24,03,14,17,59,00,+8","sms_class":"4"}
+]}`
+
+func TestParseSMSEntries(t *testing.T) {
+	var page struct {
+		Messages []map[string]interface{} `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(smsDataTotalPage), &page); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []SMSMessage
+	for _, m := range page.Messages {
+		messages = append(messages, parseSMSEntry(m, SMSStoreDevice))
+	}
+
+	if got := messages[2].Content; got != "Your balance is MWK 100" {
+		t.Errorf("GSM7_default content = %q", got)
+	}
+	if messages[2].IsUnread() || !messages[0].IsUnread() {
+		t.Errorf("statuses = %v and %v, want read for tag 0 and unread for tag 1", messages[2].Status, messages[0].Status)
+	}
+	if !messages[3].IsSent() || messages[3].Content != "Ok 👍" {
+		t.Errorf("sent message = %q, sent %v", messages[3].Content, messages[3].IsSent())
+	}
+	if messages[0].ConcatTotal != 2 || messages[0].ConcatPart != 2 || messages[0].ConcatRef != "47" {
+		t.Errorf("concat fields = %d/%d ref %q", messages[0].ConcatPart, messages[0].ConcatTotal, messages[0].ConcatRef)
+	}
+
+	stitched := StitchConcat(messages)
+	if len(stitched) != 3 {
+		t.Fatalf("StitchConcat returned %d messages, want 3", len(stitched))
+	}
+
+	var joined *SMSMessage
+	for i := range stitched {
+		if stitched[i].Number == "+265999000111" {
+			joined = &stitched[i]
+		}
+	}
+	if joined == nil {
+		t.Fatal("concatenated message missing after StitchConcat")
+	}
+	if want := "This is part one, the rest of it - bye!"; joined.Content != want {
+		t.Errorf("stitched content = %q, want %q", joined.Content, want)
+	}
+	if ids := joined.AllIDs(); len(ids) != 2 {
+		t.Errorf("stitched IDs = %v, want both parts", ids)
+	}
+}
@@ -0,0 +1,255 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// missingPart stands in for parts of a concatenated message that have not
+// arrived yet
+const missingPart = "[…]"
+
+// Thread is the conversation with one correspondent
+type Thread struct {
+	// Key is the NumberKey shared by every message
+	Key string
+	// Number is the normalized number of the newest message
+	Number string
+	// Messages are oldest first
+	Messages []SMSMessage
+	Unread   int
+}
+
+// Latest returns the newest message in the thread
+func (t *Thread) Latest() SMSMessage {
+	return t.Messages[len(t.Messages)-1]
+}
+
+// IsUnread reports whether a received message has not been read
+func (m *SMSMessage) IsUnread() bool {
+	return m.Status == SMSUnread
+}
+
+// IsSent reports whether the message was sent from the device
+func (m *SMSMessage) IsSent() bool {
+	return m.Status == SMSSent
+}
+
+// AllIDs returns the device IDs behind the message, including every part of
+// a stitched message
+func (m *SMSMessage) AllIDs() []string {
+	if len(m.PartIDs) > 0 {
+		return m.PartIDs
+	}
+	return []string{m.ID}
+}
+
+// NormalizeNumber reduces a phone number to the form used to match threads:
+// separators are dropped and a 00 international prefix becomes +.
+// Alphanumeric senders such as "AIRTEL" are upper-cased.
+func NormalizeNumber(number string) string {
+	number = strings.TrimSpace(number)
+
+	var b strings.Builder
+	for i, r := range number {
+		switch {
+		case unicode.IsDigit(r), r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ', r == '-', r == '.', r == '(', r == ')':
+		default:
+			return strings.ToUpper(number)
+		}
+	}
+
+	normalized := b.String()
+	if rest, ok := strings.CutPrefix(normalized, "00"); ok && len(rest) > 6 {
+		normalized = "+" + rest
+	}
+	return normalized
+}
+
+// minNumberDigits is the length from which a number is a subscriber number
+// rather than a short code
+const minNumberDigits = 7
+
+var (
+	countryCodeMu      sync.RWMutex
+	defaultCountryCode string
+)
+
+// NormalizeCountryCode checks a calling code such as "265", "+265" or
+// "00265" and returns its digits. An empty code is allowed.
+func NormalizeCountryCode(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if rest, ok := strings.CutPrefix(code, "+"); ok {
+		code = rest
+	} else {
+		code = strings.TrimPrefix(code, "00")
+	}
+
+	if len(code) > 3 || strings.IndexFunc(code, func(r rune) bool {
+		return r < '0' || r > '9'
+	}) >= 0 || strings.HasPrefix(code, "0") {
+		return "", fmt.Errorf("invalid country code %q", code)
+	}
+	return code, nil
+}
+
+// SetDefaultCountryCode sets the calling code NumberKey gives numbers
+// written in national form. An empty code leaves them national.
+func SetDefaultCountryCode(code string) error {
+	code, err := NormalizeCountryCode(code)
+	if err != nil {
+		return err
+	}
+
+	countryCodeMu.Lock()
+	defer countryCodeMu.Unlock()
+	defaultCountryCode = code
+	return nil
+}
+
+// DefaultCountryCode returns the code set by SetDefaultCountryCode
+func DefaultCountryCode() string {
+	countryCodeMu.RLock()
+	defer countryCodeMu.RUnlock()
+	return defaultCountryCode
+}
+
+// NumberKey reduces a phone number to the key used to match it against
+// contacts. Subscriber numbers become +<country code><national number>, so
+// with a default country code of 265 "+265 999 123 456", "00265999123456"
+// and "0999123456" agree. A leading 0 is taken as the trunk prefix.
+//
+// Without a default country code the national number cannot be told from
+// the country code, so subscriber numbers are compared by their last
+// minNumberDigits digits, as phones do. Short codes and alphanumeric
+// senders are compared whole, as NormalizeNumber returns them.
+func NumberKey(number string) string {
+	normalized := NormalizeNumber(number)
+	digits, international := strings.CutPrefix(normalized, "+")
+	if len(digits) < minNumberDigits || strings.IndexFunc(digits, func(r rune) bool {
+		return r < '0' || r > '9'
+	}) >= 0 {
+		return normalized
+	}
+
+	cc := DefaultCountryCode()
+	if cc == "" {
+		return digits[len(digits)-minNumberDigits:]
+	}
+	if international {
+		return normalized
+	}
+	if national, ok := strings.CutPrefix(digits, "0"); ok {
+		return "+" + cc + national
+	}
+
+	// Some networks report international numbers without the +
+	if strings.HasPrefix(digits, cc) && len(digits)-len(cc) >= minNumberDigits {
+		return "+" + digits
+	}
+	return "+" + cc + digits
+}
+
+// StitchConcat joins the parts of concatenated messages that the device
+// reports separately. Parts are matched on number and concat reference and
+// ordered by part number; the result keeps the first part's ID and lists
+// every part in PartIDs.
+func StitchConcat(messages []SMSMessage) []SMSMessage {
+	type key struct {
+		number string
+		ref    string
+	}
+
+	groups := make(map[key][]SMSMessage)
+	var out []SMSMessage
+	var order []key
+
+	for _, m := range messages {
+		if m.ConcatTotal <= 1 || m.ConcatRef == "" {
+			out = append(out, m)
+			continue
+		}
+
+		k := key{NormalizeNumber(m.Number), m.ConcatRef}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], m)
+	}
+
+	for _, k := range order {
+		parts := groups[k]
+		sort.SliceStable(parts, func(i, j int) bool { return parts[i].ConcatPart < parts[j].ConcatPart })
+
+		stitched := parts[0]
+		stitched.PartIDs = nil
+
+		var content strings.Builder
+		next := 1
+		for _, p := range parts {
+			for ; next < p.ConcatPart; next++ {
+				content.WriteString(missingPart)
+			}
+			if p.ConcatPart >= next {
+				content.WriteString(p.Content)
+				next = p.ConcatPart + 1
+			}
+
+			stitched.PartIDs = append(stitched.PartIDs, p.ID)
+			if p.IsUnread() {
+				stitched.Status = p.Status
+			}
+			if p.Timestamp.After(stitched.Timestamp) {
+				stitched.Timestamp = p.Timestamp
+			}
+		}
+		for ; next <= stitched.ConcatTotal; next++ {
+			content.WriteString(missingPart)
+		}
+
+		stitched.Content = content.String()
+		out = append(out, stitched)
+	}
+
+	return out
+}
+
+// GroupThreads stitches concatenated messages and groups the result by
+// NumberKey, so national and international forms of a number share a
+// thread. Threads are ordered newest first.
+func GroupThreads(messages []SMSMessage) []Thread {
+	index := make(map[string]int)
+	var threads []Thread
+
+	for _, m := range StitchConcat(messages) {
+		key := NumberKey(m.Number)
+		i, ok := index[key]
+		if !ok {
+			i = len(threads)
+			index[key] = i
+			threads = append(threads, Thread{Key: key})
+		}
+
+		threads[i].Messages = append(threads[i].Messages, m)
+		if m.IsUnread() {
+			threads[i].Unread++
+		}
+	}
+
+	for i := range threads {
+		msgs := threads[i].Messages
+		sort.SliceStable(msgs, func(a, b int) bool { return msgs[a].Timestamp.Before(msgs[b].Timestamp) })
+		threads[i].Number = NormalizeNumber(threads[i].Latest().Number)
+	}
+
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threads[i].Latest().Timestamp.After(threads[j].Latest().Timestamp)
+	})
+
+	return threads
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNumberKey(t *testing.T) {
+	defer SetDefaultCountryCode("")
+
+	tests := []struct {
+		countryCode string
+		numbers     []string
+		want        string
+	}{
+		// Nine digit national numbers
+		{"265", []string{"+265 999 123 456", "00265999123456", "0999123456", "999123456", "265999123456"}, "+265999123456"},
+		// Ten digit national numbers
+		{"44", []string{"+44 7700 900123", "0044 7700 900123", "07700 900123"}, "+447700900123"},
+		// Eight digit numbers without a trunk prefix
+		{"267", []string{"+267 7123 4567", "71234567", "26771234567"}, "+26771234567"},
+		// Numbers of another country keep their own code
+		{"265", []string{"+27 82 123 4567", "0027821234567"}, "+27821234567"},
+		// Without a country code subscriber numbers match by their last digits
+		{"", []string{"0999123456", "999123456", "+265 999 123 456", "00265999123456", "265999123456"}, "9123456"},
+		{"", []string{"+267 7123 4567", "71234567"}, "1234567"},
+		{"", []string{"*100#"}, "*100#"},
+		{"", []string{"Airtel"}, "AIRTEL"},
+		{"265", []string{"*100#"}, "*100#"},
+		{"265", []string{"456"}, "456"},
+		{"265", []string{"Airtel"}, "AIRTEL"},
+	}
+
+	for _, tt := range tests {
+		if err := SetDefaultCountryCode(tt.countryCode); err != nil {
+			t.Fatal(err)
+		}
+		for _, number := range tt.numbers {
+			if got := NumberKey(number); got != tt.want {
+				t.Errorf("country code %q: NumberKey(%q) = %q, want %q", tt.countryCode, number, got, tt.want)
+			}
+		}
+	}
+
+	// Different subscribers sharing their last digits stay apart
+	SetDefaultCountryCode("265")
+	if NumberKey("+27999123456") == NumberKey("0999123456") {
+		t.Error("numbers of different countries with the same last nine digits match")
+	}
+}
+
+func TestNormalizeCountryCode(t *testing.T) {
+	for code, want := range map[string]string{"265": "265", "+44": "44", "00267": "267", " 1 ": "1", "": ""} {
+		got, err := NormalizeCountryCode(code)
+		if err != nil || got != want {
+			t.Errorf("NormalizeCountryCode(%q) = %q, %v, want %q", code, got, err, want)
+		}
+	}
+	for _, code := range []string{"2655", "+0", "MW", "2 65"} {
+		if _, err := NormalizeCountryCode(code); err == nil {
+			t.Errorf("NormalizeCountryCode(%q) accepted an invalid code", code)
+		}
+	}
+}
+
+func TestGroupThreads(t *testing.T) {
+	defer SetDefaultCountryCode("")
+
+	day := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	messages := []SMSMessage{
+		{ID: "1", Number: "+265999123456", Content: "Hi", Status: SMSRead, Timestamp: day},
+		{ID: "2", Number: "0999 123 456", Content: "Hello", Status: SMSSent, Timestamp: day.Add(time.Minute)},
+		{ID: "3", Number: "00265999123456", Content: "Call me", Status: SMSUnread, Timestamp: day.Add(2 * time.Minute)},
+		{ID: "4", Number: "+265888000222", Content: "Later", Status: SMSRead, Timestamp: day.Add(time.Hour)},
+		{ID: "5", Number: "AIRTEL", Content: "Bundle", Status: SMSRead, Timestamp: day.Add(-time.Hour)},
+	}
+
+	for _, cc := range []string{"265", ""} {
+		if err := SetDefaultCountryCode(cc); err != nil {
+			t.Fatal(err)
+		}
+
+		threads := GroupThreads(messages)
+		if len(threads) != 3 {
+			t.Fatalf("country code %q: %d threads, want 3", cc, len(threads))
+		}
+		if threads[0].Number != "+265888000222" || threads[2].Number != "AIRTEL" {
+			t.Errorf("country code %q: threads ordered %q, %q, %q", cc, threads[0].Number, threads[1].Number, threads[2].Number)
+		}
+
+		// National and international forms share a thread, shown by the
+		// newest message's number
+		thread := threads[1]
+		if len(thread.Messages) != 3 || thread.Unread != 1 || thread.Key != NumberKey("0999123456") {
+			t.Errorf("country code %q: thread %q has %d messages, %d unread", cc, thread.Key, len(thread.Messages), thread.Unread)
+		}
+		if thread.Number != "+265999123456" || thread.Latest().ID != "3" {
+			t.Errorf("country code %q: thread number %q, latest %s", cc, thread.Number, thread.Latest().ID)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redacted replaces sensitive values in recorded fixtures
+const Redacted = "REDACTED"
+
+// redactedKeys are request parameters and response fields that identify the
+// device, its owner or its credentials
+var redactedKeys = map[string]bool{
+	"password":      true,
+	"user":          true,
+	"AD":            true,
+	"LD":            true,
+	"RD":            true,
+	"imei":          true,
+	"iccid":         true,
+	"imsi":          true,
+	"sim_imsi":      true,
+	"msisdn":        true,
+	"wifi_password": true,
+	"WPAPSK1":       true,
+	"passphrase":    true,
+	"Number":        true,
+	"number":        true,
+	"macaddress":    true,
+	"mac_addr":      true,
+	"station_mac":   true,
+
+	// Phonebook contacts, as read and as posted
+	"pbm_name":        true,
+	"pbm_number":      true,
+	"pbm_anr":         true,
+	"pbm_anr1":        true,
+	"pbm_email":       true,
+	"name":            true,
+	"mobilephone_num": true,
+	"homephone_num":   true,
+	"officephone_num": true,
+	"email":           true,
+
+	// Message and USSD text, which carries codes, balances and OTPs
+	"content":     true,
+	"Content":     true,
+	"MessageBody": true,
+	"ussd_data":   true,
+}
+
+// volatileKeys change on every request and are ignored when matching replays
+var volatileKeys = map[string]bool{
+	"_":        true,
+	"AD":       true,
+	"isTest":   true,
+	"sms_time": true,
+}
+
+// Exchange is one recorded request/response pair
+type Exchange struct {
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Params map[string]string `json:"params,omitempty"`
+	Status int               `json:"status"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	// Raw holds a body that is not JSON exactly as the device sent it
+	Raw string `json:"raw,omitempty"`
+}
+
+// body returns the response body as the device sent it
+func (ex *Exchange) body() []byte {
+	if ex.Body == nil {
+		return []byte(ex.Raw)
+	}
+	return ex.Body
+}
+
+// Fixture is a recorded session. RecordingTransport writes it as JSON
+// lines: a header with the recording time, then one exchange per line.
+type Fixture struct {
+	RecordedAt time.Time  `json:"recorded_at"`
+	Exchanges  []Exchange `json:"exchanges,omitempty"`
+}
+
+// fixtureLine is one line of a fixture file, either the header or an
+// exchange
+type fixtureLine struct {
+	RecordedAt time.Time `json:"recorded_at"`
+	Exchange
+}
+
+// LoadFixture reads a fixture file written by RecordingTransport
+func LoadFixture(path string) (*Fixture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	defer f.Close()
+
+	return ParseFixture(f)
+}
+
+// ParseFixture reads a fixture from r
+func ParseFixture(r io.Reader) (*Fixture, error) {
+	var fixture Fixture
+	dec := json.NewDecoder(r)
+	for {
+		var line fixtureLine
+		if err := dec.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse fixture: %w", err)
+		}
+
+		if fixture.RecordedAt.IsZero() {
+			fixture.RecordedAt = line.RecordedAt
+		}
+		if line.Method != "" {
+			fixture.Exchanges = append(fixture.Exchanges, line.Exchange)
+		}
+	}
+
+	return &fixture, nil
+}
+
+// RecordingTransport passes requests through to Next and appends every
+// goform exchange, with credentials and identifiers redacted, to a fixture
+// file at Path. The file is replaced when the first exchange is recorded.
+type RecordingTransport struct {
+	Next http.RoundTripper
+	Path string
+
+	mu         sync.Mutex
+	recordedAt time.Time
+	started    bool
+}
+
+// NewRecordingTransport wraps next (http.DefaultTransport if nil) and records to path
+func NewRecordingTransport(next http.RoundTripper, path string) *RecordingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RecordingTransport{
+		Next:       next,
+		Path:       path,
+		recordedAt: time.Now(),
+	}
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	params, err := requestParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil || !strings.HasPrefix(req.URL.Path, "/goform/") {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	exchange := Exchange{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Params: redactParams(params),
+		Status: resp.StatusCode,
+	}
+	if redacted, ok := redactBody(body); ok {
+		exchange.Body = redacted
+	} else {
+		exchange.Raw = string(body)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.append(exchange); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// append writes exchange as a line at the end of the fixture, creating the
+// file with its header first if this is the first exchange
+func (t *RecordingTransport) append(exchange Exchange) error {
+	var lines []byte
+	flags := os.O_WRONLY | os.O_APPEND
+	if !t.started {
+		header, err := json.Marshal(Fixture{RecordedAt: t.recordedAt})
+		if err != nil {
+			return fmt.Errorf("failed to encode fixture: %w", err)
+		}
+		lines = append(header, '\n')
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	lines = append(append(lines, line...), '\n')
+
+	f, err := os.OpenFile(t.Path, flags, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	if _, err := f.Write(lines); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	t.started = true
+	return nil
+}
+
+// ReplayTransport answers goform requests from a recorded fixture without
+// touching the network. Exchanges with the same method, path and parameters
+// are served in recorded order, and the last one repeats once they run out,
+// so polling keeps working.
+type ReplayTransport struct {
+	mu     sync.Mutex
+	queues map[string][]Exchange
+}
+
+// NewReplayTransport serves the exchanges in fixture
+func NewReplayTransport(fixture *Fixture) *ReplayTransport {
+	t := &ReplayTransport{queues: make(map[string][]Exchange)}
+	for _, ex := range fixture.Exchanges {
+		key := exchangeKey(ex.Method, ex.Path, ex.Params)
+		t.queues[key] = append(t.queues[key], ex)
+	}
+	return t
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	params, err := requestParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	key := exchangeKey(req.Method, req.URL.Path, redactParams(params))
+
+	t.mu.Lock()
+	queue := t.queues[key]
+	if len(queue) == 0 {
+		t.mu.Unlock()
+
+		// Pages outside the goform API, such as the Ping target, always exist
+		if !strings.HasPrefix(req.URL.Path, "/goform/") {
+			return replayResponse(req, http.StatusOK, nil), nil
+		}
+		return nil, fmt.Errorf("no recorded exchange for %s", key)
+	}
+	ex := queue[0]
+	if len(queue) > 1 {
+		t.queues[key] = queue[1:]
+	}
+	t.mu.Unlock()
+
+	return replayResponse(req, ex.Status, ex.body()), nil
+}
+
+func replayResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// requestParams returns the query and form parameters of req, restoring the
+// body so it can still be sent
+func requestParams(req *http.Request) (map[string]string, error) {
+	params := make(map[string]string)
+	for k, v := range req.URL.Query() {
+		params[k] = strings.Join(v, ",")
+	}
+
+	if req.Body == nil {
+		return params, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return params, nil
+	}
+	for k, v := range form {
+		params[k] = strings.Join(v, ",")
+	}
+
+	return params, nil
+}
+
+// isRedacted reports whether the value of parameter or field k is
+// sensitive. The WiFi password names of registered profiles count too.
+func isRedacted(k string) bool {
+	return redactedKeys[k] || isProfilePasswordKey(k)
+}
+
+func redactParams(params map[string]string) map[string]string {
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		if isRedacted(k) && v != "" {
+			v = Redacted
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// redactBody blanks sensitive fields anywhere in a JSON response. It
+// reports false for bodies that are not JSON.
+func redactBody(body []byte) (json.RawMessage, bool) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, false
+	}
+
+	redacted, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return nil, false
+	}
+	return redacted, true
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, inner := range val {
+			if s, ok := inner.(string); ok && isRedacted(k) && s != "" {
+				val[k] = Redacted
+				continue
+			}
+			val[k] = redactValue(inner)
+		}
+	case []interface{}:
+		for i, inner := range val {
+			val[i] = redactValue(inner)
+		}
+	}
+	return v
+}
+
+// exchangeKey identifies requests that should receive the same recorded response
+func exchangeKey(method, path string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if !volatileKeys[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(method + " " + path)
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteString("?")
+		} else {
+			b.WriteString("&")
+		}
+		b.WriteString(k + "=" + params[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"mifi_app/internal/mifisim"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// session runs the requests of a typical app start against client
+func session(t *testing.T, client *Client) (*DeviceStatus, []SMSMessage) {
+	t.Helper()
+
+	if err := client.Login("admin", "admin"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	status, err := client.GetDeviceStatus()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	messages, err := client.GetSMSList(0, 20)
+	if err != nil {
+		t.Fatalf("SMS list: %v", err)
+	}
+	return status, messages
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	sim := mifisim.New(mifisim.Options{})
+	url, err := sim.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.Close()
+	sim.DeliverSMS("+265999000111", "Your code is 482913")
+
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+
+	live := NewClient(url, logrus.New())
+	live.HTTPClient.Transport = NewRecordingTransport(nil, path)
+	recordedStatus, recordedMessages := session(t, live)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"861234050000001", "+265999000111", encodeUCS2Hex("Your code is 482913")} {
+		if bytes.Contains(bytes.ToLower(data), bytes.ToLower([]byte(secret))) {
+			t.Errorf("fixture contains %q", secret)
+		}
+	}
+
+	// Every exchange is a line of its own after the header
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lines := 0
+	for scanner.Scan() {
+		if !json.Valid(scanner.Bytes()) {
+			t.Fatalf("line %d is not JSON: %s", lines+1, scanner.Text())
+		}
+		lines++
+	}
+
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixture.RecordedAt.IsZero() {
+		t.Error("fixture has no recording time")
+	}
+	if len(fixture.Exchanges) != lines-1 {
+		t.Errorf("loaded %d exchanges from %d lines", len(fixture.Exchanges), lines)
+	}
+
+	sim.Close()
+
+	replayed := NewClient(url, logrus.New())
+	replayed.HTTPClient.Transport = NewReplayTransport(fixture)
+	status, messages := session(t, replayed)
+
+	if status.NetworkProvider != recordedStatus.NetworkProvider || status.SignalStrength != recordedStatus.SignalStrength {
+		t.Errorf("replayed status = %+v, recorded %+v", status, recordedStatus)
+	}
+	if status.IMEI != Redacted {
+		t.Errorf("replayed IMEI = %q, want it redacted", status.IMEI)
+	}
+	if len(messages) != len(recordedMessages) {
+		t.Fatalf("replayed %d messages, recorded %d", len(messages), len(recordedMessages))
+	}
+	for i := range messages {
+		if messages[i].ID != recordedMessages[i].ID || messages[i].Content != Redacted {
+			t.Errorf("message %d = %s %q, recorded %s with its content redacted", i, messages[i].ID, messages[i].Content, recordedMessages[i].ID)
+		}
+	}
+}
+
+func TestRecordRawBody(t *testing.T) {
+	const page = "<html><body>Device busy\n</body></html>"
+
+	path := filepath.Join(t.TempDir(), "raw.jsonl")
+	recorder := NewRecordingTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return replayResponse(req, http.StatusServiceUnavailable, []byte(page)), nil
+	}), path)
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://192.168.0.1/goform/goform_get_cmd_process?cmd=loginfo", nil)
+		resp, err := recorder.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixture.Exchanges) != 2 {
+		t.Fatalf("recorded %d exchanges, want 2", len(fixture.Exchanges))
+	}
+	if ex := fixture.Exchanges[0]; ex.Raw != page || ex.Body != nil {
+		t.Errorf("raw body stored as raw %q, body %s", ex.Raw, ex.Body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://192.168.0.1/goform/goform_get_cmd_process?cmd=loginfo", nil)
+	resp, err := NewReplayTransport(fixture).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != page || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("replayed %d %q, want %d %q", resp.StatusCode, body, http.StatusServiceUnavailable, page)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ussdPollInterval and ussdTimeout bound the wait for the network to
+	// answer a USSD request
+	ussdPollInterval = time.Second
+	ussdTimeout      = time.Minute
+)
+
+// USSD_PROCESS progress reported in ussd_write_flag
+const (
+	ussdFlagNoService = "1"
+	ussdFlagWaiting   = "15"
+	ussdFlagReady     = "16"
+)
+
+// SendUSSD dials a USSD code such as *100# and waits for the network's answer
+func (c *Client) SendUSSD(code string) (*USSDResponse, error) {
+	return c.SendUSSDContext(context.Background(), code)
+}
+
+// SendUSSDContext is like SendUSSD but uses ctx for cancellation.
+func (c *Client) SendUSSDContext(ctx context.Context, code string) (*USSDResponse, error) {
+	code = strings.TrimSpace(code)
+	if !ValidUSSDCode(code) {
+		return nil, fmt.Errorf("invalid USSD code %q", code)
+	}
+
+	data := map[string]string{
+		"goformId":         "USSD_PROCESS",
+		"USSD_operator":    "ussd_send",
+		"USSD_send_number": code,
+		"notCallback":      "true",
+		"isTest":           "false",
+	}
+	return c.ussd(ctx, data)
+}
+
+// ReplyUSSD answers the menu of an open USSD session
+func (c *Client) ReplyUSSD(reply string) (*USSDResponse, error) {
+	return c.ReplyUSSDContext(context.Background(), reply)
+}
+
+// ReplyUSSDContext is like ReplyUSSD but uses ctx for cancellation.
+func (c *Client) ReplyUSSDContext(ctx context.Context, reply string) (*USSDResponse, error) {
+	data := map[string]string{
+		"goformId":          "USSD_PROCESS",
+		"USSD_operator":     "ussd_reply",
+		"USSD_reply_number": strings.TrimSpace(reply),
+		"notCallback":       "true",
+		"isTest":            "false",
+	}
+	return c.ussd(ctx, data)
+}
+
+// CancelUSSD ends the open USSD session
+func (c *Client) CancelUSSD() error {
+	return c.CancelUSSDContext(context.Background())
+}
+
+// CancelUSSDContext is like CancelUSSD but uses ctx for cancellation.
+func (c *Client) CancelUSSDContext(ctx context.Context) error {
+	data := map[string]string{
+		"goformId":      "USSD_PROCESS",
+		"USSD_operator": "ussd_cancel",
+		"notCallback":   "true",
+		"isTest":        "false",
+	}
+
+	c.ussdMu.Lock()
+	defer c.ussdMu.Unlock()
+
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
+	if err != nil {
+		return err
+	}
+
+	return checkResult(data["goformId"], resp)
+}
+
+// ValidUSSDCode reports whether code looks like a USSD code: digits, * and #
+// only, ending in #
+func ValidUSSDCode(code string) bool {
+	if len(code) < 2 || !strings.HasSuffix(code, "#") {
+		return false
+	}
+	for _, r := range code {
+		if (r < '0' || r > '9') && r != '*' && r != '#' {
+			return false
+		}
+	}
+	return true
+}
+
+// ussd posts a USSD_PROCESS request and returns the network's answer once
+// the device has it. Other USSD requests wait until the answer is read.
+func (c *Client) ussd(ctx context.Context, data map[string]string) (*USSDResponse, error) {
+	c.ussdMu.Lock()
+	defer c.ussdMu.Unlock()
+
+	resp, err := c.PostContext(ctx, LoginEndpoint, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResult(data["goformId"], resp); err != nil {
+		return nil, err
+	}
+
+	if err := c.waitUSSD(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err = c.GetContext(ctx, StatusEndpoint, map[string]string{"cmd": "ussd_data_info"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read USSD answer: %w", err)
+	}
+
+	answer := &USSDResponse{
+		Text: decodeUSSD(firstString(resp, "ussd_data"), firstString(resp, "ussd_dcs")),
+	}
+	switch firstString(resp, "ussd_action") {
+	case "1":
+		answer.Action = USSDReplyExpected
+	case "2":
+		answer.Action = USSDTerminated
+	}
+	return answer, nil
+}
+
+// waitUSSD polls ussd_write_flag until the network has answered
+func (c *Client) waitUSSD(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, ussdTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(ussdPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w: the network did not answer", ErrUSSDFailed)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		resp, err := c.GetContext(ctx, StatusEndpoint, map[string]string{"cmd": "ussd_write_flag"})
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			return fmt.Errorf("failed to read USSD progress: %w", err)
+		}
+
+		switch flag := firstString(resp, "ussd_write_flag"); flag {
+		case ussdFlagReady:
+			return nil
+		case ussdFlagWaiting:
+		case ussdFlagNoService:
+			return ErrUSSDNoService
+		default:
+			return fmt.Errorf("%w (status %q)", ErrUSSDFailed, flag)
+		}
+	}
+}
+
+// decodeUSSD decodes ussd_data as its data coding scheme says. ZTE firmware
+// sends UCS-2 answers (dcs 72) as UTF-16BE hex and decodes GSM-7 and 8-bit
+// answers itself, so those arrive as plain text. Answers without a scheme
+// or that do not decode are shown as they came.
+func decodeUSSD(data, dcs string) string {
+	scheme, err := strconv.Atoi(strings.TrimSpace(dcs))
+	if err != nil || codingForDCS(scheme) != codingUCS2 {
+		return data
+	}
+	if text, ok := decodeUCS2Hex(data); ok {
+		return text
+	}
+	return data
+}
@@ -0,0 +1,161 @@
+// Package balance reads the airtime balance, remaining data and expiry date
+// out of an operator's USSD answer or balance SMS, using per-operator
+// regular expression templates, and keeps a history of the results.
+package balance
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mifi_app/internal/api"
+	"mifi_app/internal/config"
+	"mifi_app/internal/smssync"
+)
+
+// Sources of a balance check
+const (
+	SourceUSSD = "ussd"
+	SourceSMS  = "sms"
+)
+
+var (
+	// ErrNoMatch is returned when an answer holds nothing the template reads
+	ErrNoMatch = errors.New("no balance found in the answer")
+
+	// ErrNoMessage is returned when no balance message has been received
+	ErrNoMessage = errors.New("no balance message received")
+)
+
+// Result is one balance reading. Values the answer did not hold are nil or
+// zero.
+type Result struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	Balance  *float64  `json:"balance,omitempty"`
+	Currency string    `json:"currency,omitempty"`
+	DataMB   *float64  `json:"data_mb,omitempty"`
+	Expiry   time.Time `json:"expiry,omitzero"`
+
+	// Text is the answer the values were read from
+	Text string `json:"text"`
+}
+
+// BalanceText formats the airtime balance, or returns "" if it is unknown
+func (r *Result) BalanceText() string {
+	if r.Balance == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %.2f", r.Currency, *r.Balance))
+}
+
+// DataText formats the remaining data, or returns "" if it is unknown
+func (r *Result) DataText() string {
+	if r.DataMB == nil {
+		return ""
+	}
+	if *r.DataMB >= 1024 {
+		return fmt.Sprintf("%.2f GB", *r.DataMB/1024)
+	}
+	return fmt.Sprintf("%.0f MB", *r.DataMB)
+}
+
+// DroppedBelow reports whether cur is the first result below threshold since
+// prev, which is nil when there was no earlier result. A zero threshold
+// never triggers.
+func DroppedBelow(prev *Result, cur Result, threshold float64) bool {
+	if threshold <= 0 || cur.Balance == nil || *cur.Balance >= threshold {
+		return false
+	}
+	return prev == nil || prev.Balance == nil || *prev.Balance >= threshold
+}
+
+// Check reads the balance as cfg says, with the template for operator:
+// by dialling the USSD code or from the newest balance message on the device
+func Check(ctx context.Context, client api.DeviceAPI, cfg config.BalanceConfig, operator string) (Result, error) {
+	if cfg.Operator != "" {
+		operator = cfg.Operator
+	}
+	template := Select(cfg.Templates, operator)
+	p, err := Compile(template)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if cfg.Source == SourceSMS {
+		sender := cmp.Or(cfg.SMSSender, template.SMSSender)
+		messages, err := smssync.FetchAllContext(ctx, client)
+		if err != nil {
+			return Result{}, err
+		}
+		return FromMessages(p, sender, api.StitchConcat(messages))
+	}
+
+	sender, ok := client.(api.USSDSender)
+	if !ok {
+		return Result{}, fmt.Errorf("USSD balance checks are %w; read balance messages instead", api.ErrUnsupported)
+	}
+	return CheckUSSD(ctx, sender, p, cmp.Or(cfg.USSDCode, template.USSDCode))
+}
+
+// CheckUSSD dials code and parses the answer. A menu left open by the
+// answer is cancelled.
+func CheckUSSD(ctx context.Context, sender api.USSDSender, p *Parser, code string) (Result, error) {
+	if code == "" {
+		return Result{}, errors.New("no USSD code is configured for the balance check")
+	}
+
+	answer, err := sender.SendUSSDContext(ctx, code)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to dial %s: %w", code, err)
+	}
+	if answer.ExpectsReply() {
+		if err := sender.CancelUSSDContext(ctx); err != nil {
+			return Result{}, fmt.Errorf("failed to end USSD session: %w", err)
+		}
+	}
+
+	r, err := p.Parse(answer.Text)
+	r.Time = time.Now()
+	r.Source = SourceUSSD
+	if err != nil {
+		return r, fmt.Errorf("%w: %q", err, answer.Text)
+	}
+	return r, nil
+}
+
+// FromMessages parses the newest message received from sender that the
+// template reads. Multi-part messages should already be stitched with
+// api.StitchConcat.
+func FromMessages(p *Parser, sender string, messages []api.SMSMessage) (Result, error) {
+	if sender == "" {
+		return Result{}, errors.New("no balance message sender is configured")
+	}
+	sender = api.NormalizeNumber(sender)
+
+	var newest *Result
+	for _, m := range messages {
+		if m.IsSent() || api.NormalizeNumber(m.Number) != sender {
+			continue
+		}
+		if newest != nil && !m.Timestamp.After(newest.Time) {
+			continue
+		}
+
+		r, err := p.Parse(m.Content)
+		if err != nil {
+			continue
+		}
+		r.Time = m.Timestamp
+		r.Source = SourceSMS
+		newest = &r
+	}
+
+	if newest == nil {
+		return Result{}, ErrNoMessage
+	}
+	return *newest, nil
+}
@@ -0,0 +1,69 @@
+package balance
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mifi_app/internal/api"
+)
+
+func amount(f float64) *float64 {
+	return &f
+}
+
+func TestDroppedBelow(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev      *Result
+		cur       Result
+		threshold float64
+		want      bool
+	}{
+		{"first result below", nil, Result{Balance: amount(50)}, 100, true},
+		{"first result above", nil, Result{Balance: amount(150)}, 100, false},
+		{"crossed", &Result{Balance: amount(120)}, Result{Balance: amount(80)}, 100, true},
+		{"at the threshold is not below", &Result{Balance: amount(120)}, Result{Balance: amount(100)}, 100, false},
+		{"already below", &Result{Balance: amount(90)}, Result{Balance: amount(80)}, 100, false},
+		{"previous balance unknown", &Result{}, Result{Balance: amount(80)}, 100, true},
+		{"balance unknown", &Result{Balance: amount(120)}, Result{}, 100, false},
+		{"no threshold", &Result{Balance: amount(120)}, Result{Balance: amount(0)}, 0, false},
+	}
+
+	for _, tt := range tests {
+		if got := DroppedBelow(tt.prev, tt.cur, tt.threshold); got != tt.want {
+			t.Errorf("%s: DroppedBelow = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFromMessages(t *testing.T) {
+	p, err := Compile(Generic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+
+	messages := []api.SMSMessage{
+		{Number: "Airtel", Content: "Your balance is MWK 900", Timestamp: day.Add(2 * time.Hour)},
+		{Number: "AIRTEL", Content: "Your balance is MWK 500", Timestamp: day.Add(time.Hour)},
+		{Number: "AIRTEL", Content: "Thank you for using Airtel", Timestamp: day.Add(3 * time.Hour)},
+		{Number: "AIRTEL", Content: "Your balance is MWK 1", Status: api.SMSSent, Timestamp: day.Add(4 * time.Hour)},
+		{Number: "+265999000111", Content: "Your balance is MWK 5", Timestamp: day.Add(5 * time.Hour)},
+	}
+
+	r, err := FromMessages(p, "airtel", messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BalanceText() != "MWK 900.00" || !r.Time.Equal(day.Add(2*time.Hour)) || r.Source != SourceSMS {
+		t.Errorf("read %q at %v from %s", r.BalanceText(), r.Time, r.Source)
+	}
+
+	if _, err := FromMessages(p, "TNM", messages); !errors.Is(err, ErrNoMessage) {
+		t.Errorf("no message from the sender: %v, want ErrNoMessage", err)
+	}
+	if _, err := FromMessages(p, "", messages); err == nil {
+		t.Error("no sender configured read a balance")
+	}
+}
@@ -0,0 +1,128 @@
+package balance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"mifi_app/internal/config"
+)
+
+// HistoryFileName is the balance history file in the config directory
+const HistoryFileName = "balance_history.json"
+
+// maxHistory is how many results are kept; older ones are dropped
+const maxHistory = 1000
+
+// History is the list of balance results, oldest first, kept on disk. It is
+// safe for concurrent use.
+type History struct {
+	path string
+
+	mu      sync.Mutex
+	results []Result
+}
+
+// DefaultHistoryPath returns the history location in the config directory
+func DefaultHistoryPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, HistoryFileName), nil
+}
+
+// NewHistory returns an empty history that is not saved to disk
+func NewHistory() *History {
+	return &History{}
+}
+
+// OpenHistory loads the history at path. A missing file is an empty history.
+func OpenHistory(path string) (*History, error) {
+	h := &History{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read balance history: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &h.results); err != nil {
+		return nil, fmt.Errorf("failed to parse balance history %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// Add records r and saves the history. It reports false without saving when
+// r is the latest result again, as when the same balance message is read
+// twice.
+func (h *History) Add(r Result) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n := len(h.results); n > 0 {
+		last := h.results[n-1]
+		if last.Source == r.Source && last.Time.Equal(r.Time) && last.Text == r.Text {
+			return false, nil
+		}
+	}
+
+	h.results = append(h.results, r)
+	if len(h.results) > maxHistory {
+		h.results = h.results[len(h.results)-maxHistory:]
+	}
+	return true, h.save()
+}
+
+// Results returns a copy of every result, oldest first
+func (h *History) Results() []Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	results := make([]Result, len(h.results))
+	copy(results, h.results)
+	return results
+}
+
+// Latest returns the newest result, or nil if there is none
+func (h *History) Latest() *Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.results) == 0 {
+		return nil
+	}
+	r := h.results[len(h.results)-1]
+	return &r
+}
+
+// save writes the history. The caller must hold mu.
+func (h *History) save() error {
+	if h.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(h.results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode balance history: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	// Write a temporary file first so a crash cannot truncate the history
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write balance history: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return fmt.Errorf("failed to write balance history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package balance
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryAdd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HistoryFileName)
+	h, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Latest() != nil {
+		t.Fatal("a new history has a latest result")
+	}
+
+	day := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	first := Result{Time: day, Source: SourceSMS, Balance: amount(900), Text: "Your balance is MWK 900"}
+	ussd := first
+	ussd.Source = SourceUSSD
+	later := first
+	later.Time = day.Add(time.Hour)
+
+	steps := []struct {
+		name  string
+		r     Result
+		added bool
+	}{
+		{"first", first, true},
+		{"same message read again", first, false},
+		{"same text from USSD", ussd, true},
+		{"same text later", later, true},
+		{"latest again", later, false},
+	}
+
+	for _, step := range steps {
+		added, err := h.Add(step.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != step.added {
+			t.Errorf("%s: Add = %v, want %v", step.name, added, step.added)
+		}
+	}
+
+	// An older result is recorded again once it is no longer the latest
+	if added, _ := h.Add(first); !added {
+		t.Error("a result other than the latest was dropped")
+	}
+
+	reopened, err := OpenHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := reopened.Results()
+	if len(results) != 4 {
+		t.Fatalf("reopened history holds %d results, want 4", len(results))
+	}
+	if latest := reopened.Latest(); latest == nil || !latest.Time.Equal(first.Time) || *latest.Balance != 900 {
+		t.Errorf("latest = %+v", latest)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	h := NewHistory()
+	day := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < maxHistory+5; i++ {
+		if _, err := h.Add(Result{Time: day.Add(time.Duration(i) * time.Minute), Source: SourceUSSD}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := h.Results()
+	if len(results) != maxHistory || !results[0].Time.Equal(day.Add(5*time.Minute)) {
+		t.Errorf("kept %d results from %v", len(results), results[0].Time)
+	}
+}
@@ -0,0 +1,169 @@
+package balance
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mifi_app/internal/config"
+)
+
+// Generic reads the common ways operators phrase a balance, such as "Your
+// balance is MWK 1,250.00. Data: 2048MB, valid until 06/11/2026". It is used
+// when no configured template matches the operator. Dates are read day
+// first.
+var Generic = config.BalanceTemplate{
+	Balance: `(?i:balance|bal|airtime|credit)\b[^\d\n]{0,20}?(?P<currency>[A-Z]{3}|[$€£₦₹])? ?(?P<amount>\d[\d,]*(?:\.\d+)?)`,
+	Data:    `(?P<amount>\d[\d,]*(?:\.\d+)?)\s?(?P<unit>(?i:[KMGT]B))\b`,
+	Expiry:  `(?i:valid|expir\w*|until)[^\d\n]{0,20}?(?P<date>\d{4}-\d{2}-\d{2}|\d{1,2}[/.-]\d{1,2}[/.-]\d{2,4})`,
+}
+
+// expiryLayouts are tried in order when a template gives no layout
+var expiryLayouts = []string{
+	"2006-01-02",
+	"02/01/2006",
+	"2/1/2006",
+	"02-01-2006",
+	"02.01.2006",
+	"02/01/06",
+	"2/1/06",
+}
+
+// dataUnits converts a data unit to MB
+var dataUnits = map[string]float64{
+	"KB": 1.0 / 1024,
+	"MB": 1,
+	"GB": 1024,
+	"TB": 1024 * 1024,
+}
+
+// Parser reads balance answers with a compiled template
+type Parser struct {
+	Template config.BalanceTemplate
+
+	balance *regexp.Regexp
+	data    *regexp.Regexp
+	expiry  *regexp.Regexp
+}
+
+// Compile checks the patterns of t. At least one pattern must be set.
+func Compile(t config.BalanceTemplate) (*Parser, error) {
+	p := &Parser{Template: t}
+
+	var err error
+	if p.balance, err = compilePattern("balance", t.Balance); err != nil {
+		return nil, err
+	}
+	if p.data, err = compilePattern("data", t.Data); err != nil {
+		return nil, err
+	}
+	if p.expiry, err = compilePattern("expiry", t.Expiry); err != nil {
+		return nil, err
+	}
+
+	if p.balance == nil && p.data == nil && p.expiry == nil {
+		return nil, fmt.Errorf("template %q has no patterns", t.Operator)
+	}
+	return p, nil
+}
+
+func compilePattern(name, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s pattern: %w", name, err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("%s pattern %q has no capture group", name, pattern)
+	}
+	return re, nil
+}
+
+// Select picks the template for operator from templates, falling back to
+// Generic. A template matches when its Operator is contained in operator,
+// ignoring case.
+func Select(templates []config.BalanceTemplate, operator string) config.BalanceTemplate {
+	operator = strings.ToLower(strings.TrimSpace(operator))
+	if operator != "" {
+		for _, t := range templates {
+			if t.Operator != "" && strings.Contains(operator, strings.ToLower(t.Operator)) {
+				return t
+			}
+		}
+	}
+	return Generic
+}
+
+// Parse reads the balance, data and expiry out of text. It fails with
+// ErrNoMatch when none of them is found.
+func (p *Parser) Parse(text string) (Result, error) {
+	r := Result{Text: text}
+
+	if m := match(p.balance, text); m != nil {
+		if amount, ok := parseAmount(group(p.balance, m, "amount")); ok {
+			r.Balance = &amount
+			r.Currency = group(p.balance, m, "currency")
+		}
+	}
+
+	if m := match(p.data, text); m != nil {
+		if amount, ok := parseAmount(group(p.data, m, "amount")); ok {
+			unit := strings.ToUpper(group(p.data, m, "unit"))
+			if scale, ok := dataUnits[unit]; ok {
+				amount *= scale
+			}
+			r.DataMB = &amount
+		}
+	}
+
+	if m := match(p.expiry, text); m != nil {
+		r.Expiry = p.parseDate(group(p.expiry, m, "date"))
+	}
+
+	if r.Balance == nil && r.DataMB == nil && r.Expiry.IsZero() {
+		return r, ErrNoMatch
+	}
+	return r, nil
+}
+
+func (p *Parser) parseDate(s string) time.Time {
+	layouts := expiryLayouts
+	if p.Template.ExpiryLayout != "" {
+		layouts = []string{p.Template.ExpiryLayout}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func match(re *regexp.Regexp, text string) []string {
+	if re == nil {
+		return nil
+	}
+	return re.FindStringSubmatch(text)
+}
+
+// group returns the named group of a match, or the first group when the
+// pattern does not name it and name is the value being read
+func group(re *regexp.Regexp, m []string, name string) string {
+	if i := re.SubexpIndex(name); i > 0 {
+		return m[i]
+	}
+	if name == "amount" || name == "date" {
+		return m[1]
+	}
+	return ""
+}
+
+// parseAmount reads a number with optional thousands separators
+func parseAmount(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	return f, err == nil
+}
@@ -0,0 +1,128 @@
+package balance
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mifi_app/internal/config"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func TestGeneric(t *testing.T) {
+	p, err := Compile(Generic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text    string
+		balance string
+		data    string
+		expiry  time.Time
+		wantErr error
+	}{
+		{"Your balance is MWK 1,250.00. Data: 2048MB, valid until 06/11/2026", "MWK 1250.00", "2.00 GB", date(2026, 11, 6), nil},
+		{"Bal: 35.50 USD", "35.50", "", time.Time{}, nil},
+		{"Airtime credit $12.5", "$ 12.50", "", time.Time{}, nil},
+		{"You have 512 MB left, expires 2026-12-31", "", "512 MB", date(2026, 12, 31), nil},
+		{"Data bundle 1.5GB valid till 1/2/26", "", "1.50 GB", date(2026, 2, 1), nil},
+		{"Remaining 300kb", "", "0 MB", time.Time{}, nil},
+		{"Dial *100# for help", "", "", time.Time{}, ErrNoMatch},
+	}
+
+	for _, tt := range tests {
+		r, err := p.Parse(tt.text)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if got := r.BalanceText(); got != tt.balance {
+			t.Errorf("Parse(%q) balance = %q, want %q", tt.text, got, tt.balance)
+		}
+		if got := r.DataText(); got != tt.data {
+			t.Errorf("Parse(%q) data = %q, want %q", tt.text, got, tt.data)
+		}
+		if !r.Expiry.Equal(tt.expiry) {
+			t.Errorf("Parse(%q) expiry = %v, want %v", tt.text, r.Expiry, tt.expiry)
+		}
+		if r.Text != tt.text {
+			t.Errorf("Parse(%q) kept text %q", tt.text, r.Text)
+		}
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	// Unnamed groups read the amount and date; the layout is month first
+	p, err := Compile(config.BalanceTemplate{
+		Operator:     "TNM",
+		Balance:      `Account: K([\d.]+)`,
+		Expiry:       `exp (\d\d/\d\d/\d{4})`,
+		ExpiryLayout: "01/02/2006",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := p.Parse("Account: K42.75 exp 12/31/2026")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BalanceText() != "42.75" || r.DataMB != nil || !r.Expiry.Equal(date(2026, 12, 31)) {
+		t.Errorf("parsed %q, data %v, expiry %v", r.BalanceText(), r.DataMB, r.Expiry)
+	}
+
+	// A date the layout cannot read leaves the expiry unknown
+	if r, err := p.Parse("exp 31/12/2026"); !errors.Is(err, ErrNoMatch) || !r.Expiry.IsZero() {
+		t.Errorf("unreadable date parsed as %v, %v", r.Expiry, err)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name     string
+		template config.BalanceTemplate
+		wantErr  bool
+	}{
+		{"generic", Generic, false},
+		{"data only", config.BalanceTemplate{Data: `(\d+)MB`}, false},
+		{"no patterns", config.BalanceTemplate{Operator: "Airtel"}, true},
+		{"invalid pattern", config.BalanceTemplate{Balance: `(\d+`}, true},
+		{"no capture group", config.BalanceTemplate{Balance: `\d+`}, true},
+	}
+
+	for _, tt := range tests {
+		if _, err := Compile(tt.template); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Compile error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	templates := []config.BalanceTemplate{
+		{Operator: "Airtel", USSDCode: "*133#"},
+		{Operator: "TNM", USSDCode: "*108#"},
+	}
+
+	tests := []struct {
+		operator string
+		want     string
+	}{
+		{"Airtel MW", "*133#"},
+		{"  tnm mpamba", "*108#"},
+		{"Vodacom", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Select(templates, tt.operator); got.USSDCode != tt.want {
+			t.Errorf("Select(%q) = %+v, want USSD code %q", tt.operator, got, tt.want)
+		}
+	}
+	if got := Select(templates, "Vodacom"); got.Balance != Generic.Balance {
+		t.Error("an unknown operator does not get the generic template")
+	}
+}
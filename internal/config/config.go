@@ -10,8 +10,10 @@ import (
 
 // Config holds the application configuration
 type Config struct {
-	Device DeviceConfig `mapstructure:"device"`
-	App    AppConfig    `mapstructure:"app"`
+	Device  DeviceConfig  `mapstructure:"device"`
+	App     AppConfig     `mapstructure:"app"`
+	SMS     SMSConfig     `mapstructure:"sms"`
+	Balance BalanceConfig `mapstructure:"balance"`
 }
 
 // DeviceConfig holds device-specific configuration
@@ -22,6 +24,8 @@ type DeviceConfig struct {
 	ConnectionTimeout int    `mapstructure:"connection_timeout"`
 	PollInterval      int    `mapstructure:"poll_interval"` // seconds
 	AutoReconnect     bool   `mapstructure:"auto_reconnect"`
+	Backend           string `mapstructure:"backend"`     // auto, zte, hilink, at
+	SerialPort        string `mapstructure:"serial_port"` // AT command port for the at backend
 }
 
 // AppConfig holds application-specific configuration
@@ -34,6 +38,39 @@ type AppConfig struct {
 	LogLevel          string `mapstructure:"log_level"` // debug, info, warn, error
 }
 
+// SMSConfig holds message storage settings
+type SMSConfig struct {
+	CleanupEnabled   bool   `mapstructure:"cleanup_enabled"`   // archive and delete old read messages when storage fills
+	CleanupThreshold int    `mapstructure:"cleanup_threshold"` // percent of a store in use that triggers cleanup
+	CleanupTarget    int    `mapstructure:"cleanup_target"`    // percent in use after cleanup
+	CountryCode      string `mapstructure:"country_code"`      // calling code, such as 265, of numbers written without one
+}
+
+// BalanceConfig holds the scheduled airtime and data balance check
+type BalanceConfig struct {
+	Enabled    bool              `mapstructure:"enabled"`
+	Interval   int               `mapstructure:"interval"`    // minutes between checks
+	Source     string            `mapstructure:"source"`      // ussd or sms
+	Operator   string            `mapstructure:"operator"`    // template to use; empty matches the network provider
+	USSDCode   string            `mapstructure:"ussd_code"`   // overrides the template's code
+	SMSSender  string            `mapstructure:"sms_sender"`  // overrides the template's balance message sender
+	LowBalance float64           `mapstructure:"low_balance"` // notify when the balance drops below this; 0 disables
+	Templates  []BalanceTemplate `mapstructure:"templates"`   // tried before the built-in template
+}
+
+// BalanceTemplate tells how to read one operator's balance answers. The
+// patterns are regular expressions with named groups: amount and currency
+// for the balance, amount and unit for data, date for the expiry.
+type BalanceTemplate struct {
+	Operator     string `mapstructure:"operator"`      // matched against the network provider name
+	USSDCode     string `mapstructure:"ussd_code"`     // code that answers with the balance
+	SMSSender    string `mapstructure:"sms_sender"`    // number or name balance messages come from
+	Balance      string `mapstructure:"balance"`       // airtime balance pattern
+	Data         string `mapstructure:"data"`          // remaining data pattern
+	Expiry       string `mapstructure:"expiry"`        // expiry date pattern
+	ExpiryLayout string `mapstructure:"expiry_layout"` // Go time layout of the date; common layouts are tried if empty
+}
+
 func DefaultConfig() *Config {
 	return &Config{
 		Device: DeviceConfig{
@@ -43,6 +80,8 @@ func DefaultConfig() *Config {
 			ConnectionTimeout: 10,
 			PollInterval:      3,
 			AutoReconnect:     true,
+			Backend:           "auto",
+			SerialPort:        "/dev/ttyUSB2",
 		},
 		App: AppConfig{
 			Theme:             "system",
@@ -52,12 +91,22 @@ func DefaultConfig() *Config {
 			ShowNotifications: true,
 			LogLevel:          "info",
 		},
+		SMS: SMSConfig{
+			CleanupEnabled:   false,
+			CleanupThreshold: 90,
+			CleanupTarget:    75,
+		},
+		Balance: BalanceConfig{
+			Enabled:  false,
+			Interval: 360,
+			Source:   "ussd",
+		},
 	}
 }
 
 func Load() (*Config, error) {
 	// Get config directory
-	configDir, err := getConfigDir()
+	configDir, err := Dir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get config directory: %w", err)
 	}
@@ -75,6 +124,8 @@ func Load() (*Config, error) {
 	cfg := DefaultConfig()
 	viper.SetDefault("device", cfg.Device)
 	viper.SetDefault("app", cfg.App)
+	viper.SetDefault("sms", cfg.SMS)
+	viper.SetDefault("balance", cfg.Balance)
 
 	// Try to read existing config
 	if err := viper.ReadInConfig(); err != nil {
@@ -98,7 +149,7 @@ func Load() (*Config, error) {
 }
 
 func (c *Config) Save() error {
-	configDir, err := getConfigDir()
+	configDir, err := Dir()
 	if err != nil {
 		return fmt.Errorf("failed to get config directory: %w", err)
 	}
@@ -106,6 +157,8 @@ func (c *Config) Save() error {
 	// Set values in viper
 	viper.Set("device", c.Device)
 	viper.Set("app", c.App)
+	viper.Set("sms", c.SMS)
+	viper.Set("balance", c.Balance)
 
 	// Write to file
 	configPath := filepath.Join(configDir, "config.yaml")
@@ -116,7 +169,8 @@ func (c *Config) Save() error {
 	return nil
 }
 
-func getConfigDir() (string, error) {
+// Dir returns the directory holding the config file and the app's local data
+func Dir() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -0,0 +1,260 @@
+package mifisim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ATModem emulates the AT command port of a USB LTE modem on top of a
+// simulated Device
+type ATModem struct {
+	// PIN, if set, must be entered with AT+CPIN before the SIM is ready
+	PIN string
+
+	mu       sync.Mutex
+	device   *Device
+	echo     bool
+	unlocked bool
+	ucs2     bool
+	nextRef  int
+}
+
+// NewATModem returns a modem backed by device, or a fresh NewDevice if nil
+func NewATModem(device *Device) *ATModem {
+	if device == nil {
+		device = NewDevice()
+	}
+	return &ATModem{device: device, echo: true}
+}
+
+// Device returns the simulated device state
+func (m *ATModem) Device() *Device {
+	return m.device
+}
+
+// DeliverSMS stores an unread incoming message
+func (m *ATModem) DeliverSMS(number, content string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.device.AddMessage(number, content, "1", time.Now())
+}
+
+// Serve answers commands read from rw until it returns an error
+func (m *ATModem) Serve(rw io.ReadWriter) error {
+	r := bufio.NewReader(rw)
+
+	for {
+		line, err := r.ReadString('\r')
+		if err != nil {
+			return err
+		}
+		cmd := strings.TrimSpace(line)
+		if cmd == "" {
+			continue
+		}
+
+		m.mu.Lock()
+		echo := m.echo
+		m.mu.Unlock()
+		if echo {
+			io.WriteString(rw, cmd+"\r\n")
+		}
+
+		if strings.HasPrefix(strings.ToUpper(cmd), "AT+CMGS=") {
+			number := strings.Trim(cmd[len("AT+CMGS="):], `"`)
+			if err := m.sendSMS(r, rw, number); err != nil {
+				return err
+			}
+			continue
+		}
+
+		lines, result := m.handle(cmd)
+		var out strings.Builder
+		for _, l := range lines {
+			out.WriteString("\r\n" + l + "\r\n")
+		}
+		out.WriteString("\r\n" + result + "\r\n")
+		if _, err := io.WriteString(rw, out.String()); err != nil {
+			return err
+		}
+	}
+}
+
+// sendSMS prompts for the message text and stores it as sent once Ctrl-Z
+// arrives. ESC cancels.
+func (m *ATModem) sendSMS(r *bufio.Reader, w io.Writer, number string) error {
+	if _, err := io.WriteString(w, "\r\n> "); err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch b {
+		case 0x1a:
+			m.mu.Lock()
+			if !m.ready() {
+				m.mu.Unlock()
+				_, err := io.WriteString(w, "\r\n+CMS ERROR: 310\r\n")
+				return err
+			}
+			content := text.String()
+			if m.ucs2 {
+				number, content = decodeUCS2(number), decodeUCS2(content)
+			}
+			m.device.AddMessage(number, content, "2", time.Now())
+			m.nextRef++
+			ref := m.nextRef
+			m.mu.Unlock()
+			_, err := fmt.Fprintf(w, "\r\n+CMGS: %d\r\n\r\nOK\r\n", ref)
+			return err
+		case 0x1b:
+			_, err := io.WriteString(w, "\r\nOK\r\n")
+			return err
+		default:
+			text.WriteByte(b)
+		}
+	}
+}
+
+// ready reports whether the SIM is unlocked. The caller must hold mu.
+func (m *ATModem) ready() bool {
+	return m.PIN == "" || m.unlocked
+}
+
+// handle runs one command and returns its information lines and final result
+func (m *ATModem) handle(cmd string) ([]string, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d := m.device
+	upper := strings.ToUpper(cmd)
+
+	switch {
+	case upper == "AT":
+		return nil, "OK"
+	case upper == "ATE0":
+		m.echo = false
+		return nil, "OK"
+	case upper == "ATE1":
+		m.echo = true
+		return nil, "OK"
+	case upper == "AT+CMGF=1", strings.HasPrefix(upper, "AT+CSMP="):
+		return nil, "OK"
+	case strings.HasPrefix(upper, "AT+CSCS="):
+		m.ucs2 = strings.Contains(upper, "UCS2")
+		return nil, "OK"
+	case upper == "AT+CPIN?":
+		if !m.ready() {
+			return []string{"+CPIN: SIM PIN"}, "OK"
+		}
+		return []string{"+CPIN: READY"}, "OK"
+	case strings.HasPrefix(upper, "AT+CPIN="):
+		if strings.Trim(cmd[len("AT+CPIN="):], `"`) != m.PIN {
+			return nil, "+CME ERROR: 16"
+		}
+		m.unlocked = true
+		return nil, "OK"
+	case upper == "AT+CGSN":
+		return []string{d.IMEI}, "OK"
+	case upper == "AT+CCID":
+		return []string{"+CCID: " + d.ICCID}, "OK"
+	case upper == "AT+CGMM":
+		return []string{d.ModelName}, "OK"
+	case upper == "AT+CGMR":
+		return []string{d.SoftwareVersion}, "OK"
+	case upper == "AT+CGACT=1,1":
+		d.Connected = true
+		return nil, "OK"
+	case upper == "AT+CGACT=0,1":
+		d.Connected = false
+		return nil, "OK"
+	case upper == "AT+CFUN=1,1", upper == "AT+CPOF":
+		return nil, "OK"
+	}
+
+	if !m.ready() {
+		return nil, "+CME ERROR: 11"
+	}
+
+	switch {
+	case upper == "AT+CSQ":
+		return []string{fmt.Sprintf("+CSQ: %d,99", d.SignalBar*6)}, "OK"
+	case upper == "AT+COPS?":
+		return []string{fmt.Sprintf(`+COPS: 0,0,"%s",%s`, d.NetworkProvider, accessTechnology(d.NetworkType))}, "OK"
+	case upper == "AT+CGPADDR=1":
+		if !d.Connected {
+			return []string{`+CGPADDR: 1,"0.0.0.0"`}, "OK"
+		}
+		return []string{fmt.Sprintf(`+CGPADDR: 1,"%s"`, d.WanIPAddress)}, "OK"
+	case upper == "AT+CPMS?":
+		n, total := len(d.Messages), d.NVCapacity
+		return []string{fmt.Sprintf(`+CPMS: "ME",%d,%d,"ME",%d,%d,"ME",%d,%d`, n, total, n, total, n, total)}, "OK"
+	case strings.HasPrefix(upper, "AT+CMGL"):
+		return m.listMessages(), "OK"
+	case strings.HasPrefix(upper, "AT+CMGD="):
+		id, err := strconv.Atoi(cmd[len("AT+CMGD="):])
+		if err != nil {
+			return nil, "+CMS ERROR: 321"
+		}
+		d.DeleteMessages([]int{id})
+		return nil, "OK"
+	}
+
+	return nil, "ERROR"
+}
+
+// listMessages renders every message in text mode, its header and text on
+// lines of their own, and marks unread ones as read, as real modems do. In
+// the UCS2 character set the number and text are UCS2 hex.
+func (m *ATModem) listMessages() []string {
+	var lines []string
+	for i, msg := range m.device.Messages {
+		stat := "REC READ"
+		switch msg.Tag {
+		case "1":
+			stat = "REC UNREAD"
+			m.device.Messages[i].Tag = "0"
+		case "2":
+			stat = "STO SENT"
+		case "4":
+			stat = "STO UNSENT"
+		}
+
+		number, content := msg.Number, msg.Content
+		if m.ucs2 {
+			number, content = encodeUCS2(number), encodeUCS2(content)
+		}
+		header := fmt.Sprintf(`+CMGL: %d,"%s","%s",,"%s"`, msg.ID, stat, number, formatATDate(msg.Date))
+		lines = append(lines, header+"\r\n"+content)
+	}
+	return lines
+}
+
+func accessTechnology(networkType string) string {
+	switch networkType {
+	case "LTE":
+		return "7"
+	case "WCDMA", "UMTS":
+		return "2"
+	case "HSPA", "HSPA+":
+		return "6"
+	case "EDGE":
+		return "3"
+	}
+	return "0"
+}
+
+// formatATDate renders t as "yy/MM/dd,hh:mm:ss±zz" with zz in quarter hours
+func formatATDate(t time.Time) string {
+	_, offset := t.Zone()
+	return fmt.Sprintf("%s%+03d", t.Format("06/01/02,15:04:05"), offset/(15*60))
+}
@@ -0,0 +1,282 @@
+package mifisim
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// Message is an SMS held in the simulated device memory
+type Message struct {
+	ID      int
+	Number  string
+	Content string
+	Tag     string // 0 read, 1 unread, 2 sent, 4 draft
+	Date    time.Time
+	OnSIM   bool // mem_store 0 rather than device memory
+
+	// Parts of a concatenated message share ConcatRef
+	ConcatRef   int
+	ConcatPart  int
+	ConcatTotal int
+}
+
+// Contact is a phonebook entry held on the simulated SIM or device
+type Contact struct {
+	ID     int
+	Name   string
+	Number string
+	Home   string
+	Office string
+	Email  string
+	Group  string
+	OnSIM  bool // pbm_sim rather than pbm_native
+}
+
+// Station is a WiFi client attached to the simulated hotspot
+type Station struct {
+	Hostname   string
+	IPAddress  string
+	MACAddress string
+}
+
+// Device is the in-memory state of a simulated ZTE MiFi
+type Device struct {
+	ModelName       string
+	IMEI            string
+	ICCID           string
+	SoftwareVersion string
+	NetworkType     string
+	NetworkProvider string
+	SignalBar       int
+	BatteryLevel    int
+	WanIPAddress    string
+	Connected       bool
+
+	SSID         string
+	WiFiPassword string
+	SecurityMode string
+	HideSSID     bool
+	Channel      int
+	MaxClients   int
+
+	Messages []Message
+	Contacts []Contact
+	Stations []Station
+
+	// Message slots in device memory and on the SIM
+	NVCapacity  int
+	SIMCapacity int
+
+	// Balance is the airtime in MWK and DataMB the data bundle left until
+	// DataExpiry, as reported by the USSD menus
+	Balance    float64
+	DataMB     float64
+	DataExpiry time.Time
+
+	TxBytes uint64
+	RxBytes uint64
+
+	nextMessageID int
+	nextConcatRef int
+	nextContactID int
+}
+
+// NewDevice returns a device seeded with plausible MF927U state
+func NewDevice() *Device {
+	now := time.Now()
+
+	d := &Device{
+		ModelName:       "MF927U",
+		IMEI:            "861234050000001",
+		ICCID:           "8926501000000000001",
+		SoftwareVersion: "BD_MF927UV1.0.0B05",
+		NetworkType:     "LTE",
+		NetworkProvider: "Airtel MW",
+		SignalBar:       4,
+		BatteryLevel:    78,
+		WanIPAddress:    "100.64.12.34",
+		Connected:       true,
+
+		SSID:         "MiFi-Sim",
+		WiFiPassword: "simulated",
+		SecurityMode: "WPA2PSK",
+		Channel:      6,
+		MaxClients:   10,
+
+		Stations: []Station{
+			{Hostname: "laptop", IPAddress: "192.168.1.100", MACAddress: "a4:5e:60:00:00:01"},
+			{Hostname: "phone", IPAddress: "192.168.1.101", MACAddress: "3c:22:fb:00:00:02"},
+		},
+
+		NVCapacity:  100,
+		SIMCapacity: 20,
+
+		Balance:    1250,
+		DataMB:     2048,
+		DataExpiry: now.AddDate(0, 0, 20),
+
+		nextMessageID: 1,
+		nextContactID: 1,
+	}
+
+	d.AddSIMMessage("+265999000111", "Welcome to Airtel. Dial *100# for your account.", "0", now.Add(-30*24*time.Hour))
+	d.AddMessage("+265999000111", "Your data bundle expires tomorrow.", "0", now.Add(-48*time.Hour))
+	d.AddMessage("+265888000222", "Hi, are we still meeting at 3?", "0", now.Add(-3*time.Hour))
+	d.AddMessage("AIRTEL", "Dear customer, your balance is MWK 1,250.00", "1", now.Add(-10*time.Minute))
+	d.AddSentMessage("+265 888 000 222", "Yes, see you at 3.", now.Add(-170*time.Minute))
+	d.AddConcatMessage("+265999000111", []string{
+		"Reminder: your 10GB monthly bundle renews on the 1st. ",
+		"Dial *444# to change or cancel your plan before then.",
+	}, "1", now.Add(-5*time.Minute))
+
+	d.AddContact(Contact{Name: "Airtel Care", Number: "+265999000111", OnSIM: true})
+	d.AddContact(Contact{Name: "Chikondi Banda", Number: "+265888000222", Email: "chikondi@example.com"})
+
+	return d
+}
+
+// AddContact stores a phonebook entry and returns its ID
+func (d *Device) AddContact(c Contact) int {
+	c.ID = d.nextContactID
+	d.nextContactID++
+	d.Contacts = append(d.Contacts, c)
+	return c.ID
+}
+
+// DeleteContacts removes the phonebook entries with the given IDs
+func (d *Device) DeleteContacts(ids []int) {
+	remove := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	kept := d.Contacts[:0]
+	for _, c := range d.Contacts {
+		if !remove[c.ID] {
+			kept = append(kept, c)
+		}
+	}
+	d.Contacts = kept
+}
+
+// AddMessage stores a message and returns its ID
+func (d *Device) AddMessage(number, content, tag string, date time.Time) int {
+	id := d.nextMessageID
+	d.nextMessageID++
+
+	d.Messages = append(d.Messages, Message{
+		ID:      id,
+		Number:  number,
+		Content: content,
+		Tag:     tag,
+		Date:    date,
+	})
+
+	return id
+}
+
+// AddSIMMessage stores a message on the SIM rather than in device memory
+func (d *Device) AddSIMMessage(number, content, tag string, date time.Time) int {
+	id := d.AddMessage(number, content, tag, date)
+	d.Messages[len(d.Messages)-1].OnSIM = true
+	return id
+}
+
+// AddSentMessage stores a message sent from the device
+func (d *Device) AddSentMessage(number, content string, date time.Time) int {
+	return d.AddMessage(number, content, "2", date)
+}
+
+// AddConcatMessage stores a multi-part message as separate parts sharing a
+// reference, the way firmware that does not merge parts reports them
+func (d *Device) AddConcatMessage(number string, parts []string, tag string, date time.Time) {
+	d.nextConcatRef++
+	for i, content := range parts {
+		d.AddMessage(number, content, tag, date)
+
+		m := &d.Messages[len(d.Messages)-1]
+		m.ConcatRef = d.nextConcatRef
+		m.ConcatPart = i + 1
+		m.ConcatTotal = len(parts)
+	}
+}
+
+// SetMessageTags changes the tag of the messages with the given IDs
+func (d *Device) SetMessageTags(ids []int, tag string) {
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+
+	for i := range d.Messages {
+		if set[d.Messages[i].ID] {
+			d.Messages[i].Tag = tag
+		}
+	}
+}
+
+// StoredMessages counts the messages on the SIM or in device memory
+func (d *Device) StoredMessages(onSIM bool) int {
+	n := 0
+	for _, m := range d.Messages {
+		if m.OnSIM == onSIM {
+			n++
+		}
+	}
+	return n
+}
+
+// DeleteMessages removes the messages with the given IDs
+func (d *Device) DeleteMessages(ids []int) {
+	remove := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	kept := d.Messages[:0]
+	for _, m := range d.Messages {
+		if !remove[m.ID] {
+			kept = append(kept, m)
+		}
+	}
+	d.Messages = kept
+}
+
+// encodeUCS2 hex-encodes s as UTF-16BE, the way the device stores SMS content
+func encodeUCS2(s string) string {
+	units := utf16.Encode([]rune(s))
+	buf := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		buf = append(buf, byte(u>>8), byte(u))
+	}
+	return hex.EncodeToString(buf)
+}
+
+// formatDate renders t in the device's "YY,MM,DD,HH,MM,SS,+TZ" format, where
+// TZ is the offset in quarter hours
+func formatDate(t time.Time) string {
+	_, offset := t.Zone()
+	quarters := offset / (15 * 60)
+	sign := "+"
+	if quarters < 0 {
+		sign = "-"
+		quarters = -quarters
+	}
+
+	return fmt.Sprintf("%02d,%02d,%02d,%02d,%02d,%02d,%s%d",
+		t.Year()%100, int(t.Month()), t.Day(), t.Hour(), t.Minute(), t.Second(), sign, quarters)
+}
+
+func boolFlag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
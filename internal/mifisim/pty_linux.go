@@ -0,0 +1,43 @@
+package mifisim
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ServePTY serves the modem on a new pseudo-terminal and returns the path of
+// its slave side, which clients open like /dev/ttyUSB2. Closing the returned
+// closer stops the modem.
+func (m *ATModem) ServePTY() (string, io.Closer, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open /dev/ptmx: %w", err)
+	}
+
+	var unlock int32
+	if err := ptyIoctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return "", nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+
+	var n uint32
+	if err := ptyIoctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		master.Close()
+		return "", nil, fmt.Errorf("failed to get pty number: %w", err)
+	}
+
+	go m.Serve(master)
+
+	return fmt.Sprintf("/dev/pts/%d", n), master, nil
+}
+
+func ptyIoctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package mifisim
+
+import (
+	"errors"
+	"io"
+)
+
+// ServePTY is only available on Linux. Use Serve with any io.ReadWriter
+// elsewhere.
+func (m *ATModem) ServePTY() (string, io.Closer, error) {
+	return "", nil, errors.New("pseudo-terminals are only supported on Linux")
+}
@@ -0,0 +1,858 @@
+// Package mifisim serves the ZTE goform API from an in-memory device model so
+// the api client and the UI can be exercised without real hardware.
+package mifisim
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	getEndpoint = "/goform/goform_get_cmd_process"
+	setEndpoint = "/goform/goform_set_cmd_process"
+
+	sessionCookie = "PHPSESSID"
+
+	innerVersion = "WA_INNER_SIM_V1.0"
+	crVersion    = "CR_SIM_V1.0"
+
+	maxLoginAttempts = 5
+	lockoutSeconds   = 300
+
+	// sendTime is how long a send reports processing
+	sendTime = 500 * time.Millisecond
+)
+
+// Options tune the simulated device. The zero value is a healthy legacy
+// firmware with password "admin" and no artificial latency.
+type Options struct {
+	// Password is the admin password; defaults to "admin"
+	Password string
+
+	// Modern emulates newer firmware that uses salted SHA-256 login and
+	// rejects set commands without a valid AD token
+	Modern bool
+
+	// Latency is added to every request
+	Latency time.Duration
+
+	// FailureRate is the fraction of requests answered with HTTP 500
+	FailureRate float64
+
+	// SessionTTL drops sessions idle for longer than this; 0 keeps them forever
+	SessionTTL time.Duration
+
+	// RebootTime is how long the device is unreachable after a reboot
+	RebootTime time.Duration
+}
+
+// Simulator is an http.Handler that behaves like a ZTE MiFi web server
+type Simulator struct {
+	mu       sync.Mutex
+	opts     Options
+	device   *Device
+	sessions map[string]time.Time
+
+	ld             string
+	rd             string
+	attemptsLeft   int
+	lockedUntil    time.Time
+	rebootUntil    time.Time
+	poweredOff     bool
+	forcedFailures map[string]string
+
+	// The last send stays processing until sendDoneAt, then reports
+	// sendResult; sendsToFail counts accepted sends that will fail
+	sendDoneAt  time.Time
+	sendResult  string
+	sendsToFail int
+
+	ussd ussdSession
+
+	server   *http.Server
+	listener net.Listener
+}
+
+// New returns a simulator for a freshly seeded device
+func New(opts Options) *Simulator {
+	if opts.Password == "" {
+		opts.Password = "admin"
+	}
+	if opts.RebootTime == 0 {
+		opts.RebootTime = 5 * time.Second
+	}
+
+	device := NewDevice()
+	if opts.Modern {
+		device.ModelName = "MF971"
+		device.SoftwareVersion = "BD_MF971V1.0.0B08"
+	}
+
+	return &Simulator{
+		opts:           opts,
+		device:         device,
+		sessions:       make(map[string]time.Time),
+		ld:             randomToken(),
+		rd:             randomToken(),
+		attemptsLeft:   maxLoginAttempts,
+		forcedFailures: make(map[string]string),
+	}
+}
+
+// Start serves the simulator on addr (for example "127.0.0.1:0") and returns
+// its base URL
+func (s *Simulator) Start(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen: %w", err)
+	}
+
+	s.listener = listener
+	s.server = &http.Server{Handler: s}
+
+	go s.server.Serve(listener)
+
+	return "http://" + listener.Addr().String(), nil
+}
+
+// Close stops a simulator started with Start
+func (s *Simulator) Close() error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Close()
+}
+
+// Device returns the simulated state. It is shared with the request
+// handlers, so only mutate it before Start or while no requests are running.
+func (s *Simulator) Device() *Device {
+	return s.device
+}
+
+// DeliverSMS places an unread message in the inbox. Like the device, it
+// drops the message without a word when device memory is full.
+func (s *Simulator) DeliverSMS(number, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.device.StoredMessages(false) >= s.device.NVCapacity {
+		return
+	}
+	s.device.AddMessage(number, content, "1", time.Now())
+}
+
+// ExpireSessions drops every session, as the device does after idling
+func (s *Simulator) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]time.Time)
+}
+
+// FailSends makes the next n messages fail after SEND_SMS has accepted
+// them, as when the network rejects a send. Failed messages are not stored.
+func (s *Simulator) FailSends(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendsToFail = n
+}
+
+// FailCommand makes every following goformID command answer with result.
+// An empty result clears the override.
+func (s *Simulator) FailCommand(goformID, result string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if result == "" {
+		delete(s.forcedFailures, goformID)
+		return
+	}
+	s.forcedFailures[goformID] = result
+}
+
+// PowerOn brings the device back after POWEROFF_DEVICE
+func (s *Simulator) PowerOn() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.poweredOff = false
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Latency > 0 {
+		select {
+		case <-time.After(s.opts.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.poweredOff || now.Before(s.rebootUntil) {
+		http.Error(w, "device unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if s.opts.FailureRate > 0 && mathrand.Float64() < s.opts.FailureRate {
+		http.Error(w, "simulated failure", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Path {
+	case getEndpoint:
+		s.handleGet(w, r, s.authenticated(r, now))
+	case setEndpoint:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.handleSet(w, r, s.authenticated(r, now))
+	case "/", "/index.html":
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><title>MiFi Simulator</title></head></html>")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authenticated reports whether r carries a live session and refreshes it
+func (s *Simulator) authenticated(r *http.Request, now time.Time) bool {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+
+	lastSeen, ok := s.sessions[cookie.Value]
+	if !ok {
+		return false
+	}
+
+	if s.opts.SessionTTL > 0 && now.Sub(lastSeen) > s.opts.SessionTTL {
+		delete(s.sessions, cookie.Value)
+		return false
+	}
+
+	s.sessions[cookie.Value] = now
+	return true
+}
+
+func (s *Simulator) handleGet(w http.ResponseWriter, r *http.Request, authed bool) {
+	q := r.URL.Query()
+	cmds := strings.Split(q.Get("cmd"), ",")
+
+	if q.Get("multi_data") != "1" && len(cmds) == 1 {
+		switch cmds[0] {
+		case "sms_data_total":
+			if q.Get("page") != "" {
+				writeJSON(w, map[string]interface{}{"messages": s.messagePage(q, authed)})
+				return
+			}
+		case "sms_cmd_status_info":
+			writeJSON(w, s.sendStatus(authed))
+			return
+		case "sms_capacity_info":
+			writeJSON(w, s.capacityInfo(authed))
+			return
+		case "ussd_data_info":
+			writeJSON(w, s.ussdData(authed))
+			return
+		case "pbm_data_info":
+			writeJSON(w, map[string]interface{}{"pbm_data": s.contactPage(q, authed)})
+			return
+		case "station_list":
+			writeJSON(w, map[string]interface{}{"station_list": s.stationList(authed)})
+			return
+		case "lan_station_list":
+			if s.opts.Modern {
+				writeJSON(w, map[string]interface{}{"lan_station_list": []map[string]string{}})
+				return
+			}
+		}
+	}
+
+	resp := make(map[string]interface{}, len(cmds))
+	for _, cmd := range cmds {
+		if cmd == "" {
+			continue
+		}
+		resp[cmd] = s.field(cmd, authed)
+	}
+
+	writeJSON(w, resp)
+}
+
+// field returns the value of a single get cmd. Login and firmware fields are
+// readable without a session; everything else reads blank, as on the device.
+func (s *Simulator) field(cmd string, authed bool) string {
+	switch cmd {
+	case "loginfo":
+		if authed {
+			return "ok"
+		}
+		return ""
+	case "LD":
+		if s.opts.Modern {
+			return s.ld
+		}
+		return ""
+	case "RD":
+		if s.opts.Modern {
+			return s.rd
+		}
+		return ""
+	case "wa_inner_version":
+		if s.opts.Modern {
+			return innerVersion
+		}
+		return ""
+	case "cr_version":
+		if s.opts.Modern {
+			return crVersion
+		}
+		return ""
+	case "login_lock_time":
+		if remaining := time.Until(s.lockedUntil); remaining > 0 {
+			return itoa(int(remaining.Seconds()))
+		}
+		return "0"
+	case "psw_fail_num_str":
+		return itoa(s.attemptsLeft)
+	case "modem_main_state":
+		return "modem_init_complete"
+	}
+
+	if !authed {
+		return ""
+	}
+
+	d := s.device
+	switch cmd {
+	case "network_type":
+		return d.NetworkType
+	case "network_provider":
+		return d.NetworkProvider
+	case "signalbar":
+		return itoa(d.SignalBar)
+	case "battery_value":
+		return itoa(d.BatteryLevel)
+	case "battery_charging":
+		return "0"
+	case "pin_status":
+		return "0"
+	case "wan_ipaddr":
+		if d.Connected {
+			return d.WanIPAddress
+		}
+		return ""
+	case "ppp_status":
+		if d.Connected {
+			return "ppp_connected"
+		}
+		return "ppp_disconnected"
+	case "wifi_status", "ssid1":
+		return "1"
+	case "sta_count":
+		return itoa(len(d.Stations))
+	case "realtime_tx_thrpt", "realtime_rx_thrpt":
+		if !d.Connected {
+			return "0"
+		}
+		return itoa(mathrand.Intn(200 * 1024))
+	case "realtime_tx_bytes":
+		d.TxBytes += uint64(mathrand.Intn(64 * 1024))
+		return strconv.FormatUint(d.TxBytes, 10)
+	case "realtime_rx_bytes":
+		d.RxBytes += uint64(mathrand.Intn(512 * 1024))
+		return strconv.FormatUint(d.RxBytes, 10)
+	case "imei":
+		return d.IMEI
+	case "iccid":
+		return d.ICCID
+	case "model_name":
+		return d.ModelName
+	case "software_version":
+		return d.SoftwareVersion
+	case "sms_data_total":
+		return itoa(len(d.Messages))
+	case "ussd_write_flag":
+		return s.ussdWriteFlag()
+	}
+
+	return s.wifiField(cmd)
+}
+
+// wifiField answers the WiFi settings under the names the emulated firmware
+// uses: wifi_ssid and friends on legacy units, SSID1 and friends on newer ones
+func (s *Simulator) wifiField(cmd string) string {
+	d := s.device
+
+	if s.opts.Modern {
+		switch cmd {
+		case "SSID1":
+			return d.SSID
+		case "WPAPSK1":
+			return base64.StdEncoding.EncodeToString([]byte(d.WiFiPassword))
+		case "AuthMode":
+			return d.SecurityMode
+		case "HideSSID":
+			return boolFlag(d.HideSSID)
+		case "Channel":
+			return itoa(d.Channel)
+		case "MAX_Access_num":
+			return itoa(d.MaxClients)
+		}
+		return ""
+	}
+
+	switch cmd {
+	case "wifi_ssid":
+		return d.SSID
+	case "wifi_password":
+		return d.WiFiPassword
+	case "security_mode":
+		return d.SecurityMode
+	case "hide_ssid":
+		return boolFlag(d.HideSSID)
+	case "wifi_channel":
+		return itoa(d.Channel)
+	case "max_client_num":
+		return itoa(d.MaxClients)
+	}
+	return ""
+}
+
+func (s *Simulator) messagePage(q map[string][]string, authed bool) []map[string]string {
+	messages := []map[string]string{}
+	if !authed {
+		return messages
+	}
+
+	page, _ := strconv.Atoi(first(q["page"]))
+	perPage, _ := strconv.Atoi(first(q["data_per_page"]))
+	if perPage <= 0 {
+		perPage = 10
+	}
+
+	// mem_store 0 lists the SIM, anything else the device memory
+	onSIM := first(q["mem_store"]) == "0"
+
+	var sorted []Message
+	for _, m := range s.device.Messages {
+		if m.OnSIM == onSIM {
+			sorted = append(sorted, m)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID > sorted[j].ID })
+
+	start := page * perPage
+	if start >= len(sorted) {
+		return messages
+	}
+	end := start + perPage
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+
+	for _, m := range sorted[start:end] {
+		msg := map[string]string{
+			"id":                  itoa(m.ID),
+			"number":              m.Number,
+			"content":             encodeUCS2(m.Content),
+			"tag":                 m.Tag,
+			"date":                formatDate(m.Date),
+			"draft_group_id":      "",
+			"concat_sms_total":    "0",
+			"concat_sms_received": "0",
+		}
+		if m.ConcatTotal > 1 {
+			msg["concat_sms_total"] = itoa(m.ConcatTotal)
+			msg["concat_sms_received"] = itoa(m.ConcatTotal)
+			msg["concat_sms_ref"] = itoa(m.ConcatRef)
+			msg["concat_sms_seq"] = itoa(m.ConcatPart)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages
+}
+
+// sendStatus reports the outcome of the last send, processing for a moment
+// after it was accepted
+func (s *Simulator) sendStatus(authed bool) map[string]string {
+	if !authed {
+		return map[string]string{}
+	}
+
+	result := s.sendResult
+	if time.Now().Before(s.sendDoneAt) {
+		result = "1"
+	}
+	return map[string]string{"sms_cmd": "4", "sms_cmd_status_result": result}
+}
+
+// capacityInfo reports message storage use by store and box
+func (s *Simulator) capacityInfo(authed bool) map[string]string {
+	info := map[string]string{}
+	if !authed {
+		return info
+	}
+
+	d := s.device
+	boxes := map[bool]map[string]int{false: {}, true: {}}
+	for _, m := range d.Messages {
+		switch m.Tag {
+		case "0", "1":
+			boxes[m.OnSIM]["rev"]++
+		case "2", "3":
+			boxes[m.OnSIM]["send"]++
+		default:
+			boxes[m.OnSIM]["draftbox"]++
+		}
+	}
+
+	info["sms_nv_total"] = itoa(d.NVCapacity)
+	info["sms_sim_total"] = itoa(d.SIMCapacity)
+	info["sms_nvused_total"] = itoa(d.StoredMessages(false))
+	for _, box := range []string{"rev", "send", "draftbox"} {
+		info["sms_nv_"+box+"_total"] = itoa(boxes[false][box])
+		info["sms_sim_"+box+"_total"] = itoa(boxes[true][box])
+	}
+	return info
+}
+
+// contactPage returns one page of the phonebook ordered by name
+func (s *Simulator) contactPage(q map[string][]string, authed bool) []map[string]string {
+	contacts := []map[string]string{}
+	if !authed {
+		return contacts
+	}
+
+	page, _ := strconv.Atoi(first(q["page"]))
+	perPage, _ := strconv.Atoi(first(q["data_per_page"]))
+	if perPage <= 0 {
+		perPage = 10
+	}
+
+	sorted := make([]Contact, len(s.device.Contacts))
+	copy(sorted, s.device.Contacts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
+	})
+
+	start := page * perPage
+	if start >= len(sorted) {
+		return contacts
+	}
+	end := min(start+perPage, len(sorted))
+
+	for _, c := range sorted[start:end] {
+		location := "pbm_native"
+		if c.OnSIM {
+			location = "pbm_sim"
+		}
+		contacts = append(contacts, map[string]string{
+			"pbm_id":       itoa(c.ID),
+			"pbm_location": location,
+			"pbm_name":     encodeUCS2(c.Name),
+			"pbm_number":   c.Number,
+			"pbm_anr":      c.Home,
+			"pbm_anr1":     c.Office,
+			"pbm_email":    c.Email,
+			"pbm_group":    c.Group,
+		})
+	}
+	return contacts
+}
+
+// saveContact adds or replaces a contact from a PBM_CONTACT_ADD form and
+// reports whether it was accepted
+func (s *Simulator) saveContact(form url.Values) bool {
+	c := Contact{
+		Name:   decodeUCS2(form.Get("name")),
+		Number: form.Get("mobilephone_num"),
+		OnSIM:  form.Get("location") == "0",
+	}
+	if c.Name == "" || c.Number == "" {
+		return false
+	}
+	if !c.OnSIM {
+		c.Home = form.Get("homephone_num")
+		c.Office = form.Get("officephone_num")
+		c.Email = form.Get("email")
+		c.Group = form.Get("groupchoose")
+	}
+
+	index := form.Get("edit_index")
+	if index == "-1" {
+		s.device.AddContact(c)
+		return true
+	}
+
+	id, err := strconv.Atoi(index)
+	if err != nil {
+		return false
+	}
+	for i := range s.device.Contacts {
+		if s.device.Contacts[i].ID == id {
+			c.ID = id
+			c.OnSIM = s.device.Contacts[i].OnSIM
+			s.device.Contacts[i] = c
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Simulator) stationList(authed bool) []map[string]string {
+	stations := []map[string]string{}
+	if !authed {
+		return stations
+	}
+
+	for _, st := range s.device.Stations {
+		stations = append(stations, map[string]string{
+			"hostname":   st.Hostname,
+			"ipaddress":  st.IPAddress,
+			"macaddress": st.MACAddress,
+		})
+	}
+	return stations
+}
+
+func (s *Simulator) handleSet(w http.ResponseWriter, r *http.Request, authed bool) {
+	goformID := r.PostForm.Get("goformId")
+
+	if s.opts.Modern && !s.validAD(r.PostForm.Get("AD")) {
+		writeResult(w, "failure")
+		return
+	}
+
+	switch goformID {
+	case "LOGIN", "LOGIN_MULTI_USER":
+		s.handleLogin(w, r, goformID)
+		return
+	}
+
+	if !authed {
+		writeResult(w, "failure")
+		return
+	}
+
+	if result, ok := s.forcedFailures[goformID]; ok {
+		writeResult(w, result)
+		return
+	}
+
+	d := s.device
+	switch goformID {
+	case "LOGOUT":
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			delete(s.sessions, cookie.Value)
+		}
+	case "SET_WIFI_SSID1_SETTINGS":
+		if !s.opts.Modern {
+			writeResult(w, "failure")
+			return
+		}
+		d.SSID = r.PostForm.Get("ssid")
+		if pw, err := base64.StdEncoding.DecodeString(r.PostForm.Get("passphrase")); err == nil {
+			d.WiFiPassword = string(pw)
+		}
+		d.SecurityMode = r.PostForm.Get("security_mode")
+		d.HideSSID = r.PostForm.Get("broadcastSsidEnabled") == "1"
+		if mc, err := strconv.Atoi(r.PostForm.Get("MAX_Access_num")); err == nil {
+			d.MaxClients = mc
+		}
+	case "SET_WIFI_SSID_PASSWORD":
+		if s.opts.Modern {
+			writeResult(w, "failure")
+			return
+		}
+		d.SSID = r.PostForm.Get("wifi_ssid")
+		d.WiFiPassword = r.PostForm.Get("wifi_password")
+		d.SecurityMode = r.PostForm.Get("security_mode")
+		d.HideSSID = r.PostForm.Get("hide_ssid") == "1"
+		if ch, err := strconv.Atoi(r.PostForm.Get("wifi_channel")); err == nil {
+			d.Channel = ch
+		}
+		if mc, err := strconv.Atoi(r.PostForm.Get("max_client_num")); err == nil {
+			d.MaxClients = mc
+		}
+	case "CONNECT_NETWORK":
+		d.Connected = true
+	case "DISCONNECT_NETWORK":
+		d.Connected = false
+	case "SEND_SMS":
+		s.sendDoneAt = time.Now().Add(sendTime)
+		if s.sendsToFail > 0 {
+			s.sendsToFail--
+			s.sendResult = "2"
+			break
+		}
+		s.sendResult = "3"
+
+		content := decodeUCS2(r.PostForm.Get("MessageBody"))
+		for _, number := range strings.Split(r.PostForm.Get("Number"), ";") {
+			if number != "" {
+				d.AddMessage(number, content, "2", time.Now())
+			}
+		}
+	case "USSD_PROCESS":
+		if !s.handleUSSD(r.PostForm) {
+			writeResult(w, "failure")
+			return
+		}
+	case "PBM_CONTACT_ADD":
+		if !s.saveContact(r.PostForm) {
+			writeResult(w, "failure")
+			return
+		}
+	case "PBM_CONTACT_DEL":
+		var ids []int
+		for _, id := range strings.Split(r.PostForm.Get("delete_id"), ",") {
+			if i, err := strconv.Atoi(id); err == nil {
+				ids = append(ids, i)
+			}
+		}
+		d.DeleteContacts(ids)
+	case "DELETE_SMS":
+		d.DeleteMessages(messageIDs(r.PostForm.Get("msg_id")))
+	case "SET_MSG_READ":
+		tag := r.PostForm.Get("tag")
+		if tag != "0" && tag != "1" {
+			writeResult(w, "failure")
+			return
+		}
+		d.SetMessageTags(messageIDs(r.PostForm.Get("msg_id")), tag)
+	case "REBOOT_DEVICE":
+		s.sessions = make(map[string]time.Time)
+		s.rebootUntil = time.Now().Add(s.opts.RebootTime)
+	case "POWEROFF_DEVICE":
+		s.sessions = make(map[string]time.Time)
+		s.poweredOff = true
+	default:
+		writeResult(w, "failure")
+		return
+	}
+
+	s.rd = randomToken()
+	writeResult(w, "success")
+}
+
+func (s *Simulator) handleLogin(w http.ResponseWriter, r *http.Request, goformID string) {
+	if time.Now().Before(s.lockedUntil) {
+		writeResult(w, "3")
+		return
+	}
+
+	// Legacy firmware only knows the plain LOGIN goform
+	if goformID == "LOGIN_MULTI_USER" && !s.opts.Modern {
+		writeResult(w, "failure")
+		return
+	}
+
+	var expected string
+	if s.opts.Modern {
+		expected = sha256Hex(sha256Hex(s.opts.Password) + s.ld)
+		s.ld = randomToken()
+	} else {
+		expected = base64.StdEncoding.EncodeToString([]byte(s.opts.Password))
+	}
+
+	if r.PostForm.Get("password") != expected {
+		s.attemptsLeft--
+		if s.attemptsLeft <= 0 {
+			s.attemptsLeft = 0
+			s.lockedUntil = time.Now().Add(lockoutSeconds * time.Second)
+		}
+		writeResult(w, "3")
+		return
+	}
+
+	s.attemptsLeft = maxLoginAttempts
+
+	session := randomToken()
+	s.sessions[session] = time.Now()
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: session, Path: "/"})
+
+	s.rd = randomToken()
+	writeResult(w, "0")
+}
+
+// validAD checks the token newer firmware expects on every set command
+func (s *Simulator) validAD(ad string) bool {
+	return ad == md5Hex(md5Hex(innerVersion+crVersion)+s.rd)
+}
+
+// decodeUCS2 reverses the web UI's hex encoding of MessageBody. Bodies that
+// are not UCS-2 hex are stored unchanged.
+func decodeUCS2(s string) string {
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw)%2 != 0 {
+		return s
+	}
+
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i < len(raw); i += 2 {
+		units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// messageIDs parses a "1;2;3;" msg_id list
+func messageIDs(list string) []int {
+	var ids []int
+	for _, id := range strings.Split(list, ";") {
+		if i, err := strconv.Atoi(id); err == nil {
+			ids = append(ids, i)
+		}
+	}
+	return ids
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeResult(w http.ResponseWriter, result string) {
+	writeJSON(w, map[string]string{"result": result})
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func randomToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
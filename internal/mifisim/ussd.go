@@ -0,0 +1,163 @@
+package mifisim
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ussdTime is how long the network takes to answer a USSD request
+const ussdTime = 300 * time.Millisecond
+
+// Menus of the simulated operator's USSD service
+const (
+	ussdMenuMain    = "main"
+	ussdMenuBundles = "bundles"
+)
+
+// ussdBundles are the data bundles sold through *100#
+var ussdBundles = []struct {
+	Name  string
+	MB    float64
+	Price float64
+}{
+	{"1GB", 1024, 500},
+	{"5GB", 5120, 2000},
+}
+
+// ussdSession is the simulated USSD exchange. *100# opens a menu and *133#
+// answers with the balance; other codes fail.
+type ussdSession struct {
+	// menu is waiting for a reply; empty when no session is open
+	menu string
+
+	// The answer is pending until readyAt
+	readyAt time.Time
+	failed  bool
+	text    string
+	action  string // 0 done, 1 reply expected
+}
+
+// handleUSSD runs a USSD_PROCESS command and reports whether it was accepted
+func (s *Simulator) handleUSSD(form url.Values) bool {
+	switch form.Get("USSD_operator") {
+	case "ussd_send":
+		s.ussd = ussdSession{readyAt: time.Now().Add(ussdTime)}
+		switch form.Get("USSD_send_number") {
+		case "*100#":
+			s.ussdMenu(ussdMenuMain, "")
+		case "*133#":
+			s.ussdEnd(s.balanceText())
+		default:
+			s.ussd.failed = true
+		}
+	case "ussd_reply":
+		if s.ussd.menu == "" {
+			return false
+		}
+		s.ussd.readyAt = time.Now().Add(ussdTime)
+		s.ussdReply(form.Get("USSD_reply_number"))
+	case "ussd_cancel":
+		s.ussd = ussdSession{}
+	default:
+		return false
+	}
+	return true
+}
+
+// ussdReply answers a choice in the open menu
+func (s *Simulator) ussdReply(choice string) {
+	if s.ussd.menu == ussdMenuMain {
+		switch choice {
+		case "1":
+			s.ussdEnd(s.balanceText())
+		case "2":
+			s.ussdMenu(ussdMenuBundles, "")
+		case "0":
+			s.ussdEnd("Thank you for using Airtel.")
+		default:
+			s.ussdMenu(ussdMenuMain, "Invalid choice.\n")
+		}
+		return
+	}
+
+	if choice == "0" {
+		s.ussdMenu(ussdMenuMain, "")
+		return
+	}
+	i, err := strconv.Atoi(choice)
+	if err != nil || i < 1 || i > len(ussdBundles) {
+		s.ussdMenu(ussdMenuBundles, "Invalid choice.\n")
+		return
+	}
+
+	bundle := ussdBundles[i-1]
+	if s.device.Balance < bundle.Price {
+		s.ussdEnd(fmt.Sprintf("Insufficient balance for the %s bundle. %s", bundle.Name, s.balanceText()))
+		return
+	}
+	s.device.Balance -= bundle.Price
+	s.device.DataMB += bundle.MB
+	s.device.DataExpiry = time.Now().AddDate(0, 0, 30)
+	s.ussdEnd(fmt.Sprintf("You have bought the %s data bundle. %s", bundle.Name, s.balanceText()))
+}
+
+// ussdMenu shows menu, after prefix, and waits for a reply
+func (s *Simulator) ussdMenu(menu, prefix string) {
+	text := "Airtel Menu\n1. My balance\n2. Buy data bundles\n0. Exit"
+	if menu == ussdMenuBundles {
+		text = "Data bundles\n"
+		for i, bundle := range ussdBundles {
+			text += fmt.Sprintf("%d. %s – MWK %.0f\n", i+1, bundle.Name, bundle.Price)
+		}
+		text += "0. Back"
+	}
+
+	s.ussd.menu = menu
+	s.ussd.text = prefix + text
+	s.ussd.action = "1"
+}
+
+// ussdEnd answers text and closes the session
+func (s *Simulator) ussdEnd(text string) {
+	s.ussd.menu = ""
+	s.ussd.text = text
+	s.ussd.action = "0"
+}
+
+func (s *Simulator) balanceText() string {
+	d := s.device
+	text := fmt.Sprintf("Your balance is MWK %.2f.", d.Balance)
+	if d.DataMB > 0 {
+		text += fmt.Sprintf(" Data: %.0fMB, valid until %s.", d.DataMB, d.DataExpiry.Format("02/01/2006"))
+	}
+	return text
+}
+
+// ussdWriteFlag reports the progress of the last USSD request: 15 while
+// waiting for the network, 16 once the answer is ready and 4 on failure
+func (s *Simulator) ussdWriteFlag() string {
+	switch {
+	case s.ussd.readyAt.IsZero():
+		return "0"
+	case time.Now().Before(s.ussd.readyAt):
+		return "15"
+	case s.ussd.failed:
+		return "4"
+	}
+	return "16"
+}
+
+// ussdData returns the answer to the last USSD request, UCS-2 encoded
+func (s *Simulator) ussdData(authed bool) map[string]string {
+	if !authed || s.ussdWriteFlag() != "16" {
+		return map[string]string{"ussd_data": "", "ussd_action": "", "ussd_dcs": ""}
+	}
+
+	return map[string]string{
+		"ussd_data":   encodeUCS2(s.ussd.text),
+		"ussd_action": s.ussd.action,
+		"ussd_dcs":    "72",
+	}
+}
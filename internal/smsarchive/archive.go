@@ -0,0 +1,240 @@
+// Package smsarchive keeps every message the app has synced in a JSON file
+// under the config directory, so history survives the device filling up,
+// deleting messages or being reset.
+package smsarchive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"mifi_app/internal/api"
+	"mifi_app/internal/config"
+)
+
+// FileName is the archive file in the config directory
+const FileName = "sms_archive.json"
+
+// Entry is an archived message
+type Entry struct {
+	IMEI      string         `json:"imei"`
+	Message   api.SMSMessage `json:"message"`
+	FirstSeen time.Time      `json:"first_seen"`
+
+	// DeletedAt is when the message was first missing from the device
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// Deleted reports whether the message is no longer on the device
+func (e *Entry) Deleted() bool {
+	return !e.DeletedAt.IsZero()
+}
+
+// Archive is the set of archived messages. It is safe for concurrent use.
+type Archive struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+	dirty   bool
+}
+
+// DefaultPath returns the archive location in the config directory
+func DefaultPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Open loads the archive at path. A missing file is an empty archive.
+func Open(path string) (*Archive, error) {
+	a := &Archive{path: path, entries: make(map[string]*Entry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SMS archive: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &a.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse SMS archive %s: %w", path, err)
+	}
+
+	return a, nil
+}
+
+// Record archives messages, which must be everything currently on the
+// device identified by imei. Archived messages of that device that are
+// missing from messages are marked deleted. It returns the number of
+// messages archived for the first time and whether the archive has changes
+// to save.
+func (a *Archive) Record(imei string, messages []api.SMSMessage) (added int, changed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	present := make(map[string]bool, len(messages))
+
+	for _, m := range messages {
+		k := key(imei, m)
+		present[k] = true
+
+		e, ok := a.entries[k]
+		if ok && !sameMessage(e.Message, m) {
+			// The device reused the index after a delete or reset; keep
+			// the old message under its own key
+			a.retire(k, e, now)
+			ok = false
+		}
+
+		if !ok {
+			a.entries[k] = &Entry{IMEI: imei, Message: m, FirstSeen: now}
+			a.dirty = true
+			added++
+			continue
+		}
+
+		if e.Message.Status != m.Status || e.Deleted() {
+			e.Message = m
+			e.DeletedAt = time.Time{}
+			a.dirty = true
+		}
+	}
+
+	for k, e := range a.entries {
+		if e.IMEI == imei && !e.Deleted() && !present[k] {
+			e.DeletedAt = now
+			a.dirty = true
+		}
+	}
+
+	return added, a.dirty
+}
+
+// retire moves e from k to a key that cannot clash with a live message
+func (a *Archive) retire(k string, e *Entry, now time.Time) {
+	delete(a.entries, k)
+	if !e.Deleted() {
+		e.DeletedAt = now
+	}
+	a.entries[k+"@"+strconv.FormatInt(e.Message.Timestamp.Unix(), 10)] = e
+	a.dirty = true
+}
+
+// Save writes the archive if it changed since it was loaded or last saved
+func (a *Archive) Save() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(a.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode SMS archive: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	// Write a temporary file first so a crash cannot truncate the archive
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write SMS archive: %w", err)
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		return fmt.Errorf("failed to write SMS archive: %w", err)
+	}
+
+	a.dirty = false
+	return nil
+}
+
+// Len returns the number of archived messages
+func (a *Archive) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.entries)
+}
+
+// Search returns the entries matching q, newest first
+func (a *Archive) Search(q Query) []Entry {
+	return a.SearchWith(q, nil)
+}
+
+// SearchWith is Search over the archive and messages that could not be
+// recorded, e.g. because the device is not identified yet. A message already
+// archived under the same index is returned once.
+func (a *Archive) SearchWith(q Query, unrecorded []api.SMSMessage) []Entry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var matches []*Entry
+	for _, e := range a.entries {
+		if q.Matches(e) {
+			matches = append(matches, e)
+		}
+	}
+
+	for _, m := range unrecorded {
+		if a.archived(m) {
+			continue
+		}
+		e := &Entry{Message: m}
+		if q.Matches(e) {
+			matches = append(matches, e)
+		}
+	}
+	sortEntries(matches)
+
+	results := make([]Entry, len(matches))
+	for i, e := range matches {
+		results[i] = *e
+	}
+	return results
+}
+
+// archived reports whether m is archived for any device
+func (a *Archive) archived(m api.SMSMessage) bool {
+	for _, e := range a.entries {
+		if e.Message.Store == m.Store && e.Message.ID == m.ID && sameMessage(e.Message, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// key identifies a message on one device
+func key(imei string, m api.SMSMessage) string {
+	return imei + "/" + m.Store.String() + "/" + m.ID
+}
+
+// sameMessage reports whether two messages with the same index are the same
+// message rather than a reused index
+func sameMessage(a, b api.SMSMessage) bool {
+	return a.Number == b.Number && a.Timestamp.Equal(b.Timestamp)
+}
+
+func sortEntries(entries []*Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		ti, tj := entries[i].Message.Timestamp, entries[j].Message.Timestamp
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		idi, _ := strconv.Atoi(entries[i].Message.ID)
+		idj, _ := strconv.Atoi(entries[j].Message.ID)
+		return idi > idj
+	})
+}
@@ -0,0 +1,130 @@
+package smsarchive
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mifi_app/internal/api"
+)
+
+func message(id, number, content string, at time.Time) api.SMSMessage {
+	return api.SMSMessage{ID: id, Number: number, Content: content, Status: api.SMSRead, Timestamp: at}
+}
+
+func TestSearchWith(t *testing.T) {
+	day := time.Date(2024, 3, 15, 10, 30, 0, 0, time.Local)
+	archived := []api.SMSMessage{
+		message("1", "+265999000111", "Your bundle is active", day),
+		message("2", "+265888000222", "See you at 5", day.Add(time.Hour)),
+	}
+
+	a, err := Open(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Record("861234050000001", archived)
+
+	tests := []struct {
+		name       string
+		query      string
+		unrecorded []api.SMSMessage
+		want       []string
+	}{
+		{"archive only", "", nil, []string{"2", "1"}},
+		{"device messages added", "", []api.SMSMessage{message("3", "+265999000111", "Balance MWK 100", day.Add(2*time.Hour))}, []string{"3", "2", "1"}},
+		{"archived message not repeated", "", []api.SMSMessage{archived[0], message("3", "+265999000111", "Balance MWK 100", day.Add(2*time.Hour))}, []string{"3", "2", "1"}},
+		{"reused index kept", "", []api.SMSMessage{message("1", "+265999000111", "New message", day.Add(3*time.Hour))}, []string{"1", "2", "1"}},
+		{"device messages filtered", "balance", []api.SMSMessage{message("3", "+265999000111", "Balance MWK 100", day), message("4", "+265999000111", "Hello", day)}, []string{"3"}},
+		{"deleted filter skips device messages", "is:deleted", []api.SMSMessage{message("3", "+265999000111", "Balance MWK 100", day)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range a.SearchWith(q, tt.unrecorded) {
+				got = append(got, e.Message.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("SearchWith(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("SearchWith(%q) = %v, want %v", tt.query, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	const imei = "861234050000001"
+	day := time.Date(2024, 3, 15, 10, 30, 0, 0, time.Local)
+	first := message("1", "+265999000111", "Your bundle is active", day)
+	second := message("2", "+265888000222", "See you at 5", day.Add(time.Hour))
+	reused := message("1", "+265888000222", "New message", day.Add(2*time.Hour))
+	unread := second
+	unread.Status = api.SMSUnread
+
+	path := filepath.Join(t.TempDir(), FileName)
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name        string
+		messages    []api.SMSMessage
+		wantAdded   int
+		wantChanged bool
+		wantLen     int
+		wantDeleted int
+	}{
+		{"first sync", []api.SMSMessage{first, second}, 2, true, 2, 0},
+		{"nothing new", []api.SMSMessage{first, second}, 0, false, 2, 0},
+		{"status changed", []api.SMSMessage{first, unread}, 0, true, 2, 0},
+		{"deleted on the device", []api.SMSMessage{unread}, 0, true, 2, 1},
+		{"still deleted", []api.SMSMessage{unread}, 0, false, 2, 1},
+		{"index reused", []api.SMSMessage{reused, unread}, 1, true, 3, 1},
+	}
+
+	for _, step := range steps {
+		added, changed := a.Record(imei, step.messages)
+		if added != step.wantAdded || changed != step.wantChanged {
+			t.Fatalf("%s: Record = %d, %v, want %d, %v", step.name, added, changed, step.wantAdded, step.wantChanged)
+		}
+		if a.Len() != step.wantLen {
+			t.Fatalf("%s: %d archived, want %d", step.name, a.Len(), step.wantLen)
+		}
+		q, _ := ParseQuery("is:deleted")
+		if n := len(a.Search(q)); n != step.wantDeleted {
+			t.Fatalf("%s: %d deleted, want %d", step.name, n, step.wantDeleted)
+		}
+		if err := a.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another device's messages are kept apart
+	if added, _ := a.Record("861234050000002", []api.SMSMessage{first}); added != 1 || a.Len() != 4 {
+		t.Errorf("other device: added %d, %d archived", added, a.Len())
+	}
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 4 {
+		t.Errorf("reopened archive holds %d messages, want 4", reopened.Len())
+	}
+	if _, changed := reopened.Record(imei, []api.SMSMessage{reused, unread}); changed {
+		t.Error("recording the same messages after reopening changed the archive")
+	}
+}
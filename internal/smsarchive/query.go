@@ -0,0 +1,127 @@
+package smsarchive
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"mifi_app/internal/api"
+)
+
+// queryDateLayout is the date format of from: and to: filters
+const queryDateLayout = "2006-01-02"
+
+// Query selects archived messages. Zero fields match everything.
+type Query struct {
+	// Terms must all appear in the content or number, ignoring case
+	Terms []string
+
+	// Number must appear in the normalized number, or be the whole number
+	// in another form. It is kept without its trunk prefix or default
+	// country code so that it matches both forms.
+	Number string
+
+	// From and To bound the message time; To is exclusive
+	From time.Time
+	To   time.Time
+
+	// Deleted limits the results to messages no longer on the device
+	Deleted bool
+}
+
+// ParseQuery reads a search box query. Words are matched as text, and
+// number:, from: and to: filter by sender and by date (YYYY-MM-DD, both
+// days included). is:deleted keeps only messages gone from the device.
+func ParseQuery(s string) (Query, error) {
+	var q Query
+
+	for _, field := range strings.Fields(s) {
+		name, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			q.Terms = append(q.Terms, strings.ToLower(field))
+			continue
+		}
+
+		switch strings.ToLower(name) {
+		case "number":
+			q.Number = numberFilter(value)
+		case "from":
+			day, err := time.ParseInLocation(queryDateLayout, value, time.Local)
+			if err != nil {
+				return Query{}, fmt.Errorf("from: needs a date like 2024-01-31, not %q", value)
+			}
+			q.From = day
+		case "to":
+			day, err := time.ParseInLocation(queryDateLayout, value, time.Local)
+			if err != nil {
+				return Query{}, fmt.Errorf("to: needs a date like 2024-01-31, not %q", value)
+			}
+			q.To = day.AddDate(0, 0, 1)
+		case "is":
+			if strings.ToLower(value) != "deleted" {
+				return Query{}, fmt.Errorf("unknown filter %q", field)
+			}
+			q.Deleted = true
+		default:
+			// Not a filter, e.g. a time such as 10:30
+			q.Terms = append(q.Terms, strings.ToLower(field))
+		}
+	}
+
+	return q, nil
+}
+
+// IsZero reports whether q matches every message
+func (q Query) IsZero() bool {
+	return len(q.Terms) == 0 && q.Number == "" && q.From.IsZero() && q.To.IsZero() && !q.Deleted
+}
+
+// Matches reports whether e is selected by q
+func (q Query) Matches(e *Entry) bool {
+	m := e.Message
+
+	if q.Deleted && !e.Deleted() {
+		return false
+	}
+	if !q.From.IsZero() && m.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !m.Timestamp.Before(q.To) {
+		return false
+	}
+	if q.Number != "" && !strings.Contains(api.NormalizeNumber(m.Number), q.Number) &&
+		api.NumberKey(m.Number) != api.NumberKey(q.Number) {
+		return false
+	}
+
+	content := strings.ToLower(m.Content)
+	number := strings.ToLower(m.Number)
+	for _, term := range q.Terms {
+		if !strings.Contains(content, term) && !strings.Contains(number, term) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// numberFilter normalizes the number of a number: filter and drops the
+// trunk prefix or the default country code, so "0999" and "+265999" find
+// the same messages as "999"
+func numberFilter(value string) string {
+	number := api.NormalizeNumber(value)
+	digits, international := strings.CutPrefix(number, "+")
+	if !international {
+		// NormalizeNumber keeps the 00 of numbers this short
+		digits, international = strings.CutPrefix(number, "00")
+	}
+	if !international {
+		return strings.TrimPrefix(number, "0")
+	}
+	if cc := api.DefaultCountryCode(); cc != "" {
+		if national, ok := strings.CutPrefix(digits, cc); ok && national != "" {
+			return national
+		}
+	}
+	return digits
+}
@@ -0,0 +1,133 @@
+package smsarchive
+
+import (
+	"testing"
+	"time"
+
+	"mifi_app/internal/api"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    Query
+		wantErr bool
+	}{
+		{"", Query{}, false},
+		{"Bundle  ACTIVE", Query{Terms: []string{"bundle", "active"}}, false},
+		{"number:0999", Query{Number: "999"}, false},
+		{"number:+265-999", Query{Number: "265999"}, false},
+		{"number:00265", Query{Number: "265"}, false},
+		{"number:airtel", Query{Number: "AIRTEL"}, false},
+		{"from:2024-03-01 to:2024-03-31", Query{
+			From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local),
+			To:   time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local),
+		}, false},
+		{"is:deleted", Query{Deleted: true}, false},
+		{"at 10:30", Query{Terms: []string{"at", "10:30"}}, false},
+		{"from:yesterday", Query{}, true},
+		{"to:2024-13-01", Query{}, true},
+		{"is:unread", Query{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseQuery(tt.query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseQuery(%q) error = %v", tt.query, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(got.Terms) != len(tt.want.Terms) || got.Number != tt.want.Number || !got.From.Equal(tt.want.From) ||
+			!got.To.Equal(tt.want.To) || got.Deleted != tt.want.Deleted {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got.Terms {
+			if got.Terms[i] != tt.want.Terms[i] {
+				t.Errorf("ParseQuery(%q) terms = %q, want %q", tt.query, got.Terms, tt.want.Terms)
+			}
+		}
+	}
+}
+
+func TestQueryMatchesNumber(t *testing.T) {
+	defer api.SetDefaultCountryCode("")
+
+	international := &Entry{Message: api.SMSMessage{Number: "+265999123456"}}
+	national := &Entry{Message: api.SMSMessage{Number: "0999123456"}}
+	other := &Entry{Message: api.SMSMessage{Number: "+265888000222"}}
+	sender := &Entry{Message: api.SMSMessage{Number: "Airtel"}}
+
+	tests := []struct {
+		countryCode string
+		query       string
+		want        []*Entry
+	}{
+		{"265", "number:0999", []*Entry{international, national}},
+		{"265", "number:+265999", []*Entry{international, national}},
+		{"265", "number:00265999", []*Entry{international, national}},
+		{"265", "number:999123456", []*Entry{international, national}},
+		{"265", "number:+265999123456", []*Entry{international, national}},
+		{"265", "number:0888", []*Entry{other}},
+		{"265", "number:airtel", []*Entry{sender}},
+		{"", "number:0999", []*Entry{international, national}},
+		{"", "number:+265999123456", []*Entry{international, national}},
+		{"", "number:0999123456", []*Entry{international, national}},
+		{"", "number:+265", []*Entry{international, other}},
+		{"", "number:0777", nil},
+	}
+
+	for _, tt := range tests {
+		if err := api.SetDefaultCountryCode(tt.countryCode); err != nil {
+			t.Fatal(err)
+		}
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := make(map[*Entry]bool)
+		for _, e := range tt.want {
+			want[e] = true
+		}
+		for _, e := range []*Entry{international, national, other, sender} {
+			if got := q.Matches(e); got != want[e] {
+				t.Errorf("country code %q: %q matches %s = %v, want %v", tt.countryCode, tt.query, e.Message.Number, got, want[e])
+			}
+		}
+	}
+}
+
+func TestQueryMatches(t *testing.T) {
+	day := time.Date(2024, 3, 15, 10, 30, 0, 0, time.Local)
+	e := &Entry{Message: api.SMSMessage{Number: "+265999123456", Content: "Your bundle is ACTIVE", Timestamp: day}}
+	deleted := &Entry{Message: e.Message, DeletedAt: day.Add(time.Hour)}
+
+	tests := []struct {
+		query string
+		entry *Entry
+		want  bool
+	}{
+		{"", e, true},
+		{"bundle active", e, true},
+		{"bundle expired", e, false},
+		{"999123", e, true},
+		{"from:2024-03-15 to:2024-03-15", e, true},
+		{"from:2024-03-16", e, false},
+		{"to:2024-03-14", e, false},
+		{"is:deleted", e, false},
+		{"is:deleted", deleted, true},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.Matches(tt.entry); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
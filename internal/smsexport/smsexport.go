@@ -0,0 +1,249 @@
+// Package smsexport writes messages to CSV, JSON or the XML format of the
+// Android "SMS Backup & Restore" app, so they can be handed over as evidence
+// or restored onto a phone.
+package smsexport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"mifi_app/internal/api"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+	FormatXML  Format = "xml" // SMS Backup & Restore
+)
+
+// Formats lists the supported formats
+var Formats = []Format{FormatCSV, FormatJSON, FormatXML}
+
+// ParseFormat returns the format named s
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown export format %q, use csv, json or xml", s)
+}
+
+// FormatForPath picks the format from the file extension
+func FormatForPath(path string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "" {
+		return "", fmt.Errorf("cannot tell the export format of %q, give it a .csv, .json or .xml extension", path)
+	}
+	return ParseFormat(ext)
+}
+
+// Description names the format for the user
+func (f Format) Description() string {
+	switch f {
+	case FormatCSV:
+		return "CSV spreadsheet"
+	case FormatJSON:
+		return "JSON"
+	case FormatXML:
+		return "SMS Backup & Restore XML"
+	}
+	return string(f)
+}
+
+// Write exports messages to w, oldest first. Multi-part messages should
+// already be stitched with api.StitchConcat.
+func Write(w io.Writer, format Format, messages []api.SMSMessage) error {
+	sorted := make([]api.SMSMessage, len(messages))
+	copy(sorted, messages)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, sorted)
+	case FormatJSON:
+		return writeJSON(w, sorted)
+	case FormatXML:
+		return writeBackupXML(w, sorted)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// WriteFile exports messages to a new file at path
+func WriteFile(path string, format Format, messages []api.SMSMessage) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	if err := Write(f, format, messages); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to export messages: %w", err)
+	}
+	return f.Close()
+}
+
+// direction describes who sent a message
+func direction(m api.SMSMessage) string {
+	switch m.Status {
+	case api.SMSSent:
+		return "sent"
+	case api.SMSDraft:
+		return "draft"
+	}
+	return "received"
+}
+
+func writeCSV(w io.Writer, messages []api.SMSMessage) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"id", "number", "direction", "status", "store", "date", "content"}); err != nil {
+		return err
+	}
+	for _, m := range messages {
+		record := []string{
+			m.ID,
+			m.Number,
+			direction(m),
+			m.Status.String(),
+			m.Store.String(),
+			m.Timestamp.Format(time.RFC3339),
+			m.Content,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// jsonMessage is the JSON export record, with readable enums
+type jsonMessage struct {
+	ID        string    `json:"id"`
+	PartIDs   []string  `json:"part_ids,omitempty"`
+	Number    string    `json:"number"`
+	Direction string    `json:"direction"`
+	Status    string    `json:"status"`
+	Store     string    `json:"store"`
+	Date      time.Time `json:"date"`
+	Content   string    `json:"content"`
+}
+
+func writeJSON(w io.Writer, messages []api.SMSMessage) error {
+	records := make([]jsonMessage, len(messages))
+	for i, m := range messages {
+		records[i] = jsonMessage{
+			ID:        m.ID,
+			PartIDs:   m.PartIDs,
+			Number:    m.Number,
+			Direction: direction(m),
+			Status:    m.Status.String(),
+			Store:     m.Store.String(),
+			Date:      m.Timestamp,
+			Content:   m.Content,
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// backupSMSes is the root element of an SMS Backup & Restore file
+type backupSMSes struct {
+	XMLName xml.Name    `xml:"smses"`
+	Count   int         `xml:"count,attr"`
+	SMS     []backupSMS `xml:"sms"`
+}
+
+// backupSMS is one message. The app writes the literal string "null" for
+// unset attributes and restores more reliably when they are present.
+type backupSMS struct {
+	Protocol      string `xml:"protocol,attr"`
+	Address       string `xml:"address,attr"`
+	Date          int64  `xml:"date,attr"`
+	Type          int    `xml:"type,attr"`
+	Subject       string `xml:"subject,attr"`
+	Body          string `xml:"body,attr"`
+	TOA           string `xml:"toa,attr"`
+	SCTOA         string `xml:"sc_toa,attr"`
+	ServiceCenter string `xml:"service_center,attr"`
+	Read          int    `xml:"read,attr"`
+	Status        int    `xml:"status,attr"`
+	Locked        int    `xml:"locked,attr"`
+	DateSent      int64  `xml:"date_sent,attr"`
+	ReadableDate  string `xml:"readable_date,attr"`
+	ContactName   string `xml:"contact_name,attr"`
+}
+
+// Android message box types
+const (
+	backupInbox = 1
+	backupSent  = 2
+	backupDraft = 3
+)
+
+func writeBackupXML(w io.Writer, messages []api.SMSMessage) error {
+	root := backupSMSes{Count: len(messages)}
+
+	for _, m := range messages {
+		sms := backupSMS{
+			Protocol:      "0",
+			Address:       m.Number,
+			Date:          m.Timestamp.UnixMilli(),
+			Type:          backupInbox,
+			Subject:       "null",
+			Body:          m.Content,
+			TOA:           "null",
+			SCTOA:         "null",
+			ServiceCenter: "null",
+			Read:          1,
+			Status:        -1,
+			ReadableDate:  m.Timestamp.Format("Jan 2, 2006 15:04:05"),
+			ContactName:   "(Unknown)",
+		}
+		switch m.Status {
+		case api.SMSUnread:
+			sms.Read = 0
+		case api.SMSSent:
+			sms.Type = backupSent
+		case api.SMSDraft:
+			sms.Type = backupDraft
+		}
+		if sms.Type == backupInbox {
+			sms.DateSent = sms.Date
+		}
+		root.SMS = append(root.SMS, sms)
+	}
+
+	if _, err := io.WriteString(w, "<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// FileName suggests a file name for an export made at t
+func FileName(format Format, t time.Time) string {
+	return "sms-export-" + t.Format("20060102-150405") + "." + string(format)
+}
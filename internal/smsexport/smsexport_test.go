@@ -0,0 +1,113 @@
+package smsexport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"mifi_app/internal/api"
+)
+
+func TestFormatForPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    Format
+		wantErr bool
+	}{
+		{"messages.csv", FormatCSV, false},
+		{"Messages.JSON", FormatJSON, false},
+		{"/tmp/backup.xml", FormatXML, false},
+		{"messages", "", true},
+		{"messages.txt", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := FormatForPath(tt.path)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("FormatForPath(%q) = %q, %v", tt.path, got, err)
+		}
+	}
+}
+
+var day = time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+
+// testMessages are newest first, as the device lists them
+var testMessages = []api.SMSMessage{
+	{ID: "3", Number: "+265888000222", Content: "Draft", Status: api.SMSDraft, Timestamp: day.Add(2 * time.Hour)},
+	{ID: "2", Number: "+265888000222", Content: "On my way, \"5 min\"", Status: api.SMSSent, Timestamp: day.Add(time.Hour)},
+	{ID: "1", Number: "+265999000111", Content: "Zikomo 👍\nsee you", Status: api.SMSUnread, Timestamp: day},
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format Format
+		check  func(t *testing.T, data []byte)
+	}{
+		{FormatCSV, func(t *testing.T, data []byte) {
+			records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 4 || records[0][0] != "id" {
+				t.Fatalf("records = %q", records)
+			}
+			if r := records[1]; r[0] != "1" || r[2] != "received" || r[6] != "Zikomo 👍\nsee you" {
+				t.Errorf("oldest record = %q", r)
+			}
+			if r := records[2]; r[2] != "sent" || r[6] != "On my way, \"5 min\"" {
+				t.Errorf("sent record = %q", r)
+			}
+		}},
+		{FormatJSON, func(t *testing.T, data []byte) {
+			var records []jsonMessage
+			if err := json.Unmarshal(data, &records); err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 3 || records[0].ID != "1" || records[2].Direction != "draft" {
+				t.Fatalf("records = %+v", records)
+			}
+			if r := records[0]; r.Status != "unread" || !r.Date.Equal(day) {
+				t.Errorf("oldest record = %+v", r)
+			}
+		}},
+		{FormatXML, func(t *testing.T, data []byte) {
+			var root backupSMSes
+			if err := xml.Unmarshal(data, &root); err != nil {
+				t.Fatal(err)
+			}
+			if root.Count != 3 || len(root.SMS) != 3 {
+				t.Fatalf("count %d with %d messages", root.Count, len(root.SMS))
+			}
+			received, sent, draft := root.SMS[0], root.SMS[1], root.SMS[2]
+			if received.Type != backupInbox || received.Read != 0 || received.DateSent != day.UnixMilli() || received.Body != "Zikomo 👍\nsee you" {
+				t.Errorf("received = %+v", received)
+			}
+			if sent.Type != backupSent || sent.Read != 1 || sent.DateSent != 0 {
+				t.Errorf("sent = %+v", sent)
+			}
+			if draft.Type != backupDraft {
+				t.Errorf("draft = %+v", draft)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, testMessages); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, buf.Bytes())
+		})
+	}
+
+	if testMessages[0].ID != "3" {
+		t.Error("Write reordered its input")
+	}
+	if err := Write(&bytes.Buffer{}, "pdf", testMessages); err == nil {
+		t.Error("unknown format written without an error")
+	}
+}
@@ -0,0 +1,464 @@
+// Package smsoutbox queues outgoing messages on disk, confirms them with the
+// device's send status and retries failed ones, so a message is not lost
+// when the network or the session drops while it is being sent.
+package smsoutbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mifi_app/internal/api"
+	"mifi_app/internal/config"
+)
+
+// FileName is the outbox file in the config directory
+const FileName = "sms_outbox.json"
+
+// MaxAttempts is how often a message is sent before it is given up on
+const MaxAttempts = 5
+
+const (
+	// retryDelay is the wait after the first failure; it doubles with every
+	// further failure up to maxRetryDelay
+	retryDelay    = 30 * time.Second
+	maxRetryDelay = 30 * time.Minute
+
+	// statusInterval and statusTimeout bound the wait for the device to
+	// confirm a send
+	statusInterval = time.Second
+	statusTimeout  = 2 * time.Minute
+
+	// sentRetention is how long sent messages are kept for display
+	sentRetention = 24 * time.Hour
+)
+
+// ErrNotConfirmed is recorded when the device never reports the outcome of a
+// send. The message may have gone out, so it is not retried automatically.
+var ErrNotConfirmed = errors.New("device did not confirm the send")
+
+// State is where a message is in the outbox
+type State string
+
+const (
+	StateQueued  State = "queued"
+	StateSending State = "sending"
+	StateSent    State = "sent"
+	StateFailed  State = "failed"
+)
+
+// Item is a queued message for one recipient
+type Item struct {
+	ID       string    `json:"id"`
+	Number   string    `json:"number"`
+	Content  string    `json:"content"`
+	State    State     `json:"state"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+
+	// NextAttempt is when a failed message is retried
+	NextAttempt time.Time `json:"next_attempt,omitzero"`
+}
+
+// WillRetry reports whether a failed message is still retried automatically
+func (i *Item) WillRetry() bool {
+	return i.State == StateFailed && i.Attempts < MaxAttempts
+}
+
+// MayHaveSent reports whether the message failed after the device accepted
+// it without confirming the send, so sending it again could deliver it twice
+func (i *Item) MayHaveSent() bool {
+	return i.State == StateFailed && strings.HasPrefix(i.Error, ErrNotConfirmed.Error())
+}
+
+// Unsent reports whether the message has not been sent yet
+func (i *Item) Unsent() bool {
+	return i.State != StateSent
+}
+
+// Outbox is the queue of outgoing messages. It is safe for concurrent use.
+type Outbox struct {
+	path   string
+	client api.DeviceAPI
+
+	// OnChange is called after any item changes state, without locks held
+	OnChange func()
+
+	mu     sync.Mutex
+	items  []*Item
+	nextID int
+
+	// sending serialises sends, since the device reports the status of the
+	// last send only
+	sending sync.Mutex
+}
+
+// DefaultPath returns the outbox location in the config directory
+func DefaultPath() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// New returns an empty outbox that is not saved to disk
+func New(client api.DeviceAPI) *Outbox {
+	return &Outbox{client: client}
+}
+
+// Open loads the outbox at path. A missing file is an empty outbox.
+// Messages that were being sent when the app stopped may have gone out, so
+// they are failed with ErrNotConfirmed rather than sent again.
+func Open(path string, client api.DeviceAPI) (*Outbox, error) {
+	o := &Outbox{path: path, client: client}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SMS outbox: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &o.items); err != nil {
+		return nil, fmt.Errorf("failed to parse SMS outbox %s: %w", path, err)
+	}
+	for _, item := range o.items {
+		if item.State == StateSending {
+			item.State = StateFailed
+			item.Error = ErrNotConfirmed.Error()
+			item.Attempts = max(item.Attempts, MaxAttempts)
+			item.NextAttempt = time.Time{}
+		}
+	}
+
+	return o, nil
+}
+
+// Add queues content for number and returns the new item
+func (o *Outbox) Add(number, content string) (Item, error) {
+	o.mu.Lock()
+	now := time.Now()
+	o.nextID++
+	item := &Item{
+		ID:      strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.Itoa(o.nextID),
+		Number:  number,
+		Content: content,
+		State:   StateQueued,
+		Created: now,
+		Updated: now,
+	}
+	o.items = append(o.items, item)
+	err := o.save()
+	o.mu.Unlock()
+
+	o.changed()
+	return *item, err
+}
+
+// Items returns a copy of every item, oldest first
+func (o *Outbox) Items() []Item {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	items := make([]Item, len(o.items))
+	for i, item := range o.items {
+		items[i] = *item
+	}
+	return items
+}
+
+// Unsent returns the number of messages not sent yet
+func (o *Outbox) Unsent() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n := 0
+	for _, item := range o.items {
+		if item.Unsent() {
+			n++
+		}
+	}
+	return n
+}
+
+// Remove drops a message from the outbox. A message being sent cannot be
+// removed.
+func (o *Outbox) Remove(id string) error {
+	o.mu.Lock()
+	kept := o.items[:0]
+	var err error
+	for _, item := range o.items {
+		if item.ID == id && item.State == StateSending {
+			err = errors.New("the message is being sent")
+		}
+		if item.ID != id || item.State == StateSending {
+			kept = append(kept, item)
+		}
+	}
+	o.items = kept
+	if err == nil {
+		err = o.save()
+	}
+	o.mu.Unlock()
+
+	o.changed()
+	return err
+}
+
+// Retry queues a failed message again, with a fresh set of attempts
+func (o *Outbox) Retry(id string) error {
+	o.mu.Lock()
+	item := o.find(id)
+	if item == nil || item.State != StateFailed {
+		o.mu.Unlock()
+		return errors.New("the message is not waiting for a retry")
+	}
+	item.State = StateQueued
+	item.Attempts = 0
+	item.NextAttempt = time.Time{}
+	item.Updated = time.Now()
+	err := o.save()
+	o.mu.Unlock()
+
+	o.changed()
+	return err
+}
+
+// Wake makes every message that is waiting for a retry due now, for when
+// the connection to the device comes back
+func (o *Outbox) Wake() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, item := range o.items {
+		if item.WillRetry() {
+			item.NextAttempt = time.Time{}
+		}
+	}
+}
+
+// Flush sends every queued message and every failed message that is due for
+// a retry. It returns at once if another flush or send is running.
+func (o *Outbox) Flush(ctx context.Context) {
+	if !o.sending.TryLock() {
+		return
+	}
+	defer o.sending.Unlock()
+
+	for {
+		item := o.nextDue()
+		if item == nil {
+			return
+		}
+		if err := o.send(ctx, item); errors.Is(err, context.Canceled) {
+			return
+		}
+	}
+}
+
+// Send sends the message id now, waiting for any running flush first, and
+// returns the error that made it fail. A failed message stays in the outbox
+// and is retried. Messages that are sent or that will not be retried, such
+// as ones the device may have sent already, are not sent again; Retry them
+// first.
+func (o *Outbox) Send(ctx context.Context, id string) error {
+	o.sending.Lock()
+	defer o.sending.Unlock()
+
+	o.mu.Lock()
+	item := o.find(id)
+	o.mu.Unlock()
+	if item == nil {
+		return errors.New("the message is no longer in the outbox")
+	}
+
+	return o.send(ctx, item)
+}
+
+// nextDue returns the oldest message ready to be sent
+func (o *Outbox) nextDue() *Item {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	for _, item := range o.items {
+		if item.State == StateQueued || item.WillRetry() && !now.Before(item.NextAttempt) {
+			return item
+		}
+	}
+	return nil
+}
+
+// send makes one attempt at item if it is queued or waiting for a retry.
+// The caller must hold sending.
+func (o *Outbox) send(ctx context.Context, item *Item) error {
+	var skip error
+	sent := false
+	o.update(item, func() {
+		switch {
+		case item.State == StateSent:
+			sent = true
+		case item.MayHaveSent():
+			skip = fmt.Errorf("%w; retry it to send it again", ErrNotConfirmed)
+		case item.State != StateQueued && !item.WillRetry():
+			skip = errors.New(item.Error)
+		default:
+			item.State = StateSending
+			item.Error = ""
+		}
+	})
+	if sent || skip != nil {
+		return skip
+	}
+
+	_, err := o.client.SendSMSContext(ctx, item.Number, item.Content)
+	accepted := err == nil
+	if accepted {
+		err = o.confirm(ctx)
+	}
+
+	if !accepted && errors.Is(err, context.Canceled) {
+		// Abandoned before the device took it; the next flush picks it up
+		o.update(item, func() { item.State = StateQueued })
+		return err
+	}
+
+	o.update(item, func() {
+		if err == nil {
+			item.State = StateSent
+			item.Attempts++
+			item.NextAttempt = time.Time{}
+			return
+		}
+
+		item.State = StateFailed
+		item.Error = err.Error()
+		item.Attempts++
+		if errors.Is(err, api.ErrMessageTooLong) || errors.Is(err, ErrNotConfirmed) {
+			// Retrying cannot help, or could send the message twice
+			item.Attempts = max(item.Attempts, MaxAttempts)
+		}
+		delay := retryDelay << (item.Attempts - 1)
+		if delay > maxRetryDelay || delay <= 0 {
+			delay = maxRetryDelay
+		}
+		item.NextAttempt = time.Now().Add(delay)
+	})
+	return err
+}
+
+// confirm waits for the device to report the outcome of the last send.
+// Backends that cannot report it are trusted once they accept a message.
+// The device has taken the message by now, so an outcome that cannot be
+// read is ErrNotConfirmed.
+func (o *Outbox) confirm(ctx context.Context) error {
+	tracker, ok := o.client.(api.SMSSendTracker)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return ErrNotConfirmed
+			}
+			return fmt.Errorf("%w: %w", ErrNotConfirmed, ctx.Err())
+		case <-ticker.C:
+		}
+
+		status, err := tracker.GetSMSSendStatusContext(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				continue
+			}
+			return fmt.Errorf("%w: failed to read send status: %w", ErrNotConfirmed, err)
+		}
+
+		switch status {
+		case api.SMSSendSucceeded:
+			return nil
+		case api.SMSSendFailed:
+			return errors.New("the network rejected the message")
+		}
+	}
+}
+
+// update changes item under the lock, saves and notifies
+func (o *Outbox) update(item *Item, change func()) {
+	o.mu.Lock()
+	change()
+	item.Updated = time.Now()
+	_ = o.save()
+	o.mu.Unlock()
+
+	o.changed()
+}
+
+func (o *Outbox) find(id string) *Item {
+	for _, item := range o.items {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+func (o *Outbox) changed() {
+	if o.OnChange != nil {
+		o.OnChange()
+	}
+}
+
+// save writes the outbox, dropping messages sent more than a day ago. The
+// caller must hold mu.
+func (o *Outbox) save() error {
+	cutoff := time.Now().Add(-sentRetention)
+	kept := o.items[:0]
+	for _, item := range o.items {
+		if item.State != StateSent || item.Updated.After(cutoff) {
+			kept = append(kept, item)
+		}
+	}
+	o.items = kept
+
+	if o.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(o.items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode SMS outbox: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.path), 0755); err != nil {
+		return fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	// Write a temporary file first so a crash cannot truncate the outbox
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write SMS outbox: %w", err)
+	}
+	if err := os.Rename(tmp, o.path); err != nil {
+		return fmt.Errorf("failed to write SMS outbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package smsoutbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"mifi_app/internal/api"
+	"mifi_app/internal/mifisim"
+)
+
+// testClient counts sends to the simulator and can fail reading the send
+// status or cancel the send while it is being confirmed
+type testClient struct {
+	*api.Client
+
+	sends     atomic.Int32
+	statusErr error
+	onConfirm func()
+}
+
+func (c *testClient) SendSMSContext(ctx context.Context, number, content string) (api.SMSInfo, error) {
+	c.sends.Add(1)
+	return c.Client.SendSMSContext(ctx, number, content)
+}
+
+func (c *testClient) GetSMSSendStatusContext(ctx context.Context) (api.SMSSendStatus, error) {
+	if c.onConfirm != nil {
+		c.onConfirm()
+	}
+	if c.statusErr != nil {
+		return api.SMSSendUnknown, c.statusErr
+	}
+	return c.Client.GetSMSSendStatusContext(ctx)
+}
+
+func newTestOutbox(t *testing.T) (*Outbox, *testClient, *mifisim.Simulator) {
+	t.Helper()
+
+	sim := mifisim.New(mifisim.Options{})
+	url, err := sim.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sim.Close() })
+
+	client := &testClient{Client: api.NewClient(url, logrus.New())}
+	if err := client.Login("admin", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := Open(filepath.Join(t.TempDir(), FileName), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o, client, sim
+}
+
+func itemByID(o *Outbox, id string) Item {
+	for _, item := range o.Items() {
+		if item.ID == id {
+			return item
+		}
+	}
+	return Item{}
+}
+
+func TestSendIsNotRepeated(t *testing.T) {
+	o, client, _ := newTestOutbox(t)
+	ctx := context.Background()
+
+	item, _ := o.Add("+265888000222", "Hello")
+	if err := o.Send(ctx, item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := itemByID(o, item.ID); got.State != StateSent || got.Attempts != 1 {
+		t.Fatalf("after sending: %s after %d attempts", got.State, got.Attempts)
+	}
+
+	// Compose's Send after a flush already sent it
+	if err := o.Send(ctx, item.ID); err != nil {
+		t.Fatal(err)
+	}
+	o.Flush(ctx)
+	if n := client.sends.Load(); n != 1 {
+		t.Errorf("message sent %d times, want once", n)
+	}
+}
+
+func TestUnconfirmedSendIsNotRepeated(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *testClient, cancel context.CancelFunc)
+	}{
+		{"status unreadable", func(c *testClient, cancel context.CancelFunc) {
+			c.statusErr = errors.New("connection reset")
+		}},
+		{"cancelled while confirming", func(c *testClient, cancel context.CancelFunc) {
+			c.onConfirm = cancel
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, client, _ := newTestOutbox(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			tt.setup(client, cancel)
+
+			item, _ := o.Add("+265888000222", "Top up code 1234")
+			err := o.Send(ctx, item.ID)
+			if !errors.Is(err, ErrNotConfirmed) {
+				t.Fatalf("Send = %v, want ErrNotConfirmed", err)
+			}
+
+			got := itemByID(o, item.ID)
+			if got.State != StateFailed || got.Attempts != MaxAttempts || !got.MayHaveSent() || got.WillRetry() {
+				t.Fatalf("item = %s, %d attempts, may have sent %v, will retry %v",
+					got.State, got.Attempts, got.MayHaveSent(), got.WillRetry())
+			}
+
+			client.statusErr, client.onConfirm = nil, nil
+			o.Wake()
+			o.Flush(context.Background())
+			if err := o.Send(context.Background(), item.ID); !errors.Is(err, ErrNotConfirmed) {
+				t.Errorf("Send of an unconfirmed message = %v, want ErrNotConfirmed", err)
+			}
+			if n := client.sends.Load(); n != 1 {
+				t.Fatalf("message sent %d times before a retry, want once", n)
+			}
+
+			// Only an explicit retry sends it again
+			if err := o.Retry(item.ID); err != nil {
+				t.Fatal(err)
+			}
+			o.Flush(context.Background())
+			if got := itemByID(o, item.ID); got.State != StateSent || client.sends.Load() != 2 {
+				t.Errorf("after retrying: %s, %d sends", got.State, client.sends.Load())
+			}
+		})
+	}
+}
+
+func TestRejectedSendIsRetried(t *testing.T) {
+	o, client, sim := newTestOutbox(t)
+	ctx := context.Background()
+	sim.FailSends(1)
+
+	item, _ := o.Add("+265888000222", "Hello")
+	if err := o.Send(ctx, item.ID); err == nil || errors.Is(err, ErrNotConfirmed) {
+		t.Fatalf("Send of a rejected message = %v", err)
+	}
+	got := itemByID(o, item.ID)
+	if !got.WillRetry() || got.MayHaveSent() || got.NextAttempt.IsZero() {
+		t.Fatalf("rejected message: will retry %v, may have sent %v, next attempt %v",
+			got.WillRetry(), got.MayHaveSent(), got.NextAttempt)
+	}
+
+	// Not due yet, so a flush leaves it alone; Send sends it at once
+	o.Flush(ctx)
+	if n := client.sends.Load(); n != 1 {
+		t.Fatalf("flush sent a message before its retry was due (%d sends)", n)
+	}
+	if err := o.Send(ctx, item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := itemByID(o, item.ID); got.State != StateSent || got.Attempts != 2 {
+		t.Errorf("after sending again: %s after %d attempts", got.State, got.Attempts)
+	}
+}
+
+func TestOpenFailsInterruptedSends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	data := `[
+		{"id":"a","number":"+265888000222","content":"Hi","state":"sending","attempts":1},
+		{"id":"b","number":"+265888000222","content":"Hi","state":"queued"},
+		{"id":"c","number":"+265888000222","content":"Hi","state":"sent","attempts":1}
+	]`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]State{"a": StateFailed, "b": StateQueued, "c": StateSent}
+	for _, item := range o.Items() {
+		if item.State != want[item.ID] {
+			t.Errorf("item %s = %s, want %s", item.ID, item.State, want[item.ID])
+		}
+	}
+	if a := itemByID(o, "a"); !a.MayHaveSent() || a.WillRetry() || a.Attempts != MaxAttempts {
+		t.Errorf("interrupted send: may have sent %v, will retry %v, %d attempts", a.MayHaveSent(), a.WillRetry(), a.Attempts)
+	}
+	if o.Unsent() != 2 {
+		t.Errorf("Unsent = %d, want 2", o.Unsent())
+	}
+}
@@ -0,0 +1,69 @@
+package smssync
+
+import (
+	"sort"
+
+	"mifi_app/internal/api"
+)
+
+// CleanupPolicy frees message storage by deleting the oldest read messages
+// of a store once it is Threshold percent full, until it is Target percent
+// full
+type CleanupPolicy struct {
+	Threshold int
+	Target    int
+}
+
+// Full returns the stores at or above the threshold
+func (p CleanupPolicy) Full(capacity *api.SMSCapacity) []api.SMSStore {
+	var full []api.SMSStore
+	for _, store := range []api.SMSStore{api.SMSStoreDevice, api.SMSStoreSIM} {
+		if capacity.Total(store) > 0 && capacity.Percent(store) >= p.Threshold {
+			full = append(full, store)
+		}
+	}
+	return full
+}
+
+// Select returns the messages to delete, as synced, so that every full store
+// drops to the target. The parts of a multi-part message are deleted
+// together. Unread, sent and draft messages are never selected.
+func (p CleanupPolicy) Select(capacity *api.SMSCapacity, messages []api.SMSMessage) []api.SMSMessage {
+	var selected []api.SMSMessage
+
+	for _, store := range p.Full(capacity) {
+		excess := capacity.Used(store) - capacity.Total(store)*p.Target/100
+		if excess <= 0 {
+			continue
+		}
+
+		var stored []api.SMSMessage
+		for _, m := range messages {
+			if m.Store == store {
+				stored = append(stored, m)
+			}
+		}
+
+		// Stitching groups the parts, so each candidate is a whole message
+		// that is unread if any part is
+		var candidates []api.SMSMessage
+		for _, m := range api.StitchConcat(stored) {
+			if m.Status == api.SMSRead {
+				candidates = append(candidates, m)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Timestamp.Before(candidates[j].Timestamp)
+		})
+
+		for _, m := range candidates {
+			if excess <= 0 {
+				break
+			}
+			selected = append(selected, m)
+			excess -= len(m.AllIDs())
+		}
+	}
+
+	return selected
+}
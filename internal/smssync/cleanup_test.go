@@ -0,0 +1,114 @@
+package smssync
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"mifi_app/internal/api"
+)
+
+var start = time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+
+// stored returns n read device messages, one a minute, the oldest first
+func stored(n int) []api.SMSMessage {
+	messages := make([]api.SMSMessage, n)
+	for i := range messages {
+		messages[i] = api.SMSMessage{
+			ID:        strconv.Itoa(i + 1),
+			Number:    "+265999000111",
+			Status:    api.SMSRead,
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	return messages
+}
+
+func TestCleanupFull(t *testing.T) {
+	policy := CleanupPolicy{Threshold: 90, Target: 70}
+
+	tests := []struct {
+		name     string
+		capacity api.SMSCapacity
+		want     []api.SMSStore
+	}{
+		{"below threshold", api.SMSCapacity{DeviceUsed: 89, DeviceTotal: 100, SIMUsed: 10, SIMTotal: 20}, nil},
+		{"device at threshold", api.SMSCapacity{DeviceUsed: 90, DeviceTotal: 100}, []api.SMSStore{api.SMSStoreDevice}},
+		{"both full", api.SMSCapacity{DeviceUsed: 100, DeviceTotal: 100, SIMUsed: 19, SIMTotal: 20}, []api.SMSStore{api.SMSStoreDevice, api.SMSStoreSIM}},
+		{"no SIM storage", api.SMSCapacity{DeviceUsed: 10, DeviceTotal: 100}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Full(&tt.capacity); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Full = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCleanupSelect(t *testing.T) {
+	policy := CleanupPolicy{Threshold: 90, Target: 70}
+
+	tests := []struct {
+		name     string
+		capacity api.SMSCapacity
+		messages func() []api.SMSMessage
+		want     []string
+	}{
+		{"below threshold", api.SMSCapacity{DeviceUsed: 8, DeviceTotal: 10}, func() []api.SMSMessage {
+			return stored(8)
+		}, nil},
+		{"oldest read first", api.SMSCapacity{DeviceUsed: 10, DeviceTotal: 10}, func() []api.SMSMessage {
+			m := stored(10)
+			// Newest first, as the device lists them
+			for i, j := 0, len(m)-1; i < j; i, j = i+1, j-1 {
+				m[i], m[j] = m[j], m[i]
+			}
+			return m
+		}, []string{"1", "2", "3"}},
+		{"unread, sent and drafts kept", api.SMSCapacity{DeviceUsed: 10, DeviceTotal: 10}, func() []api.SMSMessage {
+			m := stored(10)
+			m[0].Status = api.SMSUnread
+			m[1].Status = api.SMSSent
+			m[2].Status = api.SMSDraft
+			return m
+		}, []string{"4", "5", "6"}},
+		{"parts deleted together", api.SMSCapacity{DeviceUsed: 10, DeviceTotal: 10}, func() []api.SMSMessage {
+			m := stored(10)
+			for i := 0; i < 2; i++ {
+				m[i].ConcatRef, m[i].ConcatPart, m[i].ConcatTotal = "7", i+1, 2
+			}
+			return m
+		}, []string{"1", "2", "3"}},
+		{"part unread keeps the message", api.SMSCapacity{DeviceUsed: 10, DeviceTotal: 10}, func() []api.SMSMessage {
+			m := stored(10)
+			for i := 0; i < 2; i++ {
+				m[i].ConcatRef, m[i].ConcatPart, m[i].ConcatTotal = "7", i+1, 2
+			}
+			m[1].Status = api.SMSUnread
+			return m
+		}, []string{"3", "4", "5"}},
+		{"only the full store", api.SMSCapacity{DeviceUsed: 10, DeviceTotal: 10, SIMUsed: 1, SIMTotal: 10}, func() []api.SMSMessage {
+			m := stored(10)
+			m[0].Store = api.SMSStoreSIM
+			return m
+		}, []string{"2", "3", "4"}},
+		{"not enough read messages", api.SMSCapacity{DeviceUsed: 10, DeviceTotal: 10}, func() []api.SMSMessage {
+			m := stored(10)
+			for i := 1; i < len(m); i++ {
+				m[i].Status = api.SMSUnread
+			}
+			return m
+		}, []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, m := range policy.Select(&tt.capacity, tt.messages()) {
+			got = append(got, m.AllIDs()...)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Select = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
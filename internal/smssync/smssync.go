@@ -0,0 +1,197 @@
+// Package smssync pages through every message held by the device and works
+// out which ones are new, deleted or changed since the previous sync.
+package smssync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"mifi_app/internal/api"
+)
+
+// PageSize is the number of messages requested per page
+const PageSize = 100
+
+// maxPages bounds a store listing in case the firmware ignores the page
+// parameter and keeps returning the same messages
+const maxPages = 100
+
+// Result describes one sync
+type Result struct {
+	// Messages is everything on the device, newest first
+	Messages []api.SMSMessage
+
+	Added   []api.SMSMessage
+	Removed []api.SMSMessage
+	Changed []api.SMSMessage
+
+	// Initial is set on the first sync, when every message is reported as
+	// added
+	Initial bool
+}
+
+// Received returns the added messages that came in from the network, the
+// ones worth a notification
+func (r *Result) Received() []api.SMSMessage {
+	var received []api.SMSMessage
+	for _, m := range r.Added {
+		if m.Status == api.SMSRead || m.Status == api.SMSUnread {
+			received = append(received, m)
+		}
+	}
+	return received
+}
+
+// Syncer remembers the messages seen by the last sync so the next one can be
+// diffed against it. It is safe for concurrent use.
+type Syncer struct {
+	client api.DeviceAPI
+
+	mu     sync.Mutex
+	known  map[string]api.SMSMessage
+	synced bool
+}
+
+// New returns a Syncer for client
+func New(client api.DeviceAPI) *Syncer {
+	return &Syncer{client: client}
+}
+
+// Sync fetches every message and diffs it against the previous sync
+func (s *Syncer) Sync() (*Result, error) {
+	return s.SyncContext(context.Background())
+}
+
+// SyncContext is like Sync but uses ctx for cancellation.
+func (s *Syncer) SyncContext(ctx context.Context) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages, err := FetchAllContext(ctx, s.client)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Messages: messages, Initial: !s.synced}
+	current := make(map[string]api.SMSMessage, len(messages))
+
+	for _, m := range messages {
+		k := key(m)
+		current[k] = m
+
+		old, ok := s.known[k]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, m)
+		case old.Status != m.Status || old.Content != m.Content || old.Number != m.Number:
+			result.Changed = append(result.Changed, m)
+		}
+	}
+	for k, m := range s.known {
+		if _, ok := current[k]; !ok {
+			result.Removed = append(result.Removed, m)
+		}
+	}
+	sortNewestFirst(result.Removed)
+
+	s.known = current
+	s.synced = true
+
+	return result, nil
+}
+
+// Reset forgets the previous sync, so the next one is reported as initial
+func (s *Syncer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.known = nil
+	s.synced = false
+}
+
+// FetchAll lists every message in every store the backend has, newest first
+func FetchAll(client api.DeviceAPI) ([]api.SMSMessage, error) {
+	return FetchAllContext(context.Background(), client)
+}
+
+// FetchAllContext is like FetchAll but uses ctx for cancellation.
+func FetchAllContext(ctx context.Context, client api.DeviceAPI) ([]api.SMSMessage, error) {
+	var messages []api.SMSMessage
+
+	if lister, ok := client.(api.SMSStoreLister); ok {
+		for _, store := range []api.SMSStore{api.SMSStoreDevice, api.SMSStoreSIM} {
+			page := func(ctx context.Context, n int) ([]api.SMSMessage, error) {
+				return lister.GetStoredSMSListContext(ctx, store, n, PageSize)
+			}
+			stored, err := fetchPages(ctx, page)
+			if err != nil {
+				return nil, fmt.Errorf("%s messages: %w", store, err)
+			}
+			messages = append(messages, stored...)
+		}
+	} else {
+		page := func(ctx context.Context, n int) ([]api.SMSMessage, error) {
+			return client.GetSMSListContext(ctx, n, PageSize)
+		}
+		var err error
+		if messages, err = fetchPages(ctx, page); err != nil {
+			return nil, err
+		}
+	}
+
+	sortNewestFirst(messages)
+	return messages, nil
+}
+
+// fetchPages requests pages until one comes back short or brings nothing
+// that has not been seen already
+func fetchPages(ctx context.Context, page func(ctx context.Context, n int) ([]api.SMSMessage, error)) ([]api.SMSMessage, error) {
+	var messages []api.SMSMessage
+	seen := make(map[string]bool)
+
+	for n := 0; n < maxPages; n++ {
+		batch, err := page(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+
+		fresh := 0
+		for _, m := range batch {
+			if seen[m.ID] {
+				continue
+			}
+			seen[m.ID] = true
+			messages = append(messages, m)
+			fresh++
+		}
+
+		if len(batch) < PageSize || fresh == 0 {
+			break
+		}
+	}
+
+	return messages, nil
+}
+
+// key identifies a message across syncs. Indexes are only unique within a
+// store on some backends.
+func key(m api.SMSMessage) string {
+	return m.Store.String() + ":" + m.ID
+}
+
+// sortNewestFirst orders messages by timestamp, falling back to the ID for
+// messages received in the same second
+func sortNewestFirst(messages []api.SMSMessage) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		ti, tj := messages[i].Timestamp, messages[j].Timestamp
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		idi, _ := strconv.Atoi(messages[i].ID)
+		idj, _ := strconv.Atoi(messages[j].ID)
+		return idi > idj
+	})
+}
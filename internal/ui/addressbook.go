@@ -0,0 +1,394 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/sirupsen/logrus"
+
+	"mifi_app/internal/addressbook"
+)
+
+// openAddressBook opens the address book in the config directory. If it
+// cannot be read contacts are still kept, but only in memory.
+func openAddressBook(logger *logrus.Logger) *addressbook.Book {
+	path, err := addressbook.DefaultPath()
+	if err != nil {
+		logger.Warnf("Address book is not saved: %v", err)
+		return addressbook.New()
+	}
+
+	book, err := addressbook.Open(path)
+	if err != nil {
+		logger.Warnf("Address book is not saved: %v", err)
+		return addressbook.New()
+	}
+	return book
+}
+
+// addressBookMatches reports whether query is part of the name, a number or
+// the organization of c
+func addressBookMatches(c addressbook.Contact, query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" ||
+		strings.Contains(strings.ToLower(c.Name), query) ||
+		strings.Contains(strings.ToLower(c.Organization), query) {
+		return true
+	}
+	for _, phone := range c.Phones {
+		if strings.Contains(phone.Number, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// phonesText lists the numbers of c with their types
+func phonesText(c addressbook.Contact) string {
+	numbers := make([]string, len(c.Phones))
+	for i, phone := range c.Phones {
+		numbers[i] = phone.Number
+		if phone.Type != "" {
+			numbers[i] += " (" + phone.Type + ")"
+		}
+	}
+	return strings.Join(numbers, ", ")
+}
+
+// ShowAddressBookDialog lists the local address book with actions to add,
+// edit, delete and message contacts and to import and export vCard files
+func (a *App) ShowAddressBookDialog() {
+	var shown []addressbook.Contact
+	selected := -1
+
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("Search by name, number or organization")
+
+	list := widget.NewList(
+		func() int {
+			return len(shown)
+		},
+		func() fyne.CanvasObject {
+			name := widget.NewLabelWithStyle("Name", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			org := widget.NewLabelWithStyle("Organization", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+			numbers := widget.NewLabel("Numbers")
+			numbers.Truncation = fyne.TextTruncateEllipsis
+
+			return container.NewVBox(
+				container.NewHBox(name, layout.NewSpacer(), org),
+				numbers,
+				widget.NewSeparator(),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(shown) {
+				return
+			}
+			c := shown[id]
+			box := obj.(*fyne.Container)
+
+			top := box.Objects[0].(*fyne.Container)
+			top.Objects[0].(*widget.Label).SetText(c.Name)
+			top.Objects[2].(*widget.Label).SetText(c.Organization)
+			box.Objects[1].(*widget.Label).SetText(phonesText(c))
+		},
+	)
+
+	emptyLabel := widget.NewLabel("The address book is empty. Add contacts or import a .vcf file.")
+	emptyLabel.Wrapping = fyne.TextWrapWord
+	emptyLabel.Hide()
+
+	editBtn := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), nil)
+	deleteBtn := widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), nil)
+	messageBtn := widget.NewButtonWithIcon("Message", theme.MailComposeIcon(), nil)
+	selectionActions := []fyne.Disableable{editBtn, deleteBtn, messageBtn}
+
+	setSelected := func(id int) {
+		selected = id
+		for _, action := range selectionActions {
+			if id >= 0 {
+				action.Enable()
+			} else {
+				action.Disable()
+			}
+		}
+	}
+	setSelected(-1)
+
+	reload := func() {
+		shown = shown[:0]
+		for _, c := range a.addressBook.Contacts() {
+			if addressBookMatches(c, searchEntry.Text) {
+				shown = append(shown, c)
+			}
+		}
+		list.UnselectAll()
+		setSelected(-1)
+		emptyLabel.Hidden = len(shown) > 0
+		emptyLabel.Refresh()
+		list.Refresh()
+
+		// Names shown elsewhere may have changed
+		a.updateRecentSMSContent()
+	}
+	searchEntry.OnChanged = func(string) {
+		reload()
+	}
+	list.OnSelected = setSelected
+	list.OnUnselected = func(widget.ListItemID) {
+		setSelected(-1)
+	}
+
+	addBtn := widget.NewButtonWithIcon("Add", theme.ContentAddIcon(), func() {
+		a.showAddressBookForm(nil, reload)
+	})
+	editBtn.OnTapped = func() {
+		if selected >= 0 && selected < len(shown) {
+			c := shown[selected]
+			a.showAddressBookForm(&c, reload)
+		}
+	}
+	messageBtn.OnTapped = func() {
+		if selected >= 0 && selected < len(shown) {
+			a.ShowComposeDialog(shown[selected].Phones[0].Number, "")
+		}
+	}
+	deleteBtn.OnTapped = func() {
+		if selected < 0 || selected >= len(shown) {
+			return
+		}
+		c := shown[selected]
+		dialog.ShowConfirm("Delete Contact", fmt.Sprintf("Delete %s from the address book?", c.Name), func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := a.addressBook.Remove(c.ID); err != nil {
+				a.Logger.Errorf("Failed to delete contact: %v", err)
+				dialog.ShowError(err, a.MainWindow)
+			}
+			reload()
+		}, a.MainWindow)
+	}
+
+	importBtn := widget.NewButtonWithIcon("Import", theme.DownloadIcon(), func() {
+		a.importVCards(reload)
+	})
+	exportBtn := widget.NewButtonWithIcon("Export", theme.UploadIcon(), a.showVCardExportDialog)
+
+	buttons := container.NewHBox(addBtn, editBtn, deleteBtn, messageBtn, layout.NewSpacer(), importBtn, exportBtn)
+
+	content := container.NewBorder(
+		container.NewVBox(buttons, searchEntry),
+		nil,
+		nil,
+		nil,
+		container.NewStack(list, emptyLabel),
+	)
+
+	addressBookDialog := dialog.NewCustom("Address Book", "Close", content, a.MainWindow)
+	addressBookDialog.Resize(fyne.NewSize(640, 480))
+
+	reload()
+
+	addressBookDialog.Show()
+}
+
+// showAddressBookForm edits contact, or adds a new one when contact is nil,
+// and calls onSaved once it is stored
+func (a *App) showAddressBookForm(contact *addressbook.Contact, onSaved func()) {
+	adding := contact == nil
+	if adding {
+		contact = &addressbook.Contact{}
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(contact.Name)
+	nameEntry.Validator = func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return errors.New("enter a name")
+		}
+		return nil
+	}
+
+	// One number per line; the types of existing numbers are kept
+	numbers := make([]string, len(contact.Phones))
+	types := make(map[string]string, len(contact.Phones))
+	for i, phone := range contact.Phones {
+		numbers[i] = phone.Number
+		types[phone.Number] = phone.Type
+	}
+	numbersEntry := widget.NewMultiLineEntry()
+	numbersEntry.SetPlaceHolder("+265991234567")
+	numbersEntry.SetMinRowsVisible(3)
+	numbersEntry.SetText(strings.Join(numbers, "\n"))
+	numbersEntry.Validator = func(s string) error {
+		_, err := parseRecipients(s)
+		return err
+	}
+
+	emailsEntry := widget.NewMultiLineEntry()
+	emailsEntry.SetMinRowsVisible(2)
+	emailsEntry.SetText(strings.Join(contact.Emails, "\n"))
+
+	orgEntry := widget.NewEntry()
+	orgEntry.SetText(contact.Organization)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		{Text: "Numbers", Widget: numbersEntry, HintText: "One number per line"},
+		{Text: "Emails", Widget: emailsEntry, HintText: "One address per line"},
+		widget.NewFormItem("Organization", orgEntry),
+	}
+
+	title := "Edit Contact"
+	if adding {
+		title = "Add Contact"
+	}
+
+	form := dialog.NewForm(title, "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		saved := *contact
+		saved.Name = nameEntry.Text
+		saved.Organization = orgEntry.Text
+		saved.Emails = strings.Split(emailsEntry.Text, "\n")
+
+		saved.Phones = nil
+		for _, field := range strings.FieldsFunc(numbersEntry.Text, func(r rune) bool {
+			return strings.ContainsRune(recipientSeparators, r)
+		}) {
+			number := strings.TrimSpace(field)
+			phone := addressbook.Phone{Number: number, Type: types[number]}
+			if len(contact.Phones) == 0 && len(saved.Phones) == 0 {
+				phone.Type = addressbook.PhoneCell
+			}
+			saved.Phones = append(saved.Phones, phone)
+		}
+
+		if _, err := a.addressBook.Save(saved); err != nil {
+			a.Logger.Errorf("Failed to save contact %q: %v", saved.Name, err)
+			dialog.ShowError(err, a.MainWindow)
+			return
+		}
+		onSaved()
+	}, a.MainWindow)
+	form.Resize(fyne.NewSize(460, 0))
+	form.Show()
+}
+
+// importVCards asks for a .vcf file and adds its contacts to the address
+// book
+func (a *App) importVCards(onImported func()) {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.MainWindow)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		result, err := a.addressBook.Import(reader)
+		if err != nil {
+			a.Logger.Errorf("Failed to import %s: %v", reader.URI(), err)
+			dialog.ShowError(fmt.Errorf("failed to import contacts: %w", err), a.MainWindow)
+			return
+		}
+		onImported()
+
+		a.Logger.Infof("Imported %s: %d added, %d updated, %d skipped",
+			reader.URI(), result.Added, result.Updated, result.Skipped)
+		message := fmt.Sprintf("Added %d and updated %d contact(s) from %s.",
+			result.Added, result.Updated, reader.URI().Name())
+		if result.Skipped > 0 {
+			message += fmt.Sprintf(" %d contact(s) without a name or phone number were skipped.", result.Skipped)
+		}
+		dialog.ShowInformation("Import Complete", message, a.MainWindow)
+	}, a.MainWindow)
+
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".vcf", ".vcard"}))
+	openDialog.Show()
+}
+
+// showVCardExportDialog asks for a vCard version and a file, then writes
+// the address book to it
+func (a *App) showVCardExportDialog() {
+	count := len(a.addressBook.Contacts())
+	if count == 0 {
+		dialog.ShowInformation("Export Contacts", "The address book is empty.", a.MainWindow)
+		return
+	}
+
+	versions := make([]string, len(addressbook.Versions))
+	for i, v := range addressbook.Versions {
+		versions[i] = string(v)
+	}
+	versionSelect := widget.NewSelect(versions, nil)
+	versionSelect.SetSelected(string(addressbook.Version3))
+
+	hint := widget.NewLabelWithStyle("vCard 3.0 is read by most phones and mail programs.",
+		fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	hint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("%d contact(s)", count)),
+		widget.NewForm(widget.NewFormItem("vCard version", versionSelect)),
+		hint,
+	)
+
+	exportDialog := dialog.NewCustomConfirm("Export Contacts", "Export", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		version, err := addressbook.ParseVersion(versionSelect.Selected)
+		if err != nil {
+			dialog.ShowError(err, a.MainWindow)
+			return
+		}
+		a.saveVCards(version)
+	}, a.MainWindow)
+	exportDialog.Resize(fyne.NewSize(420, 200))
+	exportDialog.Show()
+}
+
+// saveVCards asks where to save and writes the address book as vCards
+func (a *App) saveVCards(version addressbook.Version) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.MainWindow)
+			return
+		}
+		if writer == nil {
+			return
+		}
+
+		err = a.addressBook.Export(writer, version)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			a.Logger.Errorf("Failed to export contacts: %v", err)
+			dialog.ShowError(fmt.Errorf("failed to export contacts: %w", err), a.MainWindow)
+			return
+		}
+
+		a.Logger.Infof("Exported the address book to %s", writer.URI())
+		dialog.ShowInformation("Export Complete",
+			fmt.Sprintf("Exported the address book to %s.", writer.URI().Name()),
+			a.MainWindow)
+	}, a.MainWindow)
+
+	saveDialog.SetFileName("contacts.vcf")
+	saveDialog.Show()
+}
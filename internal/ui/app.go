@@ -1,9 +1,13 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image/color"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -15,24 +19,36 @@ import (
 	"fyne.io/fyne/v2/widget"
 	"github.com/sirupsen/logrus"
 
+	"mifi_app/internal/addressbook"
 	"mifi_app/internal/api"
+	"mifi_app/internal/balance"
 	"mifi_app/internal/config"
+	"mifi_app/internal/smsarchive"
+	"mifi_app/internal/smsoutbox"
+	"mifi_app/internal/smssync"
 	"mifi_app/internal/utils"
 )
 
 type App struct {
 	FyneApp    fyne.App
 	MainWindow fyne.Window
-	APIClient  *api.Client
+	APIClient  api.DeviceAPI
 	Config     *config.Config
 	Logger     *logrus.Logger
 
+	// ctx is cancelled when the application quits, aborting in-flight requests
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	statusLabel     *widget.Label
 	connectBtn      *widget.Button
 	disconnectBtn   *widget.Button
 	refreshBtn      *widget.Button
 	wifiSettingsBtn *widget.Button
 	smsBtn          *widget.Button
+	ussdBtn         *widget.Button
+	contactsBtn     *widget.Button
+	addressBookBtn  *widget.Button
 	devicesBtn      *widget.Button
 	settingsBtn     *widget.Button
 	restartBtn      *widget.Button
@@ -50,22 +66,76 @@ type App struct {
 	stopPolling   chan bool
 
 	cachedSMSMessages  []api.SMSMessage
-	lastSMSCount       int
+	smsSync            *smssync.Syncer
+	smsArchive         *smsarchive.Archive
+	deviceIMEI         string
+	smsStorageWarned   bool
 	recentSMSContainer *fyne.Container
+	smsUnreadLabel     *widget.Label
+
+	// outboxListeners are refreshed on the UI thread when the outbox changes
+	outbox          *smsoutbox.Outbox
+	outboxListeners map[string]func()
 
 	cachedDevices []api.ConnectedDevice
 
+	// contactNames maps normalized numbers to phonebook names
+	contacts     []api.Contact
+	contactNames map[string]string
+	addressBook  *addressbook.Book
+
+	balanceHistory      *balance.History
+	balanceAmountLabel  *widget.Label
+	balanceDataLabel    *widget.Label
+	balanceExpiryLabel  *widget.Label
+	balanceCheckedLabel *widget.Label
+
+	// balanceMu guards the balance check schedule and the operator it uses
+	balanceMu       sync.Mutex
+	balanceNext     time.Time
+	balanceRunning  bool
+	balanceOperator string
+	// ussdDialogOpen pauses scheduled checks while the user runs USSD codes
+	ussdDialogOpen bool
+
+	// ussdCodes are the codes dialled this session, most recent first
+	ussdCodes []string
+
 	trayActions chan string
 }
 
-func NewApp(fyneApp fyne.App, client *api.Client, cfg *config.Config, logger *logrus.Logger) *App {
-	return &App{
-		FyneApp:     fyneApp,
-		APIClient:   client,
-		Config:      cfg,
-		Logger:      logger,
-		trayActions: make(chan string, 2),
+func NewApp(fyneApp fyne.App, client api.DeviceAPI, cfg *config.Config, logger *logrus.Logger) *App {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Contacts are matched by number from the start, so set the country
+	// code before the address book is indexed
+	if cfg != nil {
+		if err := api.SetDefaultCountryCode(cfg.SMS.CountryCode); err != nil {
+			logger.Warnf("Ignoring the SMS country code: %v", err)
+		}
+	}
+
+	a := &App{
+		ctx:             ctx,
+		cancel:          cancel,
+		FyneApp:         fyneApp,
+		APIClient:       client,
+		Config:          cfg,
+		Logger:          logger,
+		smsSync:         smssync.New(client),
+		smsArchive:      openSMSArchive(logger),
+		outbox:          openOutbox(client, logger),
+		outboxListeners: make(map[string]func()),
+		balanceHistory:  openBalanceHistory(logger),
+		addressBook:     openAddressBook(logger),
+		trayActions:     make(chan string, 2),
+	}
+	a.outbox.OnChange = func() {
+		fyne.Do(a.outboxChanged)
 	}
+	a.outboxListeners["dashboard"] = a.updateRecentSMSContent
+
+	return a
 }
 
 func (a *App) CreateMainWindow() {
@@ -86,6 +156,8 @@ func (a *App) CreateMainWindow() {
 		})
 	}
 
+	a.FyneApp.Lifecycle().SetOnStopped(a.cancel)
+
 	// Handle tray actions in a separate loop
 	go a.handleTrayActions()
 }
@@ -101,6 +173,14 @@ func (a *App) handleTrayActions() {
 					a.MainWindow.RequestFocus()
 				}
 			})
+		case "compose":
+			fyne.Do(func() {
+				if a.MainWindow != nil {
+					a.MainWindow.Show()
+					a.MainWindow.RequestFocus()
+					a.ShowComposeDialog("", "")
+				}
+			})
 		case "quit":
 			fyne.Do(func() {
 				if a.FyneApp != nil {
@@ -115,7 +195,7 @@ func (a *App) AutoLogin() {
 	a.statusLabel.SetText("Status: Connecting...")
 
 	// Check if device is reachable
-	if err := a.APIClient.Ping(); err != nil {
+	if err := a.APIClient.PingContext(a.ctx); err != nil {
 		a.Logger.Errorf("Device unreachable during auto-login: %v", err)
 		a.statusLabel.SetText("Status: Device Unreachable")
 		return
@@ -130,9 +210,9 @@ func (a *App) AutoLogin() {
 		return
 	}
 
-	if err := a.APIClient.Login(username, password); err != nil {
+	if err := a.APIClient.LoginContext(a.ctx, username, password); err != nil {
 		a.Logger.Errorf("Auto-login failed: %v", err)
-		a.statusLabel.SetText("Status: Login Failed")
+		a.statusLabel.SetText(loginStatusText(err))
 		return
 	}
 
@@ -162,6 +242,15 @@ func (a *App) createComponents() {
 
 	a.wifiSettingsBtn = widget.NewButton("WiFi Settings", a.ShowWiFiSettingsDialog)
 	a.smsBtn = widget.NewButton("SMS Messages", a.ShowSMSDialog)
+	a.ussdBtn = widget.NewButton("USSD Codes", a.ShowUSSDDialog)
+	if _, ok := a.APIClient.(api.USSDSender); !ok {
+		a.ussdBtn.Hide()
+	}
+	a.contactsBtn = widget.NewButton("Contacts", a.ShowContactsDialog)
+	if _, ok := a.APIClient.(api.Phonebook); !ok {
+		a.contactsBtn.Hide()
+	}
+	a.addressBookBtn = widget.NewButton("Address Book", a.ShowAddressBookDialog)
 	a.devicesBtn = widget.NewButton("Connected Devices", a.ShowDevicesDialog)
 	a.settingsBtn = widget.NewButton("Settings", a.ShowSettingsDialog)
 
@@ -226,6 +315,9 @@ func (a *App) createLayout() fyne.CanvasObject {
 	quickActionsGrid := container.NewGridWithColumns(2,
 		a.wifiSettingsBtn,
 		a.smsBtn,
+		a.contactsBtn,
+		a.addressBookBtn,
+		a.ussdBtn,
 		a.devicesBtn,
 		a.settingsBtn,
 	)
@@ -238,8 +330,14 @@ func (a *App) createLayout() fyne.CanvasObject {
 	powerCard := a.createCard("Power Management", powerGrid, nil)
 
 	a.recentSMSContainer = container.NewVBox()
+	a.smsUnreadLabel = widget.NewLabel("")
+	a.smsUnreadLabel.Importance = widget.HighImportance
 	a.updateRecentSMSContent()
-	recentSMSCard := a.createCard("Recent Messages", a.recentSMSContainer, theme.MailComposeIcon())
+	composeBtn := widget.NewButtonWithIcon("New Message", theme.MailComposeIcon(), func() {
+		a.ShowComposeDialog("", "")
+	})
+	recentSMSContent := container.NewVBox(a.recentSMSContainer, container.NewHBox(a.smsUnreadLabel, layout.NewSpacer(), composeBtn))
+	recentSMSCard := a.createCard("Recent Messages", recentSMSContent, theme.MailComposeIcon())
 
 	// Layout cards in two columns with equal sizing
 	leftColumn := container.NewVBox(
@@ -250,6 +348,7 @@ func (a *App) createLayout() fyne.CanvasObject {
 
 	rightColumn := container.NewVBox(
 		networkStatsCard,
+		a.createBalanceCard(),
 		quickActionsCard,
 		powerCard,
 	)
@@ -296,6 +395,21 @@ func (a *App) updateRecentSMSContent() {
 
 	a.recentSMSContainer.Objects = nil
 
+	unread := 0
+	for i := range a.cachedSMSMessages {
+		if a.cachedSMSMessages[i].IsUnread() {
+			unread++
+		}
+	}
+	var counts []string
+	if unread > 0 {
+		counts = append(counts, fmt.Sprintf("%d unread", unread))
+	}
+	if unsent := a.outbox.Unsent(); unsent > 0 {
+		counts = append(counts, fmt.Sprintf("%d in outbox", unsent))
+	}
+	a.smsUnreadLabel.SetText(strings.Join(counts, " · "))
+
 	if len(a.cachedSMSMessages) == 0 {
 		a.recentSMSContainer.Add(widget.NewLabel("No messages"))
 		a.recentSMSContainer.Refresh()
@@ -311,11 +425,15 @@ func (a *App) updateRecentSMSContent() {
 		msg := a.cachedSMSMessages[i]
 
 		content := msg.Content
-		if len(content) > 50 {
-			content = content[:100] + "..."
+		if runes := []rune(content); len(runes) > 50 {
+			content = string(runes[:50]) + "..."
 		}
 
-		senderLabel := widget.NewLabelWithStyle(msg.Number, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+		sender := a.displayName(msg.Number)
+		if msg.IsSent() {
+			sender = "To " + sender
+		}
+		senderLabel := widget.NewLabelWithStyle(sender, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 		contentLabel := widget.NewLabel(content)
 		dateLabel := widget.NewLabelWithStyle(msg.Timestamp.Format("Jan 02 15:04"), fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
 
@@ -342,7 +460,7 @@ func (a *App) onConnect() {
 		}
 	}()
 
-	if err := a.APIClient.Ping(); err != nil {
+	if err := a.APIClient.PingContext(a.ctx); err != nil {
 		a.Logger.Errorf("Device unreachable: %v", err)
 		a.statusLabel.SetText("Status: Device Unreachable")
 		dialog.ShowError(fmt.Errorf("Cannot reach device at %s", a.Config.Device.DefaultIP), a.MainWindow)
@@ -354,13 +472,14 @@ func (a *App) onConnect() {
 
 	if password == "" {
 		a.statusLabel.SetText("Status: Password Required")
+		a.ShowPasswordDialog()
 		return
 	}
 
-	if err := a.APIClient.Login(username, password); err != nil {
+	if err := a.APIClient.LoginContext(a.ctx, username, password); err != nil {
 		a.Logger.Errorf("Login failed: %v", err)
-		a.statusLabel.SetText("Status: Login Failed")
-		dialog.ShowError(fmt.Errorf("Login failed: %v", err), a.MainWindow)
+		a.statusLabel.SetText(loginStatusText(err))
+		a.showAPIError("Login Failed", err)
 		return
 	}
 
@@ -375,7 +494,7 @@ func (a *App) onConnect() {
 func (a *App) onDisconnect() {
 	a.stopPollingNow()
 
-	if err := a.APIClient.Logout(); err != nil {
+	if err := a.APIClient.LogoutContext(a.ctx); err != nil {
 		a.Logger.Errorf("Logout failed: %v", err)
 	}
 
@@ -391,11 +510,11 @@ func (a *App) onRefresh() {
 		return
 	}
 
-	status, err := a.APIClient.GetDeviceStatus()
+	status, err := a.APIClient.GetDeviceStatusContext(a.ctx)
 	if err != nil {
 		a.Logger.Errorf("Failed to get device status: %v", err)
 		a.statusLabel.SetText("Status: Error fetching data")
-		dialog.ShowError(fmt.Errorf("Failed to fetch device status: %v", err), a.MainWindow)
+		a.showAPIError("Failed to Fetch Device Status", err)
 		return
 	}
 
@@ -469,10 +588,16 @@ func (a *App) resetLabels() {
 }
 
 func (a *App) isConnected() bool {
-	return a.APIClient.IsAuthenticated()
+	return a.APIClient.IsAuthenticatedContext(a.ctx)
 }
 
-// startPolling starts automatic status refresh every 3 seconds
+// smsSyncInterval is how often the poller syncs every message when the
+// device message count has not changed
+const smsSyncInterval = time.Minute
+
+// startPolling starts automatic status refresh every 3 seconds. Messages
+// are synced when the device message count changes and every
+// smsSyncInterval otherwise, since a sync reads every page of them.
 func (a *App) startPolling() {
 	if a.pollingTicker != nil {
 		return
@@ -482,17 +607,43 @@ func (a *App) startPolling() {
 	a.pollingTicker = time.NewTicker(3 * time.Second)
 
 	go func() {
+		// online is false until the device answers, so queued messages are
+		// retried as soon as the connection comes back
+		online := false
+		smsCount := -1
+		var smsSynced time.Time
+
 		for {
 			select {
 			case <-a.pollingTicker.C:
 				if a.isConnected() {
-					status, err := a.APIClient.GetDeviceStatus()
+					status, err := a.APIClient.GetDeviceStatusContext(a.ctx)
 					if err != nil {
-						a.Logger.Errorf("Failed to get device status: %v", err)
+						if !errors.Is(err, context.Canceled) {
+							a.Logger.Errorf("Failed to get device status: %v", err)
+						}
+						online = false
 						continue
 					}
 					a.updateStatusSafe(status)
-					a.checkForNewSMS()
+
+					count, err := a.APIClient.GetSMSCountContext(a.ctx)
+					if err == nil && count != smsCount || time.Since(smsSynced) >= smsSyncInterval {
+						if a.checkForNewSMS(status.IMEI) {
+							smsSynced = time.Now()
+							if err == nil {
+								smsCount = count
+							}
+						}
+					}
+					a.scheduleBalanceCheck(status.NetworkProvider)
+
+					if !online {
+						online = true
+						a.outbox.Wake()
+						a.refreshContacts(a.ctx, nil)
+					}
+					go a.outbox.Flush(a.ctx)
 				} else {
 					a.stopPolling <- true
 				}
@@ -500,6 +651,10 @@ func (a *App) startPolling() {
 				a.pollingTicker.Stop()
 				a.pollingTicker = nil
 				return
+			case <-a.ctx.Done():
+				a.pollingTicker.Stop()
+				a.pollingTicker = nil
+				return
 			}
 		}
 	}()
@@ -526,10 +681,10 @@ func (a *App) onRestart() {
 			if confirmed {
 				a.stopPollingNow()
 
-				err := a.APIClient.RebootDevice()
+				err := a.APIClient.RebootDeviceContext(a.ctx)
 				if err != nil {
 					a.Logger.Errorf("Failed to restart device: %v", err)
-					dialog.ShowError(fmt.Errorf("failed to restart device: %v", err), a.MainWindow)
+					a.showAPIError("Restart Failed", err)
 					return
 				}
 
@@ -565,10 +720,10 @@ func (a *App) onShutdown() {
 			if confirmed {
 				a.stopPollingNow()
 
-				err := a.APIClient.ShutdownDevice()
+				err := a.APIClient.ShutdownDeviceContext(a.ctx)
 				if err != nil {
 					a.Logger.Errorf("Failed to shutdown device: %v", err)
-					dialog.ShowError(fmt.Errorf("failed to shutdown device: %v", err), a.MainWindow)
+					a.showAPIError("Shutdown Failed", err)
 					return
 				}
 
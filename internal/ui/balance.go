@@ -0,0 +1,375 @@
+package ui
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/sirupsen/logrus"
+
+	"mifi_app/internal/api"
+	"mifi_app/internal/balance"
+)
+
+// openBalanceHistory opens the balance history in the config directory. If
+// it cannot be read, results are kept in memory only.
+func openBalanceHistory(logger *logrus.Logger) *balance.History {
+	path, err := balance.DefaultHistoryPath()
+	if err != nil {
+		logger.Warnf("Balance history is not saved: %v", err)
+		return balance.NewHistory()
+	}
+
+	history, err := balance.OpenHistory(path)
+	if err != nil {
+		logger.Warnf("Balance history is not saved: %v", err)
+		return balance.NewHistory()
+	}
+	return history
+}
+
+// createBalanceCard builds the dashboard card showing the latest balance
+func (a *App) createBalanceCard() fyne.CanvasObject {
+	a.balanceAmountLabel = widget.NewLabel("N/A")
+	a.balanceDataLabel = widget.NewLabel("N/A")
+	a.balanceExpiryLabel = widget.NewLabel("N/A")
+	a.balanceCheckedLabel = widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	a.balanceCheckedLabel.Truncation = fyne.TextTruncateEllipsis
+
+	grid := container.New(layout.NewFormLayout(),
+		widget.NewLabelWithStyle("Airtime:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		a.balanceAmountLabel,
+		widget.NewLabelWithStyle("Data:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		a.balanceDataLabel,
+		widget.NewLabelWithStyle("Expires:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		a.balanceExpiryLabel,
+	)
+
+	checkBtn := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
+		a.startBalanceCheck(true)
+	})
+	historyBtn := widget.NewButtonWithIcon("", theme.HistoryIcon(), a.ShowBalanceHistoryDialog)
+	settingsBtn := widget.NewButtonWithIcon("", theme.SettingsIcon(), a.ShowBalanceSettingsDialog)
+
+	a.updateBalanceCard()
+
+	content := container.NewVBox(
+		grid,
+		container.NewBorder(nil, nil, nil, container.NewHBox(checkBtn, historyBtn, settingsBtn), a.balanceCheckedLabel),
+	)
+	return a.createCard("Balance", content, theme.AccountIcon())
+}
+
+// updateBalanceCard shows the latest result on the dashboard
+func (a *App) updateBalanceCard() {
+	if a.balanceAmountLabel == nil {
+		return
+	}
+
+	latest := a.balanceHistory.Latest()
+	if latest == nil {
+		a.balanceAmountLabel.SetText("N/A")
+		a.balanceDataLabel.SetText("N/A")
+		a.balanceExpiryLabel.SetText("N/A")
+		if a.Config.Balance.Enabled {
+			a.balanceCheckedLabel.SetText("Not checked yet")
+		} else {
+			a.balanceCheckedLabel.SetText("Scheduled checks are off")
+		}
+		return
+	}
+
+	a.balanceAmountLabel.SetText(cmp.Or(latest.BalanceText(), "N/A"))
+	if low := a.Config.Balance.LowBalance; low > 0 && latest.Balance != nil && *latest.Balance < low {
+		a.balanceAmountLabel.Importance = widget.DangerImportance
+	} else {
+		a.balanceAmountLabel.Importance = widget.MediumImportance
+	}
+	a.balanceAmountLabel.Refresh()
+
+	a.balanceDataLabel.SetText(cmp.Or(latest.DataText(), "N/A"))
+	a.balanceExpiryLabel.SetText(expiryText(latest.Expiry))
+	a.balanceCheckedLabel.SetText(fmt.Sprintf("Checked %s via %s", latest.Time.Format("Jan 2 15:04"), sourceName(latest.Source)))
+}
+
+// scheduleBalanceCheck starts a balance check when one is due. operator is
+// the network provider the device reports.
+func (a *App) scheduleBalanceCheck(operator string) {
+	a.balanceMu.Lock()
+	a.balanceOperator = operator
+	due := a.Config.Balance.Enabled && !a.ussdDialogOpen && !time.Now().Before(a.balanceNext)
+	a.balanceMu.Unlock()
+
+	if due {
+		a.startBalanceCheck(false)
+	}
+}
+
+// startBalanceCheck checks the balance in the background unless a check is
+// already running. Failures of checks the user asked for are shown in a
+// dialog; scheduled ones are only logged.
+func (a *App) startBalanceCheck(manual bool) {
+	a.balanceMu.Lock()
+	if a.balanceRunning {
+		a.balanceMu.Unlock()
+		return
+	}
+	a.balanceRunning = true
+	a.balanceNext = time.Now().Add(time.Duration(max(a.Config.Balance.Interval, 1)) * time.Minute)
+	operator := a.balanceOperator
+	cfg := a.Config.Balance
+	a.balanceMu.Unlock()
+
+	if manual {
+		a.balanceCheckedLabel.SetText("Checking...")
+	}
+
+	go func() {
+		defer func() {
+			a.balanceMu.Lock()
+			a.balanceRunning = false
+			a.balanceMu.Unlock()
+		}()
+
+		result, err := balance.Check(a.ctx, a.APIClient, cfg, operator)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			a.Logger.Errorf("Balance check failed: %v", err)
+			fyne.Do(func() {
+				a.updateBalanceCard()
+				a.balanceCheckedLabel.SetText("Last check failed")
+				if manual {
+					a.showAPIError("Balance Check Failed", err)
+				}
+			})
+			return
+		}
+
+		previous := a.balanceHistory.Latest()
+		added, err := a.balanceHistory.Add(result)
+		if err != nil {
+			a.Logger.Warnf("Failed to save balance history: %v", err)
+		}
+		if added {
+			a.Logger.Infof("Balance: %s, data: %s", cmp.Or(result.BalanceText(), "N/A"), cmp.Or(result.DataText(), "N/A"))
+		}
+
+		if added && balance.DroppedBelow(previous, result, cfg.LowBalance) {
+			a.FyneApp.SendNotification(&fyne.Notification{
+				Title:   "Low Balance",
+				Content: fmt.Sprintf("Your airtime balance is %s, below %.2f.", result.BalanceText(), cfg.LowBalance),
+			})
+		}
+
+		fyne.Do(a.updateBalanceCard)
+	}()
+}
+
+// ShowBalanceHistoryDialog lists earlier balance results, newest first
+func (a *App) ShowBalanceHistoryDialog() {
+	all := a.balanceHistory.Results()
+	results := make([]balance.Result, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		results = append(results, all[i])
+	}
+
+	list := widget.NewList(
+		func() int {
+			return len(results)
+		},
+		func() fyne.CanvasObject {
+			when := widget.NewLabelWithStyle("Time", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			values := widget.NewLabel("Values")
+			text := widget.NewLabelWithStyle("Answer", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+			text.Truncation = fyne.TextTruncateEllipsis
+			return container.NewVBox(container.NewHBox(when, layout.NewSpacer(), values), text)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(results) {
+				return
+			}
+			r := results[id]
+			box := obj.(*fyne.Container)
+			top := box.Objects[0].(*fyne.Container)
+
+			top.Objects[0].(*widget.Label).SetText(r.Time.Format("Jan 2, 2006 15:04") + " · " + sourceName(r.Source))
+
+			var values []string
+			if s := r.BalanceText(); s != "" {
+				values = append(values, s)
+			}
+			if s := r.DataText(); s != "" {
+				values = append(values, s)
+			}
+			if !r.Expiry.IsZero() {
+				values = append(values, "until "+r.Expiry.Format("Jan 2"))
+			}
+			top.Objects[2].(*widget.Label).SetText(strings.Join(values, " · "))
+
+			box.Objects[1].(*widget.Label).SetText(strings.ReplaceAll(r.Text, "\n", " "))
+		},
+	)
+
+	var content fyne.CanvasObject = list
+	if len(results) == 0 {
+		content = widget.NewLabel("No balance has been checked yet.")
+	}
+
+	historyDialog := dialog.NewCustom("Balance History", "Close", content, a.MainWindow)
+	historyDialog.Resize(fyne.NewSize(560, 420))
+	historyDialog.Show()
+}
+
+// balanceSources are the Source choices, mapped to config source names
+var balanceSources = []string{"USSD code", "Balance SMS"}
+
+// ShowBalanceSettingsDialog edits the scheduled balance check
+func (a *App) ShowBalanceSettingsDialog() {
+	cfg := a.Config.Balance
+
+	enabledCheck := widget.NewCheck("Check the balance on a schedule", nil)
+	enabledCheck.SetChecked(cfg.Enabled)
+
+	intervalEntry := widget.NewEntry()
+	intervalEntry.SetText(strconv.Itoa(cfg.Interval))
+	intervalEntry.SetPlaceHolder("Minutes between checks")
+
+	codeEntry := widget.NewEntry()
+	codeEntry.SetText(cfg.USSDCode)
+	codeEntry.SetPlaceHolder("e.g. *133#")
+
+	senderEntry := widget.NewEntry()
+	senderEntry.SetText(cfg.SMSSender)
+	senderEntry.SetPlaceHolder("Number or name the operator sends from")
+
+	sourceSelect := widget.NewSelect(balanceSources, func(choice string) {
+		if choice == balanceSources[1] {
+			codeEntry.Disable()
+			senderEntry.Enable()
+		} else {
+			codeEntry.Enable()
+			senderEntry.Disable()
+		}
+	})
+	if cfg.Source == balance.SourceSMS {
+		sourceSelect.SetSelected(balanceSources[1])
+	} else {
+		sourceSelect.SetSelected(balanceSources[0])
+	}
+
+	a.balanceMu.Lock()
+	detected := a.balanceOperator
+	a.balanceMu.Unlock()
+
+	operatorEntry := widget.NewEntry()
+	operatorEntry.SetText(cfg.Operator)
+	operatorEntry.SetPlaceHolder(cmp.Or(detected, "Detected from the network"))
+
+	lowEntry := widget.NewEntry()
+	if cfg.LowBalance > 0 {
+		lowEntry.SetText(strconv.FormatFloat(cfg.LowBalance, 'f', -1, 64))
+	}
+	lowEntry.SetPlaceHolder("Off")
+
+	form := &widget.Form{
+		Items: []*widget.FormItem{
+			{Text: "Scheduled Check", Widget: enabledCheck},
+			{Text: "Interval (min)", Widget: intervalEntry},
+			{Text: "Source", Widget: sourceSelect},
+			{Text: "USSD Code", Widget: codeEntry},
+			{Text: "SMS Sender", Widget: senderEntry},
+			{Text: "Operator", Widget: operatorEntry, HintText: "Picks the template that reads the answer"},
+			{Text: "Low Balance", Widget: lowEntry, HintText: "Notify when the balance drops below this"},
+		},
+	}
+
+	settingsDialog := dialog.NewCustomConfirm("Balance Settings", "Save", "Cancel", form, func(save bool) {
+		if !save {
+			return
+		}
+
+		interval, err := strconv.Atoi(strings.TrimSpace(intervalEntry.Text))
+		if err != nil || interval < 1 {
+			dialog.ShowError(errors.New("invalid interval. Must be a number of minutes >= 1"), a.MainWindow)
+			return
+		}
+
+		var low float64
+		if text := strings.TrimSpace(lowEntry.Text); text != "" {
+			low, err = strconv.ParseFloat(text, 64)
+			if err != nil || low < 0 {
+				dialog.ShowError(errors.New("invalid low balance. Must be a positive amount"), a.MainWindow)
+				return
+			}
+		}
+
+		code := strings.TrimSpace(codeEntry.Text)
+		if code != "" && !api.ValidUSSDCode(code) {
+			dialog.ShowError(errors.New("invalid USSD code. Use digits, * and # only, ending in #"), a.MainWindow)
+			return
+		}
+
+		source := balance.SourceUSSD
+		if sourceSelect.Selected == balanceSources[1] {
+			source = balance.SourceSMS
+		}
+
+		a.balanceMu.Lock()
+		a.Config.Balance.Enabled = enabledCheck.Checked
+		a.Config.Balance.Interval = interval
+		a.Config.Balance.Source = source
+		a.Config.Balance.USSDCode = code
+		a.Config.Balance.SMSSender = strings.TrimSpace(senderEntry.Text)
+		a.Config.Balance.Operator = strings.TrimSpace(operatorEntry.Text)
+		a.Config.Balance.LowBalance = low
+
+		// Check with the new settings at the next poll
+		a.balanceNext = time.Time{}
+		a.balanceMu.Unlock()
+
+		if err := a.Config.Save(); err != nil {
+			a.Logger.Errorf("Failed to save settings: %v", err)
+			dialog.ShowError(err, a.MainWindow)
+			return
+		}
+		a.updateBalanceCard()
+	}, a.MainWindow)
+	settingsDialog.Resize(fyne.NewSize(460, 420))
+	settingsDialog.Show()
+}
+
+// expiryText formats an expiry date with the days left
+func expiryText(expiry time.Time) string {
+	if expiry.IsZero() {
+		return "N/A"
+	}
+
+	days := int(time.Until(expiry).Hours() / 24)
+	switch {
+	case days < 0:
+		return expiry.Format("Jan 2, 2006") + " (expired)"
+	case days == 0:
+		return expiry.Format("Jan 2, 2006") + " (today)"
+	}
+	return fmt.Sprintf("%s (%d days)", expiry.Format("Jan 2, 2006"), days)
+}
+
+// sourceName names where a balance came from
+func sourceName(source string) string {
+	if source == balance.SourceSMS {
+		return "SMS"
+	}
+	return "USSD"
+}
@@ -0,0 +1,270 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"mifi_app/internal/addressbook"
+	"mifi_app/internal/api"
+)
+
+// recipientNumber matches a phone number or short code, optionally with a
+// leading +
+var recipientNumber = regexp.MustCompile(`^\+?[0-9]{2,20}$`)
+
+// parseRecipients splits a list of numbers separated by commas, semicolons
+// or newlines and rejects anything that is not a number. Spaces, dashes and
+// brackets inside a number are ignored.
+func parseRecipients(text string) ([]string, error) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n'
+	})
+
+	var numbers []string
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		number := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(f)
+		if !recipientNumber.MatchString(number) {
+			return nil, fmt.Errorf("%q is not a valid phone number", f)
+		}
+		if !seen[number] {
+			seen[number] = true
+			numbers = append(numbers, number)
+		}
+	}
+
+	if len(numbers) == 0 {
+		return nil, errors.New("enter at least one recipient")
+	}
+	return numbers, nil
+}
+
+// maxSuggestions is how many contacts the recipient field suggests
+const maxSuggestions = 5
+
+// recipientSeparators split the numbers typed in the recipient field
+const recipientSeparators = ",;\n"
+
+// recipientSuggestions finds contacts in the address book and the device
+// phonebook whose name or number contains query
+func (a *App) recipientSuggestions(query string) []addressbook.Match {
+	matches := a.addressBook.Search(query, maxSuggestions)
+
+	seen := make(map[string]bool)
+	for _, m := range matches {
+		seen[api.NumberKey(m.Number)] = true
+	}
+	for _, c := range a.contacts {
+		if len(matches) == maxSuggestions {
+			break
+		}
+		if !contactMatches(c, query) || seen[api.NumberKey(c.Number)] {
+			continue
+		}
+		seen[api.NumberKey(c.Number)] = true
+		matches = append(matches, addressbook.Match{Name: c.Name, Number: c.Number})
+	}
+	return matches
+}
+
+// smsCounterText describes the encoding and segment use of a message body
+func smsCounterText(info api.SMSInfo) string {
+	encoding := "GSM-7"
+	if info.Encoding == api.EncodingUnicode {
+		encoding = "Unicode"
+	}
+
+	text := fmt.Sprintf("%s · %d characters · %d left · %d/%d SMS",
+		encoding, info.Length, info.Remaining, info.Segments, api.MaxSMSSegments)
+	if info.TooLong() {
+		text += " · too long"
+	}
+	return text
+}
+
+// ShowComposeDialog opens the message editor. recipients and body prefill
+// the fields, for replies.
+func (a *App) ShowComposeDialog(recipients, body string) {
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("+265991234567, +265881234567")
+	toEntry.SetText(recipients)
+
+	bodyEntry := widget.NewMultiLineEntry()
+	bodyEntry.SetPlaceHolder("Message")
+	bodyEntry.Wrapping = fyne.TextWrapWord
+	bodyEntry.SetMinRowsVisible(6)
+
+	counterLabel := widget.NewLabel("")
+	progressLabel := widget.NewLabel("")
+	progress := widget.NewProgressBar()
+	progress.Hide()
+
+	sendBtn := widget.NewButtonWithIcon("Send", theme.MailSendIcon(), nil)
+	sendBtn.Importance = widget.HighImportance
+	cancelBtn := widget.NewButton("Cancel", nil)
+
+	updateCounter := func(text string) {
+		info := api.AnalyzeSMS(text)
+		counterLabel.SetText(smsCounterText(info))
+		if info.TooLong() || info.Length == 0 {
+			sendBtn.Disable()
+		} else {
+			sendBtn.Enable()
+		}
+	}
+	bodyEntry.OnChanged = updateCounter
+	bodyEntry.SetText(body)
+	updateCounter(body)
+
+	// Contacts matching the number or name being typed are offered below
+	// the field; choosing one replaces what was typed with its number
+	suggestions := container.NewVBox()
+	toEntry.OnChanged = func(text string) {
+		suggestions.Objects = nil
+		typed := strings.TrimLeft(text[strings.LastIndexAny(text, recipientSeparators)+1:], " ")
+		if query := strings.TrimSpace(typed); len([]rune(query)) >= 2 {
+			for _, m := range a.recipientSuggestions(query) {
+				number := m.Number
+				btn := widget.NewButtonWithIcon(m.Name+" · "+number, theme.AccountIcon(), func() {
+					toEntry.SetText(text[:len(text)-len(typed)] + number + ", ")
+					a.MainWindow.Canvas().Focus(toEntry)
+				})
+				btn.Alignment = widget.ButtonAlignLeading
+				btn.Importance = widget.LowImportance
+				suggestions.Add(btn)
+			}
+		}
+		suggestions.Refresh()
+	}
+
+	form := &widget.Form{
+		Items: []*widget.FormItem{
+			{Text: "To", Widget: toEntry, HintText: "Type a name or number, separate several with commas"},
+		},
+	}
+
+	content := container.NewBorder(
+		container.NewVBox(form, suggestions),
+		container.NewVBox(
+			container.NewHBox(counterLabel, layout.NewSpacer(), progressLabel),
+			progress,
+		),
+		nil,
+		nil,
+		bodyEntry,
+	)
+
+	composeDialog := dialog.NewCustomWithoutButtons("New Message", content, a.MainWindow)
+	composeDialog.SetButtons([]fyne.CanvasObject{cancelBtn, sendBtn})
+	composeDialog.Resize(fyne.NewSize(520, 380))
+
+	// Waiting for sends is abandoned when the dialog closes; unsent
+	// messages stay queued in the outbox
+	ctx, cancel := context.WithCancel(a.ctx)
+	composeDialog.SetOnClosed(cancel)
+	cancelBtn.OnTapped = composeDialog.Hide
+
+	setSending := func(sending bool) {
+		if sending {
+			toEntry.Disable()
+			bodyEntry.Disable()
+			sendBtn.Disable()
+			progress.SetValue(0)
+			progress.Show()
+			return
+		}
+		toEntry.Enable()
+		bodyEntry.Enable()
+		sendBtn.Enable()
+		progress.Hide()
+		progressLabel.SetText("")
+	}
+
+	sendBtn.OnTapped = func() {
+		numbers, err := parseRecipients(toEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, a.MainWindow)
+			return
+		}
+
+		text := bodyEntry.Text
+		setSending(true)
+
+		// Every recipient is queued first, so nothing is lost if sending
+		// is interrupted; the outbox retries whatever fails
+		var ids []string
+		for _, number := range numbers {
+			item, err := a.outbox.Add(number, text)
+			if err != nil {
+				a.Logger.Errorf("Failed to save outbox: %v", err)
+			}
+			ids = append(ids, item.ID)
+		}
+
+		go func() {
+			var failed []string
+			var firstErr error
+
+			for i, id := range ids {
+				fyne.Do(func() {
+					progressLabel.SetText(fmt.Sprintf("Sending %d of %d...", i+1, len(numbers)))
+				})
+
+				err := a.outbox.Send(ctx, id)
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				if err != nil {
+					a.Logger.Errorf("Failed to send SMS to %s: %v", numbers[i], err)
+					failed = append(failed, numbers[i])
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					a.Logger.Infof("Sent SMS to %s", numbers[i])
+				}
+
+				fyne.Do(func() {
+					progress.SetValue(float64(i+1) / float64(len(numbers)))
+				})
+			}
+
+			fyne.Do(func() {
+				setSending(false)
+				composeDialog.Hide()
+
+				if firstErr != nil {
+					a.showAPIError(fmt.Sprintf("Failed to Send to %d of %d Recipients", len(failed), len(numbers)),
+						fmt.Errorf("%w; the message to %s is kept in the outbox",
+							firstErr, strings.Join(failed, ", ")))
+					return
+				}
+
+				dialog.ShowInformation("Message Sent",
+					fmt.Sprintf("Message sent to %d recipient(s).", len(numbers)),
+					a.MainWindow)
+			})
+		}()
+	}
+
+	composeDialog.Show()
+	if recipients == "" {
+		a.MainWindow.Canvas().Focus(toEntry)
+	} else {
+		a.MainWindow.Canvas().Focus(bodyEntry)
+	}
+}
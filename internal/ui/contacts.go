@@ -0,0 +1,396 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"mifi_app/internal/api"
+)
+
+// Choices of the store select in the contact form
+const (
+	contactStoreDevice = "Device"
+	contactStoreSIM    = "SIM card"
+)
+
+// refreshContacts loads the phonebook in the background and indexes the
+// contact names by number. onLoaded, if set, runs on the UI thread with the
+// error of the load.
+func (a *App) refreshContacts(ctx context.Context, onLoaded func(error)) {
+	book, ok := a.APIClient.(api.Phonebook)
+	if !ok {
+		return
+	}
+
+	go func() {
+		contacts, err := book.GetContactsContext(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			a.Logger.Errorf("Failed to load contacts: %v", err)
+		}
+
+		fyne.Do(func() {
+			if err == nil {
+				a.contacts = contacts
+				a.contactNames = contactNameIndex(contacts)
+				a.updateRecentSMSContent()
+			}
+			if onLoaded != nil {
+				onLoaded(err)
+			}
+		})
+	}()
+}
+
+// contactNameIndex maps the api.NumberKey of every number of contacts to
+// its name
+func contactNameIndex(contacts []api.Contact) map[string]string {
+	names := make(map[string]string)
+	for _, c := range contacts {
+		for _, number := range c.Numbers() {
+			key := api.NumberKey(number)
+			if _, taken := names[key]; !taken {
+				names[key] = c.Name
+			}
+		}
+	}
+	return names
+}
+
+// setCountryCode changes the default country code of api.NumberKey and
+// matches the contact numbers again. It must run on the UI thread.
+func (a *App) setCountryCode(code string) {
+	if err := api.SetDefaultCountryCode(code); err != nil {
+		a.Logger.Warnf("Ignoring the SMS country code: %v", err)
+		return
+	}
+	a.addressBook.Reindex()
+	a.contactNames = contactNameIndex(a.contacts)
+	a.updateRecentSMSContent()
+}
+
+// contactName returns the name saved for number in the device phonebook or
+// the address book, or "" if there is none. It must run on the UI thread.
+func (a *App) contactName(number string) string {
+	if name := a.contactNames[api.NumberKey(number)]; name != "" {
+		return name
+	}
+	if c, ok := a.addressBook.Lookup(number); ok {
+		return c.Name
+	}
+	return ""
+}
+
+// displayName returns the contact name for number, or the number itself
+func (a *App) displayName(number string) string {
+	if name := a.contactName(number); name != "" {
+		return name
+	}
+	return number
+}
+
+// contactMatches reports whether query is part of the name or a number of c
+func contactMatches(c api.Contact, query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" || strings.Contains(strings.ToLower(c.Name), query) {
+		return true
+	}
+	for _, number := range c.Numbers() {
+		if strings.Contains(number, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShowContactsDialog lists the phonebook with actions to add, edit, delete
+// and message contacts
+func (a *App) ShowContactsDialog() {
+	book, ok := a.APIClient.(api.Phonebook)
+	if !ok {
+		dialog.ShowInformation("Contacts", "The device backend in use has no phonebook.", a.MainWindow)
+		return
+	}
+
+	// Requests started from this dialog are abandoned when it closes
+	ctx, cancel := context.WithCancel(a.ctx)
+
+	var shown []api.Contact
+	selected := -1
+
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("Search by name or number")
+
+	list := widget.NewList(
+		func() int {
+			return len(shown)
+		},
+		func() fyne.CanvasObject {
+			name := widget.NewLabelWithStyle("Name", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			store := widget.NewLabelWithStyle("Store", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+			numbers := widget.NewLabel("Numbers")
+			numbers.Truncation = fyne.TextTruncateEllipsis
+
+			return container.NewVBox(
+				container.NewHBox(name, layout.NewSpacer(), store),
+				numbers,
+				widget.NewSeparator(),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(shown) {
+				return
+			}
+			c := shown[id]
+			box := obj.(*fyne.Container)
+
+			top := box.Objects[0].(*fyne.Container)
+			top.Objects[0].(*widget.Label).SetText(c.Name)
+			top.Objects[2].(*widget.Label).SetText(storeName(c.Store))
+
+			details := strings.Join(c.Numbers(), ", ")
+			if c.Email != "" {
+				details += " · " + c.Email
+			}
+			box.Objects[1].(*widget.Label).SetText(details)
+		},
+	)
+
+	emptyLabel := widget.NewLabel("No contacts.")
+	emptyLabel.Hide()
+
+	editBtn := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), nil)
+	deleteBtn := widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), nil)
+	messageBtn := widget.NewButtonWithIcon("Message", theme.MailComposeIcon(), nil)
+	selectionActions := []fyne.Disableable{editBtn, deleteBtn, messageBtn}
+
+	setSelected := func(id int) {
+		selected = id
+		for _, action := range selectionActions {
+			if id >= 0 {
+				action.Enable()
+			} else {
+				action.Disable()
+			}
+		}
+	}
+	setSelected(-1)
+
+	filter := func() {
+		shown = shown[:0]
+		for _, c := range a.contacts {
+			if contactMatches(c, searchEntry.Text) {
+				shown = append(shown, c)
+			}
+		}
+		list.UnselectAll()
+		setSelected(-1)
+		emptyLabel.Hidden = len(shown) > 0
+		emptyLabel.Refresh()
+		list.Refresh()
+	}
+	searchEntry.OnChanged = func(string) {
+		filter()
+	}
+	list.OnSelected = setSelected
+	list.OnUnselected = func(widget.ListItemID) {
+		setSelected(-1)
+	}
+
+	reload := func() {
+		a.refreshContacts(ctx, func(err error) {
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					a.showAPIError("Failed to Load Contacts", err)
+				}
+				return
+			}
+			filter()
+		})
+	}
+
+	addBtn := widget.NewButtonWithIcon("Add", theme.ContentAddIcon(), func() {
+		a.showContactForm(ctx, book, nil, reload)
+	})
+	editBtn.OnTapped = func() {
+		if selected >= 0 && selected < len(shown) {
+			c := shown[selected]
+			a.showContactForm(ctx, book, &c, reload)
+		}
+	}
+	messageBtn.OnTapped = func() {
+		if selected >= 0 && selected < len(shown) {
+			a.ShowComposeDialog(shown[selected].Number, "")
+		}
+	}
+	deleteBtn.OnTapped = func() {
+		if selected < 0 || selected >= len(shown) {
+			return
+		}
+		c := shown[selected]
+		dialog.ShowConfirm("Delete Contact", fmt.Sprintf("Delete %s (%s)?", c.Name, c.Number), func(ok bool) {
+			if !ok {
+				return
+			}
+			go func() {
+				err := book.DeleteContactsContext(ctx, []string{c.ID})
+				fyne.Do(func() {
+					if err != nil {
+						if !errors.Is(err, context.Canceled) {
+							a.Logger.Errorf("Failed to delete contact %s: %v", c.ID, err)
+							a.showAPIError("Failed to Delete Contact", err)
+						}
+						return
+					}
+					reload()
+				})
+			}()
+		}, a.MainWindow)
+	}
+	refreshBtn := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), reload)
+
+	buttons := container.NewHBox(addBtn, editBtn, deleteBtn, messageBtn, layout.NewSpacer(), refreshBtn)
+
+	content := container.NewBorder(
+		container.NewVBox(buttons, searchEntry),
+		nil,
+		nil,
+		nil,
+		container.NewStack(list, emptyLabel),
+	)
+
+	contactsDialog := dialog.NewCustom("Contacts", "Close", content, a.MainWindow)
+	contactsDialog.Resize(fyne.NewSize(560, 480))
+	contactsDialog.SetOnClosed(cancel)
+
+	filter()
+	reload()
+
+	contactsDialog.Show()
+}
+
+// showContactForm edits contact, or adds a new one when contact is nil, and
+// calls onSaved on the UI thread once the device has stored it
+func (a *App) showContactForm(ctx context.Context, book api.Phonebook, contact *api.Contact, onSaved func()) {
+	adding := contact == nil
+	if adding {
+		contact = &api.Contact{Store: api.SMSStoreDevice}
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(contact.Name)
+	nameEntry.Validator = func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return errors.New("enter a name")
+		}
+		return nil
+	}
+
+	numberEntry := widget.NewEntry()
+	numberEntry.SetPlaceHolder("+265991234567")
+	numberEntry.SetText(contact.Number)
+	numberEntry.Validator = validateContactNumber
+
+	homeEntry := widget.NewEntry()
+	homeEntry.SetText(contact.HomeNumber)
+	officeEntry := widget.NewEntry()
+	officeEntry.SetText(contact.OfficeNumber)
+	emailEntry := widget.NewEntry()
+	emailEntry.SetText(contact.Email)
+	deviceOnly := []fyne.Disableable{homeEntry, officeEntry, emailEntry}
+
+	// SIM entries hold only a name and a mobile number
+	storeSelect := widget.NewSelect([]string{contactStoreDevice, contactStoreSIM}, func(choice string) {
+		for _, entry := range deviceOnly {
+			if choice == contactStoreSIM {
+				entry.Disable()
+			} else {
+				entry.Enable()
+			}
+		}
+	})
+	if contact.Store == api.SMSStoreSIM {
+		storeSelect.SetSelected(contactStoreSIM)
+	} else {
+		storeSelect.SetSelected(contactStoreDevice)
+	}
+	if !adding {
+		// The device cannot move a contact between the SIM and its memory
+		storeSelect.Disable()
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Mobile", numberEntry),
+		widget.NewFormItem("Saved on", storeSelect),
+		widget.NewFormItem("Home", homeEntry),
+		widget.NewFormItem("Office", officeEntry),
+		widget.NewFormItem("Email", emailEntry),
+	}
+
+	title := "Edit Contact"
+	if adding {
+		title = "Add Contact"
+	}
+
+	form := dialog.NewForm(title, "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+
+		saved := *contact
+		saved.Name = strings.TrimSpace(nameEntry.Text)
+		saved.Number = strings.TrimSpace(numberEntry.Text)
+		saved.HomeNumber, saved.OfficeNumber, saved.Email = "", "", ""
+		saved.Store = api.SMSStoreDevice
+		if storeSelect.Selected == contactStoreSIM {
+			saved.Store = api.SMSStoreSIM
+		} else {
+			saved.HomeNumber = strings.TrimSpace(homeEntry.Text)
+			saved.OfficeNumber = strings.TrimSpace(officeEntry.Text)
+			saved.Email = strings.TrimSpace(emailEntry.Text)
+		}
+
+		go func() {
+			var err error
+			if adding {
+				err = book.AddContactContext(ctx, &saved)
+			} else {
+				err = book.UpdateContactContext(ctx, &saved)
+			}
+
+			fyne.Do(func() {
+				if err != nil {
+					if !errors.Is(err, context.Canceled) {
+						a.Logger.Errorf("Failed to save contact %q: %v", saved.Name, err)
+						a.showAPIError("Failed to Save Contact", err)
+					}
+					return
+				}
+				onSaved()
+			})
+		}()
+	}, a.MainWindow)
+	form.Resize(fyne.NewSize(420, 0))
+	form.Show()
+}
+
+// validateContactNumber accepts a single phone number or short code
+func validateContactNumber(s string) error {
+	numbers, err := parseRecipients(s)
+	if err != nil {
+		return err
+	}
+	if len(numbers) > 1 {
+		return errors.New("enter a single number")
+	}
+	return nil
+}
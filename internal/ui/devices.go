@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"fyne.io/fyne/v2"
@@ -41,9 +43,12 @@ func (a *App) ShowDevicesDialog() {
 		},
 	)
 
+	// Requests started from this dialog are abandoned when it closes
+	ctx, cancel := context.WithCancel(a.ctx)
+
 	// Refresh button
 	refreshBtn := widget.NewButton("Refresh", func() {
-		a.refreshDevicesList(devicesList)
+		a.refreshDevicesList(ctx, devicesList)
 	})
 
 	buttons := container.NewHBox(refreshBtn)
@@ -60,22 +65,32 @@ func (a *App) ShowDevicesDialog() {
 	// Create dialog
 	devicesDialog := dialog.NewCustom("Connected Devices", "Close", content, a.MainWindow)
 	devicesDialog.Resize(fyne.NewSize(500, 400))
+	devicesDialog.SetOnClosed(cancel)
 
 	// Load initial devices list
-	a.refreshDevicesList(devicesList)
+	a.refreshDevicesList(ctx, devicesList)
 
 	devicesDialog.Show()
 }
 
-// refreshDevicesList fetches and refreshes the connected devices list
-func (a *App) refreshDevicesList(list *widget.List) {
-	devices, err := a.APIClient.GetConnectedDevices()
-	if err != nil {
-		a.Logger.Errorf("Failed to fetch connected devices: %v", err)
-		dialog.ShowError(fmt.Errorf("Failed to load connected devices: %v", err), a.MainWindow)
-		return
-	}
+// refreshDevicesList fetches the connected devices list in the background
+// and refreshes list when it arrives
+func (a *App) refreshDevicesList(ctx context.Context, list *widget.List) {
+	go func() {
+		devices, err := a.APIClient.GetConnectedDevicesContext(ctx)
+
+		fyne.Do(func() {
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				a.Logger.Errorf("Failed to fetch connected devices: %v", err)
+				a.showAPIError("Failed to Load Connected Devices", err)
+				return
+			}
 
-	a.cachedDevices = devices
-	list.Refresh()
+			a.cachedDevices = devices
+			list.Refresh()
+		})
+	}()
 }
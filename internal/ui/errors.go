@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"mifi_app/internal/api"
+)
+
+// errorHelp is a user-facing explanation of an API error with an optional
+// recovery action
+type errorHelp struct {
+	message     string
+	actionLabel string
+	action      func()
+}
+
+func (a *App) explainError(err error) errorHelp {
+	var devErr *api.DeviceError
+
+	switch {
+	case errors.Is(err, api.ErrBadPassword):
+		return errorHelp{
+			message:     "The device rejected the password.",
+			actionLabel: "Enter Password",
+			action:      a.ShowPasswordDialog,
+		}
+	case errors.Is(err, api.ErrLockedOut):
+		return errorHelp{
+			message: "Too many failed login attempts. The device has locked logins for a while, wait before trying again.",
+		}
+	case errors.Is(err, api.ErrSIMLocked):
+		return errorHelp{
+			message:     "The SIM card is locked. Enter its PIN as the device password.",
+			actionLabel: "Enter Password",
+			action:      a.ShowPasswordDialog,
+		}
+	case errors.Is(err, api.ErrDuplicateUser):
+		return errorHelp{
+			message:     "Another user is logged in to the device's web interface. Log out there and try again.",
+			actionLabel: "Retry",
+			action:      a.onConnect,
+		}
+	case errors.Is(err, api.ErrSessionExpired):
+		return errorHelp{
+			message:     "The session with the device has expired.",
+			actionLabel: "Reconnect",
+			action:      a.onConnect,
+		}
+	case errors.Is(err, api.ErrMessageTooLong):
+		return errorHelp{
+			message: "The message is longer than the device can send. Shorten it or split it into several messages.",
+		}
+	case errors.Is(err, api.ErrUnsupported):
+		return errorHelp{
+			message: "This device's firmware does not support this action.",
+		}
+	case errors.As(err, &devErr):
+		return errorHelp{
+			message: fmt.Sprintf("The device refused the %s command (result %q).", devErr.Goform, devErr.Result),
+		}
+	}
+
+	return errorHelp{message: err.Error()}
+}
+
+// showAPIError explains err in a dialog and offers a recovery action when
+// one applies
+func (a *App) showAPIError(title string, err error) {
+	help := a.explainError(err)
+
+	messageLabel := widget.NewLabel(help.message)
+	messageLabel.Wrapping = fyne.TextWrapWord
+
+	detailLabel := widget.NewLabelWithStyle(err.Error(), fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	detailLabel.Wrapping = fyne.TextWrapWord
+
+	content := container.NewVBox(messageLabel, detailLabel)
+
+	if help.action == nil {
+		errDialog := dialog.NewCustom(title, "Close", content, a.MainWindow)
+		errDialog.Resize(fyne.NewSize(420, 160))
+		errDialog.Show()
+		return
+	}
+
+	errDialog := dialog.NewCustomConfirm(title, help.actionLabel, "Close", content, func(ok bool) {
+		if ok {
+			help.action()
+		}
+	}, a.MainWindow)
+	errDialog.Resize(fyne.NewSize(420, 160))
+	errDialog.Show()
+}
+
+// loginStatusText returns the status line shown after a failed login
+func loginStatusText(err error) string {
+	switch {
+	case errors.Is(err, api.ErrBadPassword):
+		return "Status: Bad Password"
+	case errors.Is(err, api.ErrLockedOut):
+		return "Status: Login Locked"
+	case errors.Is(err, api.ErrSIMLocked):
+		return "Status: SIM Locked"
+	case errors.Is(err, api.ErrDuplicateUser):
+		return "Status: Another User Logged In"
+	case errors.Is(err, api.ErrUnsupported):
+		return "Status: Unsupported Firmware"
+	}
+	return "Status: Login Failed"
+}
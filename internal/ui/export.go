@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"mifi_app/internal/api"
+	"mifi_app/internal/smsexport"
+)
+
+// ShowExportDialog asks for a format and a file, then writes messages to
+// it. scope describes what is exported, e.g. "2 conversations".
+func (a *App) ShowExportDialog(messages []api.SMSMessage, scope string) {
+	if len(messages) == 0 {
+		dialog.ShowInformation("Export Messages", "There are no messages to export.", a.MainWindow)
+		return
+	}
+
+	descriptions := make([]string, len(smsexport.Formats))
+	formats := make(map[string]smsexport.Format, len(smsexport.Formats))
+	for i, f := range smsexport.Formats {
+		descriptions[i] = f.Description()
+		formats[descriptions[i]] = f
+	}
+
+	formatSelect := widget.NewSelect(descriptions, nil)
+	formatSelect.SetSelected(descriptions[0])
+
+	scopeLabel := widget.NewLabel(fmt.Sprintf("%d message(s) from %s", len(messages), scope))
+	hint := widget.NewLabelWithStyle("SMS Backup & Restore files can be restored onto an Android phone.",
+		fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	hint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewVBox(
+		scopeLabel,
+		widget.NewForm(widget.NewFormItem("Format", formatSelect)),
+		hint,
+	)
+
+	exportDialog := dialog.NewCustomConfirm("Export Messages", "Export", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		a.saveExport(messages, formats[formatSelect.Selected])
+	}, a.MainWindow)
+	exportDialog.Resize(fyne.NewSize(420, 200))
+	exportDialog.Show()
+}
+
+// saveExport asks where to save and writes the export file
+func (a *App) saveExport(messages []api.SMSMessage, format smsexport.Format) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, a.MainWindow)
+			return
+		}
+		if writer == nil {
+			return
+		}
+
+		err = smsexport.Write(writer, format, messages)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			a.Logger.Errorf("Failed to export SMS: %v", err)
+			dialog.ShowError(fmt.Errorf("failed to export messages: %w", err), a.MainWindow)
+			return
+		}
+
+		a.Logger.Infof("Exported %d message(s) to %s", len(messages), writer.URI())
+		dialog.ShowInformation("Export Complete",
+			fmt.Sprintf("Exported %d message(s) to %s.", len(messages), writer.URI().Name()),
+			a.MainWindow)
+	}, a.MainWindow)
+
+	saveDialog.SetFileName(smsexport.FileName(format, time.Now()))
+	saveDialog.Show()
+}
@@ -0,0 +1,177 @@
+package ui
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/sirupsen/logrus"
+
+	"mifi_app/internal/api"
+	"mifi_app/internal/smsoutbox"
+)
+
+// openOutbox opens the outbox in the config directory. If it cannot be read
+// messages are still queued, but only in memory.
+func openOutbox(client api.DeviceAPI, logger *logrus.Logger) *smsoutbox.Outbox {
+	path, err := smsoutbox.DefaultPath()
+	if err != nil {
+		logger.Warnf("SMS outbox is not saved: %v", err)
+		return smsoutbox.New(client)
+	}
+
+	outbox, err := smsoutbox.Open(path, client)
+	if err != nil {
+		logger.Warnf("SMS outbox is not saved: %v", err)
+		return smsoutbox.New(client)
+	}
+	return outbox
+}
+
+// outboxChanged refreshes everything that shows the outbox
+func (a *App) outboxChanged() {
+	for _, refresh := range a.outboxListeners {
+		refresh()
+	}
+}
+
+// outboxStateText describes where a queued message is
+func outboxStateText(item smsoutbox.Item) string {
+	switch item.State {
+	case smsoutbox.StateQueued:
+		return "queued"
+	case smsoutbox.StateSending:
+		return "sending..."
+	case smsoutbox.StateSent:
+		return "sent"
+	}
+
+	if item.WillRetry() {
+		return fmt.Sprintf("failed, retrying at %s (attempt %d of %d)",
+			item.NextAttempt.Format("15:04"), item.Attempts, smsoutbox.MaxAttempts)
+	}
+	return "failed"
+}
+
+// ShowOutboxDialog lists queued, failed and recently sent messages with
+// actions to retry or remove them
+func (a *App) ShowOutboxDialog() {
+	var items []smsoutbox.Item
+
+	list := widget.NewList(
+		func() int {
+			return len(items)
+		},
+		func() fyne.CanvasObject {
+			number := widget.NewLabelWithStyle("Number", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			state := widget.NewLabel("State")
+			content := widget.NewLabel("Message")
+			content.Truncation = fyne.TextTruncateEllipsis
+			errLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+			errLabel.Truncation = fyne.TextTruncateEllipsis
+
+			retryBtn := widget.NewButtonWithIcon("Retry", theme.ViewRefreshIcon(), nil)
+			removeBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), nil)
+
+			return container.NewBorder(nil, nil, nil,
+				container.NewHBox(retryBtn, removeBtn),
+				container.NewVBox(
+					container.NewHBox(number, layout.NewSpacer(), state),
+					content,
+					errLabel,
+				),
+			)
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id >= len(items) {
+				return
+			}
+			item := items[id]
+			row := obj.(*fyne.Container)
+			box := row.Objects[0].(*fyne.Container)
+			buttons := row.Objects[1].(*fyne.Container)
+
+			top := box.Objects[0].(*fyne.Container)
+			top.Objects[0].(*widget.Label).SetText(item.Number)
+
+			state := top.Objects[2].(*widget.Label)
+			state.SetText(outboxStateText(item))
+			if item.State == smsoutbox.StateFailed {
+				state.Importance = widget.DangerImportance
+			} else {
+				state.Importance = widget.MediumImportance
+			}
+			state.Refresh()
+
+			box.Objects[1].(*widget.Label).SetText(item.Content)
+			box.Objects[2].(*widget.Label).SetText(item.Error)
+
+			retryBtn := buttons.Objects[0].(*widget.Button)
+			retryBtn.OnTapped = func() {
+				retry := func() {
+					if err := a.outbox.Retry(item.ID); err != nil {
+						dialog.ShowError(err, a.MainWindow)
+						return
+					}
+					go a.outbox.Flush(a.ctx)
+				}
+				if !item.MayHaveSent() {
+					retry()
+					return
+				}
+				dialog.ShowConfirm("Send Again?",
+					fmt.Sprintf("The device did not confirm the message to %s, so it may have been sent already. Send it again?", item.Number),
+					func(ok bool) {
+						if ok {
+							retry()
+						}
+					}, a.MainWindow)
+			}
+			if item.State == smsoutbox.StateFailed {
+				retryBtn.Show()
+			} else {
+				retryBtn.Hide()
+			}
+
+			removeBtn := buttons.Objects[1].(*widget.Button)
+			removeBtn.OnTapped = func() {
+				if err := a.outbox.Remove(item.ID); err != nil {
+					dialog.ShowError(err, a.MainWindow)
+				}
+			}
+			if item.State == smsoutbox.StateSending {
+				removeBtn.Disable()
+			} else {
+				removeBtn.Enable()
+			}
+		},
+	)
+
+	emptyLabel := widget.NewLabel("The outbox is empty.")
+
+	reload := func() {
+		// Newest first
+		all := a.outbox.Items()
+		items = items[:0]
+		for i := len(all) - 1; i >= 0; i-- {
+			items = append(items, all[i])
+		}
+		emptyLabel.Hidden = len(items) > 0
+		emptyLabel.Refresh()
+		list.Refresh()
+	}
+
+	a.outboxListeners["outbox-dialog"] = reload
+	reload()
+
+	outboxDialog := dialog.NewCustom("Outbox", "Close", container.NewStack(list, emptyLabel), a.MainWindow)
+	outboxDialog.Resize(fyne.NewSize(560, 420))
+	outboxDialog.SetOnClosed(func() {
+		delete(a.outboxListeners, "outbox-dialog")
+	})
+	outboxDialog.Show()
+}
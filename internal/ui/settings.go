@@ -3,6 +3,9 @@ package ui
 import (
 	"errors"
 	"strconv"
+	"time"
+
+	"mifi_app/internal/api"
 
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
@@ -41,6 +44,27 @@ func (a *App) ShowSettingsDialog() {
 	autoReconnectCheck := widget.NewCheck("Automatically reconnect on network issues", nil)
 	autoReconnectCheck.SetChecked(a.Config.Device.AutoReconnect)
 
+	// Device type; applied on restart
+	backendSelect := widget.NewSelect(backendOptions, nil)
+	backendSelect.SetSelected(backendLabel(a.Config.Device.Backend))
+
+	// SMS storage cleanup
+	cleanupCheck := widget.NewCheck("Archive and delete old read messages when storage fills", nil)
+	cleanupCheck.SetChecked(a.Config.SMS.CleanupEnabled)
+
+	thresholdEntry := widget.NewEntry()
+	thresholdEntry.SetText(strconv.Itoa(a.Config.SMS.CleanupThreshold))
+	thresholdEntry.SetPlaceHolder("Percent full")
+
+	targetEntry := widget.NewEntry()
+	targetEntry.SetText(strconv.Itoa(a.Config.SMS.CleanupTarget))
+	targetEntry.SetPlaceHolder("Percent full")
+
+	// Calling code of numbers written without one
+	countryCodeEntry := widget.NewEntry()
+	countryCodeEntry.SetText(a.Config.SMS.CountryCode)
+	countryCodeEntry.SetPlaceHolder("265")
+
 	// Create form
 	form := &widget.Form{
 		Items: []*widget.FormItem{
@@ -51,6 +75,11 @@ func (a *App) ShowSettingsDialog() {
 			{Text: "Log Level", Widget: logLevelSelect},
 			{Text: "Connection Timeout (s)", Widget: timeoutEntry},
 			{Text: "Auto Reconnect", Widget: autoReconnectCheck},
+			{Text: "Device Type", Widget: backendSelect},
+			{Text: "SMS Cleanup", Widget: cleanupCheck},
+			{Text: "Clean Up At (%)", Widget: thresholdEntry, HintText: "Also warns when storage reaches this level"},
+			{Text: "Clean Down To (%)", Widget: targetEntry},
+			{Text: "Country Code", Widget: countryCodeEntry, HintText: "Matches 0999... with +265999... exactly; without it numbers match by their last 7 digits"},
 		},
 	}
 
@@ -73,14 +102,38 @@ func (a *App) ShowSettingsDialog() {
 				logLevelSelect.Selected,
 				timeoutEntry.Text,
 				autoReconnectCheck.Checked,
+				backendSelect.Selected,
+				cleanupCheck.Checked,
+				thresholdEntry.Text,
+				targetEntry.Text,
+				countryCodeEntry.Text,
 			)
 		},
 		a.MainWindow,
 	)
 }
 
+// backendOptions are the Device Type choices, mapped to config backend names
+var backendOptions = []string{"Auto Detect", "ZTE", "Huawei HiLink", "AT Serial Modem"}
+
+var backendNames = map[string]string{
+	"Auto Detect":     api.BackendAuto,
+	"ZTE":             api.BackendZTE,
+	"Huawei HiLink":   api.BackendHiLink,
+	"AT Serial Modem": api.BackendAT,
+}
+
+func backendLabel(backend string) string {
+	for label, name := range backendNames {
+		if name == backend {
+			return label
+		}
+	}
+	return backendOptions[0]
+}
+
 // saveSettings validates and saves the application settings
-func (a *App) saveSettings(theme string, autoStart, notifications bool, pollInterval, logLevel, timeout string, autoReconnect bool) {
+func (a *App) saveSettings(theme string, autoStart, notifications bool, pollInterval, logLevel, timeout string, autoReconnect bool, backend string, cleanup bool, cleanupThreshold, cleanupTarget, countryCode string) {
 	// Validate poll interval
 	poll, err := strconv.Atoi(pollInterval)
 	if err != nil || poll < 1 {
@@ -95,6 +148,25 @@ func (a *App) saveSettings(theme string, autoStart, notifications bool, pollInte
 		return
 	}
 
+	// Validate cleanup levels
+	threshold, err := strconv.Atoi(cleanupThreshold)
+	if err != nil || threshold < 1 || threshold > 100 {
+		dialog.ShowError(errors.New("invalid cleanup level. Must be a percentage from 1 to 100"), a.MainWindow)
+		return
+	}
+	target, err := strconv.Atoi(cleanupTarget)
+	if err != nil || target < 0 || target >= threshold {
+		dialog.ShowError(errors.New("invalid cleanup target. Must be a percentage below the cleanup level"), a.MainWindow)
+		return
+	}
+
+	// Validate country code
+	countryCode, err = api.NormalizeCountryCode(countryCode)
+	if err != nil {
+		dialog.ShowError(errors.New("invalid country code. Enter the calling code such as 265, or leave it empty"), a.MainWindow)
+		return
+	}
+
 	// Update config
 	a.Config.App.Theme = theme
 	a.Config.App.AutoStart = autoStart
@@ -103,6 +175,17 @@ func (a *App) saveSettings(theme string, autoStart, notifications bool, pollInte
 	a.Config.Device.PollInterval = poll
 	a.Config.Device.ConnectionTimeout = t
 	a.Config.Device.AutoReconnect = autoReconnect
+	a.Config.Device.Backend = backendNames[backend]
+	a.Config.SMS.CleanupEnabled = cleanup
+	a.Config.SMS.CleanupThreshold = threshold
+	a.Config.SMS.CleanupTarget = target
+
+	if countryCode != a.Config.SMS.CountryCode {
+		a.Config.SMS.CountryCode = countryCode
+		a.setCountryCode(countryCode)
+	}
+
+	a.APIClient.SetTimeout(time.Duration(t) * time.Second)
 
 	// Save to file
 	if err := a.Config.Save(); err != nil {
@@ -115,3 +198,37 @@ func (a *App) saveSettings(theme string, autoStart, notifications bool, pollInte
 		"Settings have been saved.\\nSome changes may require restart.",
 		a.MainWindow)
 }
+
+// ShowPasswordDialog asks for the device admin password, saves it and reconnects
+func (a *App) ShowPasswordDialog() {
+	passwordEntry := widget.NewPasswordEntry()
+	passwordEntry.SetPlaceHolder("Device admin password")
+
+	form := &widget.Form{
+		Items: []*widget.FormItem{
+			{Text: "Password", Widget: passwordEntry},
+		},
+	}
+
+	dialog.ShowCustomConfirm(
+		"Device Password",
+		"Connect",
+		"Cancel",
+		form,
+		func(ok bool) {
+			if !ok || passwordEntry.Text == "" {
+				return
+			}
+
+			a.Config.Device.Password = passwordEntry.Text
+			if err := a.Config.Save(); err != nil {
+				a.Logger.Errorf("Failed to save password: %v", err)
+				dialog.ShowError(err, a.MainWindow)
+				return
+			}
+
+			a.onConnect()
+		},
+		a.MainWindow,
+	)
+}
@@ -1,93 +1,404 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image/color"
+	"strings"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/sirupsen/logrus"
+
+	"mifi_app/internal/api"
+	"mifi_app/internal/smsarchive"
+	"mifi_app/internal/smsoutbox"
+	"mifi_app/internal/smssync"
 )
 
 func (a *App) ShowSMSDialog() {
-	messageDetail := widget.NewRichTextFromMarkdown("")
-	messageDetail.Wrapping = fyne.TextWrapWord
-	detailScroll := container.NewScroll(messageDetail)
+	threads := api.GroupThreads(a.cachedSMSMessages)
+	selected := ""
+	checked := make(map[string]bool)
+	marker, canMark := a.APIClient.(api.SMSReadMarker)
+
+	// Requests started from this dialog are abandoned when it closes
+	ctx, cancel := context.WithCancel(a.ctx)
+
+	var reload func()
+	reloading := false
+
+	// A search shows archived messages, which may be gone from the device,
+	// so actions on device messages are disabled meanwhile
+	var query smsarchive.Query
+	searching := false
+	deleted := make(map[string]bool)
+	var deviceActions []fyne.Disableable
+
+	threadTitle := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	history := container.NewVBox()
+	historyScroll := container.NewVScroll(history)
+
+	replyBtn := widget.NewButtonWithIcon("Reply", theme.MailReplyIcon(), nil)
+	replyBtn.Disable()
+
+	totalLabel := widget.NewLabel("")
+	storageLabel := widget.NewLabel("")
+	updateStorage := func() {
+		a.loadSMSCapacity(ctx, func(capacity *api.SMSCapacity) {
+			storageLabel.SetText(capacityText(capacity))
+			if len(a.cleanupPolicy().Full(capacity)) > 0 {
+				storageLabel.Importance = widget.WarningImportance
+			} else {
+				storageLabel.Importance = widget.MediumImportance
+			}
+			storageLabel.Refresh()
+		})
+	}
+
+	showThread := func(thread api.Thread) {
+		selected = thread.Key
+		if name := a.contactName(thread.Number); name != "" {
+			threadTitle.SetText(name + " · " + thread.Number)
+		} else {
+			threadTitle.SetText(thread.Number)
+		}
 
-	smsList := widget.NewList(
+		history.Objects = nil
+		for _, msg := range thread.Messages {
+			history.Add(messageBubble(msg, deleted[archivedKey(msg)]))
+		}
+		for _, item := range a.outbox.Items() {
+			if item.Unsent() && api.NumberKey(item.Number) == thread.Key {
+				history.Add(outboxBubble(item))
+			}
+		}
+		history.Refresh()
+		historyScroll.ScrollToBottom()
+
+		number := thread.Latest().Number
+		replyBtn.OnTapped = func() {
+			a.ShowComposeDialog(number, "")
+		}
+		replyBtn.Enable()
+	}
+
+	clearThread := func() {
+		selected = ""
+		threadTitle.SetText("Select a conversation")
+		history.Objects = nil
+		history.Refresh()
+		replyBtn.Disable()
+	}
+
+	threadList := widget.NewList(
 		func() int {
-			return len(a.cachedSMSMessages)
+			return len(threads)
 		},
 		func() fyne.CanvasObject {
 			header := widget.NewLabel("Sender Name")
 			header.TextStyle.Bold = true
 
+			unread := widget.NewLabel("")
+			unread.Importance = widget.HighImportance
+
 			date := widget.NewLabel("Date")
 			date.TextStyle.Italic = true
 
 			preview := widget.NewLabel("Message preview...")
-			preview.Wrapping = fyne.TextTruncate
+			preview.Truncation = fyne.TextTruncateEllipsis
 
-			return container.NewVBox(
-				header,
-				date,
-				preview,
-				widget.NewSeparator(),
+			return container.NewBorder(nil, nil, widget.NewCheck("", nil), nil,
+				container.NewVBox(
+					container.NewHBox(header, layout.NewSpacer(), unread),
+					date,
+					preview,
+					widget.NewSeparator(),
+				),
 			)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			if id < len(a.cachedSMSMessages) {
-				msg := a.cachedSMSMessages[id]
-				box := obj.(*fyne.Container)
+			if id >= len(threads) {
+				return
+			}
+			thread := threads[id]
+			latest := thread.Latest()
+			row := obj.(*fyne.Container)
+			box := row.Objects[0].(*fyne.Container)
+
+			check := row.Objects[1].(*widget.Check)
+			check.OnChanged = nil
+			check.SetChecked(checked[thread.Key])
+			check.OnChanged = func(on bool) {
+				if on {
+					checked[thread.Key] = true
+				} else {
+					delete(checked, thread.Key)
+				}
+			}
+
+			top := box.Objects[0].(*fyne.Container)
+			top.Objects[0].(*widget.Label).SetText(a.displayName(thread.Number))
 
-				headerLabel := box.Objects[0].(*widget.Label)
-				headerLabel.SetText(fmt.Sprintf("From: %s", msg.Number))
+			unreadLabel := top.Objects[2].(*widget.Label)
+			if thread.Unread > 0 {
+				unreadLabel.SetText(fmt.Sprintf("%d unread", thread.Unread))
+			} else {
+				unreadLabel.SetText("")
+			}
 
-				dateLabel := box.Objects[1].(*widget.Label)
-				dateLabel.SetText(msg.Timestamp.Format("Mon, Jan 2, 2006 at 3:04 PM"))
+			box.Objects[1].(*widget.Label).SetText(latest.Timestamp.Format("Mon, Jan 2, 2006 at 3:04 PM"))
 
-				previewLabel := box.Objects[2].(*widget.Label)
-				preview := msg.Content
-				if len(preview) > 80 {
-					preview = preview[:80] + "..."
-				}
-				previewLabel.SetText(preview)
+			preview := latest.Content
+			if latest.IsSent() {
+				preview = "You: " + preview
 			}
+			box.Objects[2].(*widget.Label).SetText(preview)
 		},
 	)
 
-	smsList.OnSelected = func(id widget.ListItemID) {
-		if id < len(a.cachedSMSMessages) {
-			msg := a.cachedSMSMessages[id]
+	threadList.OnSelected = func(id widget.ListItemID) {
+		if id >= len(threads) {
+			return
+		}
+		thread := threads[id]
+		showThread(thread)
 
-			markdown := fmt.Sprintf("**From:** %s\n\n**Date:** %s\n\n**Message:**\n\n%s",
-				msg.Number,
-				msg.Timestamp.Format("Monday, January 2, 2006 at 3:04 PM"),
-				msg.Content,
-			)
+		// Opening a conversation reads it, as on a phone
+		if canMark && !reloading && !searching && thread.Unread > 0 {
+			a.markSMS(ctx, marker, unreadIDs(thread), true, reload)
+		}
+	}
+
+	// reload regroups the cached messages, or the archived ones matching the
+	// search, and keeps the open thread selected
+	reload = func() {
+		if searching {
+			entries := a.smsArchive.Search(query)
+			messages := make([]api.SMSMessage, len(entries))
+			deleted = make(map[string]bool)
+			for i, e := range entries {
+				messages[i] = e.Message
+				if e.Deleted() {
+					deleted[archivedKey(e.Message)] = true
+				}
+			}
+			threads = api.GroupThreads(messages)
+			totalLabel.SetText(fmt.Sprintf("%d archived messages found", len(entries)))
+		} else {
+			threads = api.GroupThreads(a.cachedSMSMessages)
+			deleted = make(map[string]bool)
+			totalLabel.SetText(fmt.Sprintf("%d conversations, %d messages", len(threads), len(a.cachedSMSMessages)))
+		}
 
-			messageDetail.ParseMarkdown(markdown)
-			detailScroll.ScrollToTop()
+		for _, action := range deviceActions {
+			if searching {
+				action.Disable()
+			} else {
+				action.Enable()
+			}
+		}
+
+		present := make(map[string]bool, len(threads))
+		for _, thread := range threads {
+			present[thread.Key] = true
 		}
+		for key := range checked {
+			if !present[key] {
+				delete(checked, key)
+			}
+		}
+		a.updateRecentSMSContent()
+		threadList.Refresh()
+
+		for i, thread := range threads {
+			if thread.Key == selected {
+				reloading = true
+				threadList.Select(i)
+				reloading = false
+				showThread(thread)
+				return
+			}
+		}
+		threadList.UnselectAll()
+		clearThread()
 	}
 
 	refreshBtn := widget.NewButton("Refresh", func() {
-		a.refreshSMSList(smsList)
-		messageDetail.ParseMarkdown("*Select a message to view its content*")
+		a.refreshSMSList(ctx, reload)
+		updateStorage()
+	})
+
+	composeBtn := widget.NewButtonWithIcon("New Message", theme.MailComposeIcon(), func() {
+		a.ShowComposeDialog("", "")
 	})
 
-	buttons := container.NewHBox(
-		refreshBtn,
-		layout.NewSpacer(),
-		widget.NewLabel(fmt.Sprintf("Total: %d messages", len(a.cachedSMSMessages))),
+	outboxBtn := widget.NewButtonWithIcon("Outbox", theme.MailSendIcon(), a.ShowOutboxDialog)
+	updateOutbox := func() {
+		if unsent := a.outbox.Unsent(); unsent > 0 {
+			outboxBtn.SetText(fmt.Sprintf("Outbox (%d)", unsent))
+		} else {
+			outboxBtn.SetText("Outbox")
+		}
+
+		// Show the state of messages queued for the open conversation
+		for _, thread := range threads {
+			if thread.Key == selected {
+				showThread(thread)
+			}
+		}
+	}
+	updateOutbox()
+
+	// checkedIDs collects every message ID in the checked conversations
+	checkedIDs := func() []string {
+		var ids []string
+		for _, thread := range threads {
+			if !checked[thread.Key] {
+				continue
+			}
+			for _, msg := range thread.Messages {
+				ids = append(ids, msg.AllIDs()...)
+			}
+		}
+		return ids
+	}
+
+	selectAll := widget.NewCheck("All", func(on bool) {
+		for _, thread := range threads {
+			if on {
+				checked[thread.Key] = true
+			} else {
+				delete(checked, thread.Key)
+			}
+		}
+		threadList.Refresh()
+	})
+
+	deleteBtn := widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {
+		ids := checkedIDs()
+		if len(ids) == 0 {
+			return
+		}
+
+		dialog.ShowConfirm("Delete Messages",
+			fmt.Sprintf("Delete %d message(s) in %d conversation(s) from the device?", len(ids), len(checked)),
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				a.deleteSMS(ctx, ids, func() {
+					checked = make(map[string]bool)
+					selectAll.SetChecked(false)
+					reload()
+					updateStorage()
+				})
+			},
+			a.MainWindow)
+	})
+	deleteBtn.Importance = widget.DangerImportance
+
+	markReadBtn := widget.NewButton("Mark Read", func() {
+		if ids := checkedIDs(); len(ids) > 0 {
+			a.markSMS(ctx, marker, ids, true, reload)
+		}
+	})
+	markUnreadBtn := widget.NewButton("Mark Unread", func() {
+		if ids := checkedIDs(); len(ids) > 0 {
+			a.markSMS(ctx, marker, ids, false, reload)
+		}
+	})
+	if !canMark {
+		markReadBtn.Hide()
+		markUnreadBtn.Hide()
+	}
+	deviceActions = []fyne.Disableable{deleteBtn, markReadBtn, markUnreadBtn}
+
+	// Export takes the checked conversations, else the open one, else
+	// everything listed, so a search narrows it to a date range
+	exportBtn := widget.NewButtonWithIcon("Export", theme.DocumentSaveIcon(), func() {
+		var messages []api.SMSMessage
+		scope := ""
+		switch {
+		case len(checked) > 0:
+			for _, thread := range threads {
+				if checked[thread.Key] {
+					messages = append(messages, thread.Messages...)
+				}
+			}
+			scope = fmt.Sprintf("%d conversation(s)", len(checked))
+		case selected != "":
+			for _, thread := range threads {
+				if thread.Key == selected {
+					messages = thread.Messages
+					scope = thread.Number
+				}
+			}
+		default:
+			for _, thread := range threads {
+				messages = append(messages, thread.Messages...)
+			}
+			scope = "all listed conversations"
+		}
+		a.ShowExportDialog(messages, scope)
+	})
+
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("Search archive: words, number:0999, from:2024-01-01, to:2024-01-31, is:deleted")
+	searchEntry.ActionItem = widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		searchEntry.SetText("")
+	})
+	searchEntry.OnChanged = func(text string) {
+		q, err := smsarchive.ParseQuery(text)
+		if err != nil {
+			// Wait for the filter to be finished
+			return
+		}
+		query = q
+		searching = !q.IsZero()
+		reload()
+	}
+	searchEntry.OnSubmitted = func(text string) {
+		if _, err := smsarchive.ParseQuery(text); err != nil {
+			dialog.ShowError(err, a.MainWindow)
+		}
+	}
+	if a.smsArchive == nil {
+		searchEntry.Hide()
+	}
+
+	buttons := container.NewVBox(
+		searchEntry,
+		container.NewHBox(
+			refreshBtn,
+			composeBtn,
+			replyBtn,
+			outboxBtn,
+			layout.NewSpacer(),
+			storageLabel,
+			totalLabel,
+		),
+		container.NewHBox(
+			selectAll,
+			deleteBtn,
+			markReadBtn,
+			markUnreadBtn,
+			layout.NewSpacer(),
+			exportBtn,
+		),
 	)
 
 	split := container.NewHSplit(
-		container.NewBorder(nil, nil, nil, nil, smsList),
-		container.NewBorder(nil, nil, nil, nil, detailScroll),
+		container.NewBorder(nil, nil, nil, nil, threadList),
+		container.NewBorder(threadTitle, nil, nil, nil, historyScroll),
 	)
-	split.Offset = 0.4 // 40% for list, 60% for detail
+	split.Offset = 0.35
 
 	content := container.NewBorder(
 		buttons,
@@ -99,50 +410,374 @@ func (a *App) ShowSMSDialog() {
 
 	smsDialog := dialog.NewCustom("SMS Messages", "Close", content, a.MainWindow)
 	smsDialog.Resize(fyne.NewSize(900, 600))
+	a.outboxListeners["sms-dialog"] = updateOutbox
+	smsDialog.SetOnClosed(func() {
+		cancel()
+		delete(a.outboxListeners, "sms-dialog")
+	})
 
-	a.refreshSMSList(smsList)
-
-	messageDetail.ParseMarkdown("*Select a message from the list to view its content*")
+	reload()
+	a.refreshSMSList(ctx, reload)
+	updateStorage()
 
 	smsDialog.Show()
 }
 
-func (a *App) refreshSMSList(list *widget.List) {
-	messages, err := a.APIClient.GetSMSList(0, 50)
+// archivedKey identifies a message among search results
+func archivedKey(msg api.SMSMessage) string {
+	return msg.Number + "|" + msg.Store.String() + "|" + msg.ID
+}
+
+// unreadIDs returns the IDs of the unread messages in thread
+func unreadIDs(thread api.Thread) []string {
+	var ids []string
+	for _, msg := range thread.Messages {
+		if msg.IsUnread() {
+			ids = append(ids, msg.AllIDs()...)
+		}
+	}
+	return ids
+}
+
+// markSMS changes the read state of messages in the background, then
+// refreshes the cached list and calls onDone
+func (a *App) markSMS(ctx context.Context, marker api.SMSReadMarker, ids []string, read bool, onDone func()) {
+	go func() {
+		err := marker.MarkSMSReadContext(ctx, ids, read)
+
+		fyne.Do(func() {
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				a.Logger.Errorf("Failed to mark SMS: %v", err)
+				a.showAPIError("Failed to Update Messages", err)
+				return
+			}
+			a.refreshSMSList(ctx, onDone)
+		})
+	}()
+}
+
+// deleteSMS deletes messages in the background, then refreshes the cached
+// list and calls onDone
+func (a *App) deleteSMS(ctx context.Context, ids []string, onDone func()) {
+	go func() {
+		err := a.APIClient.DeleteSMSContext(ctx, ids)
+
+		fyne.Do(func() {
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				a.Logger.Errorf("Failed to delete SMS: %v", err)
+				a.showAPIError("Failed to Delete Messages", err)
+				return
+			}
+			a.refreshSMSList(ctx, onDone)
+		})
+	}()
+}
+
+// messageBubble renders one message of a conversation. Sent messages are
+// indented from the left, received ones from the right.
+func messageBubble(msg api.SMSMessage, deleted bool) fyne.CanvasObject {
+	body := widget.NewLabel(msg.Content)
+	body.Wrapping = fyne.TextWrapWord
+
+	stamp := msg.Timestamp.Format("Jan 2, 15:04")
+	if msg.ConcatTotal > 1 {
+		stamp += fmt.Sprintf(" · %d parts", msg.ConcatTotal)
+	}
+	align := fyne.TextAlignLeading
+	bg := color.NRGBA{R: 60, G: 60, B: 72, A: 255}
+	if msg.IsSent() {
+		align = fyne.TextAlignTrailing
+		bg = color.NRGBA{R: 40, G: 90, B: 150, A: 255}
+	}
+	if msg.IsUnread() {
+		stamp += " · unread"
+	}
+	if deleted {
+		stamp += " · deleted from device"
+	}
+	date := widget.NewLabelWithStyle(stamp, align, fyne.TextStyle{Italic: true})
+
+	rect := canvas.NewRectangle(bg)
+	rect.CornerRadius = 8
+	bubble := container.NewStack(rect, container.NewPadded(container.NewVBox(body, date)))
+
+	indent := canvas.NewRectangle(color.Transparent)
+	indent.SetMinSize(fyne.NewSize(120, 0))
+
+	if msg.IsSent() {
+		return container.NewBorder(nil, nil, indent, nil, bubble)
+	}
+	return container.NewBorder(nil, nil, nil, indent, bubble)
+}
+
+// openSMSArchive opens the local message archive. The app runs without
+// archive and search if it cannot be read.
+func openSMSArchive(logger *logrus.Logger) *smsarchive.Archive {
+	path, err := smsarchive.DefaultPath()
 	if err != nil {
-		a.Logger.Errorf("Failed to fetch SMS list: %v", err)
-		dialog.ShowError(fmt.Errorf("Failed to load SMS messages: %v", err), a.MainWindow)
-		return
+		logger.Warnf("SMS archive disabled: %v", err)
+		return nil
+	}
+
+	archive, err := smsarchive.Open(path)
+	if err != nil {
+		logger.Warnf("SMS archive disabled: %v", err)
+		return nil
+	}
+	return archive
+}
+
+// archiveSMS records messages, which must be everything on the device
+// identified by imei, in the local archive. It fails when the archive is
+// disabled, the device is not yet identified or the archive cannot be saved.
+func (a *App) archiveSMS(imei string, messages []api.SMSMessage) error {
+	if a.smsArchive == nil {
+		return errors.New("the SMS archive is disabled")
+	}
+	if imei == "" {
+		return errors.New("the device IMEI is not known yet")
 	}
 
-	a.cachedSMSMessages = messages
-	list.Refresh()
+	added, changed := a.smsArchive.Record(imei, messages)
+	if added > 0 {
+		a.Logger.Debugf("Archived %d new message(s)", added)
+	}
+	if !changed {
+		return nil
+	}
+	if err := a.smsArchive.Save(); err != nil {
+		a.Logger.Errorf("Failed to save SMS archive: %v", err)
+		return err
+	}
+	return nil
 }
 
-func (a *App) checkForNewSMS() {
-	count, err := a.APIClient.GetSMSCount()
+// cleanupPolicy returns the configured storage cleanup policy
+func (a *App) cleanupPolicy() smssync.CleanupPolicy {
+	return smssync.CleanupPolicy{
+		Threshold: a.Config.SMS.CleanupThreshold,
+		Target:    a.Config.SMS.CleanupTarget,
+	}
+}
+
+// checkSMSStorage warns when message storage is nearly full, since the
+// device drops incoming messages once it is, and runs the cleanup policy if
+// it is enabled. messages must be everything on the device, and archived
+// reports whether they were all saved to the archive.
+func (a *App) checkSMSStorage(messages []api.SMSMessage, archived bool) {
+	reader, ok := a.APIClient.(api.SMSCapacityReader)
+	if !ok {
+		return
+	}
+
+	capacity, err := reader.GetSMSCapacityContext(a.ctx)
 	if err != nil {
-		a.Logger.Errorf("Failed to check SMS count: %v", err)
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, api.ErrUnsupported) {
+			a.Logger.Errorf("Failed to read SMS capacity: %v", err)
+		}
+		return
+	}
+
+	policy := a.cleanupPolicy()
+	full := policy.Full(capacity)
+	if len(full) == 0 {
+		a.smsStorageWarned = false
+		return
+	}
+
+	// Messages are only deleted once they are safely archived
+	var ids []string
+	if a.Config.SMS.CleanupEnabled && archived {
+		for _, m := range policy.Select(capacity, messages) {
+			ids = append(ids, m.AllIDs()...)
+		}
+	}
+
+	if len(ids) == 0 {
+		if !a.smsStorageWarned {
+			a.smsStorageWarned = true
+			store := full[0]
+			a.Logger.Warnf("SMS %s storage is %d%% full", store, capacity.Percent(store))
+			a.FyneApp.SendNotification(&fyne.Notification{
+				Title: "SMS Storage Almost Full",
+				Content: fmt.Sprintf("%s storage is %d%% full. New messages are lost once it is full; delete some or enable automatic cleanup.",
+					storeName(store), capacity.Percent(store)),
+			})
+		}
+		return
+	}
+
+	if err := a.APIClient.DeleteSMSContext(a.ctx, ids); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			a.Logger.Errorf("SMS cleanup failed: %v", err)
+		}
 		return
 	}
 
-	if a.lastSMSCount == 0 || count != a.lastSMSCount {
-		messages, err := a.APIClient.GetSMSList(0, 50)
+	a.Logger.Infof("SMS cleanup deleted %d old read message(s)", len(ids))
+	a.FyneApp.SendNotification(&fyne.Notification{
+		Title:   "SMS Storage Cleaned Up",
+		Content: fmt.Sprintf("Deleted %d old read message(s) to free storage. They remain in the local archive.", len(ids)),
+	})
+}
+
+// storeName names a message store for the user
+func storeName(store api.SMSStore) string {
+	if store == api.SMSStoreSIM {
+		return "SIM"
+	}
+	return "Device"
+}
+
+// capacityText summarises storage use, or returns "" when nothing is known
+func capacityText(capacity *api.SMSCapacity) string {
+	var parts []string
+	for _, store := range []api.SMSStore{api.SMSStoreDevice, api.SMSStoreSIM} {
+		if capacity.Total(store) > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d/%d", storeName(store), capacity.Used(store), capacity.Total(store)))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Storage: " + strings.Join(parts, " · ")
+}
+
+// loadSMSCapacity reads storage use in the background and calls onLoaded on
+// the UI thread. Backends that cannot report it are skipped.
+func (a *App) loadSMSCapacity(ctx context.Context, onLoaded func(*api.SMSCapacity)) {
+	reader, ok := a.APIClient.(api.SMSCapacityReader)
+	if !ok {
+		return
+	}
+
+	go func() {
+		capacity, err := reader.GetSMSCapacityContext(ctx)
 		if err != nil {
-			a.Logger.Errorf("Failed to fetch SMS list: %v", err)
-		} else {
-			a.cachedSMSMessages = messages
-			a.updateRecentSMSContent()
+			if !errors.Is(err, context.Canceled) {
+				a.Logger.Debugf("Failed to read SMS capacity: %v", err)
+			}
+			return
 		}
+		fyne.Do(func() {
+			onLoaded(capacity)
+		})
+	}()
+}
+
+// outboxBubble renders a message still in the outbox as a sent message with
+// its state
+func outboxBubble(item smsoutbox.Item) fyne.CanvasObject {
+	body := widget.NewLabel(item.Content)
+	body.Wrapping = fyne.TextWrapWord
+
+	state := widget.NewLabelWithStyle(item.Created.Format("Jan 2, 15:04")+" · "+outboxStateText(item),
+		fyne.TextAlignTrailing, fyne.TextStyle{Italic: true})
+	if item.State == smsoutbox.StateFailed {
+		state.Importance = widget.DangerImportance
 	}
 
-	if a.lastSMSCount > 0 && count > a.lastSMSCount {
-		newCount := count - a.lastSMSCount
-		a.FyneApp.SendNotification(&fyne.Notification{
-			Title:   "New SMS",
-			Content: fmt.Sprintf("You have %d new message(s)", newCount),
+	rect := canvas.NewRectangle(color.NRGBA{R: 40, G: 60, B: 90, A: 255})
+	rect.CornerRadius = 8
+	bubble := container.NewStack(rect, container.NewPadded(container.NewVBox(body, state)))
+
+	indent := canvas.NewRectangle(color.Transparent)
+	indent.SetMinSize(fyne.NewSize(120, 0))
+
+	return container.NewBorder(nil, nil, indent, nil, bubble)
+}
+
+// refreshSMSList fetches every message in the background and calls onLoaded
+// on the UI thread when it finishes. It leaves a.smsSync alone, so messages
+// that arrive meanwhile are still reported to the poller as new.
+func (a *App) refreshSMSList(ctx context.Context, onLoaded func()) {
+	imei := a.deviceIMEI
+
+	go func() {
+		messages, err := smssync.FetchAllContext(ctx, a.APIClient)
+		if err == nil {
+			if err := a.archiveSMS(imei, messages); err != nil {
+				a.Logger.Debugf("SMS not archived: %v", err)
+			}
+		}
+
+		fyne.Do(func() {
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				a.Logger.Errorf("Failed to sync SMS: %v", err)
+				a.showAPIError("Failed to Load SMS Messages", err)
+				return
+			}
+
+			a.cachedSMSMessages = messages
+			onLoaded()
 		})
+	}()
+}
+
+// checkForNewSMS syncs every message on the device identified by imei,
+// archives them, checks the storage and notifies about the ones received
+// since the last sync. It reports whether the sync succeeded.
+func (a *App) checkForNewSMS(imei string) bool {
+	result, err := a.smsSync.SyncContext(a.ctx)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			a.Logger.Errorf("Failed to sync SMS: %v", err)
+		}
+		return false
+	}
+	archiveErr := a.archiveSMS(imei, result.Messages)
+	if archiveErr != nil && a.Config.SMS.CleanupEnabled {
+		a.Logger.Debugf("SMS cleanup skipped, messages not archived: %v", archiveErr)
 	}
+	a.checkSMSStorage(result.Messages, archiveErr == nil)
 
-	a.lastSMSCount = count
+	if len(result.Added) > 0 || len(result.Removed) > 0 || len(result.Changed) > 0 {
+		a.Logger.Debugf("SMS sync: %d added, %d removed, %d changed",
+			len(result.Added), len(result.Removed), len(result.Changed))
+	}
+
+	fyne.Do(func() {
+		a.deviceIMEI = imei
+		a.cachedSMSMessages = result.Messages
+		a.updateRecentSMSContent()
+
+		if received := result.Received(); !result.Initial && len(received) > 0 {
+			a.FyneApp.SendNotification(a.receivedNotification(received))
+		}
+	})
+	return true
+}
+
+// receivedNotification announces newly received messages by sender name.
+// It must run on the UI thread, where the contact names are kept.
+func (a *App) receivedNotification(received []api.SMSMessage) *fyne.Notification {
+	if len(received) == 1 {
+		return &fyne.Notification{
+			Title:   "SMS from " + a.displayName(received[0].Number),
+			Content: received[0].Content,
+		}
+	}
+
+	var senders []string
+	seen := make(map[string]bool)
+	for _, m := range received {
+		name := a.displayName(m.Number)
+		if !seen[name] {
+			seen[name] = true
+			senders = append(senders, name)
+		}
+	}
+	return &fyne.Notification{
+		Title:   "New SMS",
+		Content: fmt.Sprintf("%d new messages from %s", len(received), strings.Join(senders, ", ")),
+	}
 }
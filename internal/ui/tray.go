@@ -28,7 +28,7 @@ func (a *AppIconResource) Content() []byte {
 	return a.data
 }
 
-// StartSystemTray sets up a simple system tray with Show and Quit menu items.
+// StartSystemTray sets up a simple system tray with Show, New Message and Quit menu items.
 // It blocks until systray.Quit is called. onReady is invoked once the tray is visible.
 func (a *App) StartSystemTray(onReady func()) {
 	systray.Run(func() {
@@ -40,6 +40,7 @@ func (a *App) StartSystemTray(onReady func()) {
 		systray.SetTooltip("MiFiMate")
 
 		mShow := systray.AddMenuItem("Show MiFiMate", "Show the main window")
+		mCompose := systray.AddMenuItem("New Message", "Write an SMS")
 		mQuit := systray.AddMenuItem("Quit", "Quit MiFiMate")
 
 		if onReady != nil {
@@ -53,6 +54,11 @@ func (a *App) StartSystemTray(onReady func()) {
 				case a.trayActions <- "show":
 				default:
 				}
+			case <-mCompose.ClickedCh:
+				select {
+				case a.trayActions <- "compose":
+				default:
+				}
 			case <-mQuit.ClickedCh:
 				select {
 				case a.trayActions <- "quit":
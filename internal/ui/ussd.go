@@ -0,0 +1,217 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"mifi_app/internal/api"
+)
+
+// ShowUSSDDialog runs USSD codes such as *100# and lets the user answer the
+// operator's menus. The session is cancelled when the dialog closes.
+func (a *App) ShowUSSDDialog() {
+	sender, ok := a.APIClient.(api.USSDSender)
+	if !ok {
+		dialog.ShowInformation("USSD", "This device does not support USSD codes.", a.MainWindow)
+		return
+	}
+
+	// Requests started from this dialog are abandoned when it closes
+	ctx, cancel := context.WithCancel(a.ctx)
+
+	transcript := container.NewVBox()
+	scroll := container.NewVScroll(transcript)
+	scroll.SetMinSize(fyne.NewSize(440, 260))
+
+	addLine := func(text string, mine bool) {
+		var line *widget.Label
+		if mine {
+			line = widget.NewLabelWithStyle("> "+text, fyne.TextAlignLeading, fyne.TextStyle{Bold: true, Monospace: true})
+		} else {
+			line = widget.NewLabelWithStyle(text, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+			line.Wrapping = fyne.TextWrapWord
+		}
+		transcript.Add(line)
+		scroll.ScrollToBottom()
+	}
+
+	codeEntry := widget.NewSelectEntry(a.ussdCodes)
+	codeEntry.SetPlaceHolder("*100#")
+	sendBtn := widget.NewButton("Send", nil)
+	sendBtn.Importance = widget.HighImportance
+
+	replyEntry := widget.NewEntry()
+	replyEntry.SetPlaceHolder("Reply")
+	replyBtn := widget.NewButton("Reply", nil)
+	endBtn := widget.NewButton("End Session", nil)
+
+	progress := widget.NewProgressBarInfinite()
+	progress.Hide()
+
+	// open is true while the network waits for a reply, busy while a
+	// request is running
+	open, busy := false, false
+	setBusy := func(b bool) {
+		busy = b
+		if busy {
+			progress.Show()
+			codeEntry.Disable()
+			sendBtn.Disable()
+			replyEntry.Disable()
+			replyBtn.Disable()
+			endBtn.Disable()
+			return
+		}
+
+		progress.Hide()
+		codeEntry.Enable()
+		sendBtn.Enable()
+		if open {
+			replyEntry.Enable()
+			replyBtn.Enable()
+			endBtn.Enable()
+		} else {
+			replyEntry.Disable()
+			replyBtn.Disable()
+			endBtn.Disable()
+		}
+	}
+	setBusy(false)
+
+	// run sends one request in the background and shows the answer
+	run := func(request func() (*api.USSDResponse, error)) {
+		setBusy(true)
+		go func() {
+			answer, err := request()
+			fyne.Do(func() {
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+
+				open = false
+				switch {
+				case errors.Is(err, api.ErrUSSDNoService):
+					addLine("No network service. Check the signal and try again.", false)
+				case err != nil:
+					a.Logger.Errorf("USSD request failed: %v", err)
+					addLine("Error: "+err.Error(), false)
+				default:
+					open = answer.ExpectsReply()
+					addLine(answer.Text, false)
+					if answer.Action == api.USSDTerminated {
+						addLine("(session ended by the network)", false)
+					}
+				}
+				setBusy(false)
+				if open {
+					replyEntry.SetText("")
+					a.MainWindow.Canvas().Focus(replyEntry)
+				}
+			})
+		}()
+	}
+
+	send := func() {
+		code := strings.TrimSpace(codeEntry.Text)
+		if !api.ValidUSSDCode(code) {
+			dialog.ShowError(errors.New("enter a USSD code such as *100#"), a.MainWindow)
+			return
+		}
+
+		// Remember the code for this session, most recent first
+		a.ussdCodes = slices.DeleteFunc(a.ussdCodes, func(c string) bool { return c == code })
+		a.ussdCodes = append([]string{code}, a.ussdCodes...)
+		codeEntry.SetOptions(a.ussdCodes)
+
+		addLine(code, true)
+		run(func() (*api.USSDResponse, error) {
+			return sender.SendUSSDContext(ctx, code)
+		})
+	}
+	reply := func() {
+		text := strings.TrimSpace(replyEntry.Text)
+		if text == "" || !open {
+			return
+		}
+
+		addLine(text, true)
+		run(func() (*api.USSDResponse, error) {
+			return sender.ReplyUSSDContext(ctx, text)
+		})
+	}
+
+	sendBtn.OnTapped = send
+	codeEntry.OnSubmitted = func(string) { send() }
+	replyBtn.OnTapped = reply
+	replyEntry.OnSubmitted = func(string) { reply() }
+	endBtn.OnTapped = func() {
+		setBusy(true)
+		go func() {
+			err := sender.CancelUSSDContext(ctx)
+			fyne.Do(func() {
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				if err != nil {
+					a.Logger.Warnf("Failed to end USSD session: %v", err)
+				}
+				open = false
+				addLine("(session ended)", false)
+				setBusy(false)
+			})
+		}()
+	}
+
+	hint := widget.NewLabelWithStyle("Dial an operator code to check your balance or buy bundles. Menus are answered with the option number.",
+		fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	hint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewBorder(
+		container.NewVBox(
+			hint,
+			container.NewBorder(nil, nil, nil, sendBtn, codeEntry),
+		),
+		container.NewVBox(
+			progress,
+			container.NewBorder(nil, nil, nil, container.NewHBox(replyBtn, endBtn), replyEntry),
+		),
+		nil,
+		nil,
+		scroll,
+	)
+
+	ussdDialog := dialog.NewCustom("USSD", "Close", content, a.MainWindow)
+	ussdDialog.Resize(fyne.NewSize(520, 480))
+	ussdDialog.SetOnClosed(func() {
+		cancel()
+		a.setUSSDDialogOpen(false)
+		if open || busy {
+			// Free the session on the device, which otherwise waits for
+			// the network to time it out
+			go func() {
+				if err := sender.CancelUSSDContext(a.ctx); err != nil {
+					a.Logger.Warnf("Failed to end USSD session: %v", err)
+				}
+			}()
+		}
+	})
+	a.setUSSDDialogOpen(true)
+	ussdDialog.Show()
+	a.MainWindow.Canvas().Focus(codeEntry)
+}
+
+// setUSSDDialogOpen records whether the USSD dialog is shown. Scheduled
+// balance checks are skipped meanwhile so they do not end or answer the
+// user's session; they run at the next poll after the dialog closes.
+func (a *App) setUSSDDialogOpen(open bool) {
+	a.balanceMu.Lock()
+	a.ussdDialogOpen = open
+	a.balanceMu.Unlock()
+}
@@ -12,10 +12,10 @@ import (
 )
 
 func (a *App) ShowWiFiSettingsDialog() {
-	currentConfig, err := a.APIClient.GetWiFiConfig()
+	currentConfig, err := a.APIClient.GetWiFiConfigContext(a.ctx)
 	if err != nil {
 		a.Logger.Errorf("Failed to get WiFi config: %v", err)
-		dialog.ShowError(err, a.MainWindow)
+		a.showAPIError("Failed to Load WiFi Settings", err)
 		return
 	}
 
@@ -108,9 +108,9 @@ func (a *App) saveWiFiSettings(ssid, password, security string, hideSSID bool, c
 		}
 	}
 
-	if err := a.APIClient.SetWiFiConfig(config); err != nil {
+	if err := a.APIClient.SetWiFiConfigContext(a.ctx, config); err != nil {
 		a.Logger.Errorf("Failed to update WiFi config: %v", err)
-		dialog.ShowError(err, a.MainWindow)
+		a.showAPIError("Failed to Update WiFi Settings", err)
 		return
 	}
 
@@ -1,16 +1,36 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
 	"mifi_app/internal/api"
 	"mifi_app/internal/config"
+	"mifi_app/internal/mifisim"
+	"mifi_app/internal/smsarchive"
+	"mifi_app/internal/smsexport"
+	"mifi_app/internal/smssync"
 	"mifi_app/internal/ui"
 	"mifi_app/internal/utils"
 
 	"fyne.io/fyne/v2/app"
 	"fyne.io/systray"
+	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	simulate := flag.Bool("simulate", false, "run against a local simulated ZTE device, or an emulated modem with the at backend")
+	record := flag.String("record", "", "record device exchanges to a redacted JSON lines fixture file")
+	replay := flag.String("replay", "", "replay device exchanges from a fixture file instead of the network")
+	exportPath := flag.String("export-sms", "", "export messages to a .csv, .json or .xml (SMS Backup & Restore) file and exit")
+	exportFormat := flag.String("export-format", "", "export format: csv, json or xml; defaults to the file extension")
+	exportQuery := flag.String("export-query", "", `select messages to export, e.g. "number:0999 from:2024-01-01 to:2024-01-31"`)
+	flag.Parse()
+
 	cfg, err := config.Load()
 	if err != nil {
 		panic("Failed to load configuration: " + err.Error())
@@ -19,10 +39,84 @@ func main() {
 	logger := utils.InitLogger(cfg.App.LogLevel)
 	logger.Info("Starting MiFiMate")
 
-	apiClient := api.NewClient(
-		"http://"+cfg.Device.DefaultIP,
-		logger,
-	)
+	baseURL := "http://" + cfg.Device.DefaultIP
+	serialPort := cfg.Device.SerialPort
+	backend := cfg.Device.Backend
+
+	switch {
+	case *simulate && backend == api.BackendAT:
+		modem := mifisim.NewATModem(nil)
+		var closer io.Closer
+		serialPort, closer, err = modem.ServePTY()
+		if err != nil {
+			panic("Failed to start modem emulator: " + err.Error())
+		}
+		defer closer.Close()
+		logger.Infof("Using simulated modem at %s", serialPort)
+	case *simulate:
+		sim := mifisim.New(mifisim.Options{Password: cfg.Device.Password})
+		baseURL, err = sim.Start("127.0.0.1:0")
+		if err != nil {
+			panic("Failed to start device simulator: " + err.Error())
+		}
+		defer sim.Close()
+		backend = api.BackendZTE
+		logger.Infof("Using simulated device at %s", baseURL)
+	case *replay != "" && (backend == "" || backend == api.BackendAuto):
+		backend = api.BackendZTE
+	case backend == "" || backend == api.BackendAuto:
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Device.ConnectionTimeout)*time.Second)
+		backend, err = api.DetectBackend(ctx, baseURL, nil)
+		cancel()
+		if err != nil {
+			logger.Warnf("Backend detection failed, assuming ZTE: %v", err)
+		}
+	}
+
+	var apiClient api.DeviceAPI
+	var httpClient *http.Client
+	switch backend {
+	case api.BackendHiLink:
+		client := api.NewHiLinkClient(baseURL, logger)
+		apiClient, httpClient = client, client.HTTPClient
+	case api.BackendAT:
+		client, err := api.OpenATClient(serialPort, logger)
+		if err != nil {
+			panic("Failed to open modem: " + err.Error())
+		}
+		defer client.Close()
+		apiClient = client
+	default:
+		client := api.NewClient(baseURL, logger)
+		apiClient, httpClient = client, client.HTTPClient
+	}
+	logger.Infof("Using %s backend", backend)
+	apiClient.SetTimeout(time.Duration(cfg.Device.ConnectionTimeout) * time.Second)
+
+	switch {
+	case httpClient == nil && (*replay != "" || *record != ""):
+		logger.Warnf("Recording and replay are not available for the %s backend", backend)
+	case *replay != "":
+		fixture, err := api.LoadFixture(*replay)
+		if err != nil {
+			panic("Failed to load fixture: " + err.Error())
+		}
+		httpClient.Transport = api.NewReplayTransport(fixture)
+		logger.Infof("Replaying device session from %s", *replay)
+	case *record != "":
+		if backend != api.BackendZTE {
+			logger.Warnf("Only goform exchanges are recorded; the %s backend will not be captured", backend)
+		}
+		httpClient.Transport = api.NewRecordingTransport(httpClient.Transport, *record)
+		logger.Infof("Recording device session to %s", *record)
+	}
+
+	if *exportPath != "" {
+		if err := exportSMS(apiClient, cfg, logger, *exportPath, *exportFormat, *exportQuery); err != nil {
+			logger.Fatalf("SMS export failed: %v", err)
+		}
+		return
+	}
 
 	fyneApp := app.New()
 	fyneApp.SetIcon(ui.GetAppIcon())
@@ -50,3 +144,76 @@ func main() {
 	logger.Info("Application closed")
 	systray.Quit()
 }
+
+// exportSMS writes the messages matching query to path. Messages are synced
+// from the device into the archive first when it is reachable, so the export
+// also covers history already deleted from the device.
+func exportSMS(client api.DeviceAPI, cfg *config.Config, logger *logrus.Logger, path, format, query string) error {
+	var f smsexport.Format
+	var err error
+	if format != "" {
+		f, err = smsexport.ParseFormat(format)
+	} else {
+		f, err = smsexport.FormatForPath(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	q, err := smsarchive.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var onDevice []api.SMSMessage
+	imei := ""
+	if err := client.LoginContext(ctx, cfg.Device.Username, cfg.Device.Password); err != nil {
+		logger.Warnf("Cannot log in to the device, exporting archived messages only: %v", err)
+	} else {
+		if onDevice, err = smssync.FetchAllContext(ctx, client); err != nil {
+			return fmt.Errorf("failed to read messages from the device: %w", err)
+		}
+		if status, err := client.GetDeviceStatusContext(ctx); err == nil {
+			imei = status.IMEI
+		}
+	}
+
+	var messages []api.SMSMessage
+	archivePath, err := smsarchive.DefaultPath()
+	if err != nil {
+		return err
+	}
+	archive, err := smsarchive.Open(archivePath)
+	if err != nil {
+		logger.Warnf("Exporting device messages only: %v", err)
+		for _, m := range onDevice {
+			if q.Matches(&smsarchive.Entry{Message: m}) {
+				messages = append(messages, m)
+			}
+		}
+	} else {
+		// Without the IMEI the device messages cannot be recorded, so they
+		// are exported alongside the archive instead
+		unrecorded := onDevice
+		if imei != "" {
+			if _, changed := archive.Record(imei, onDevice); changed {
+				if err := archive.Save(); err != nil {
+					logger.Warnf("Failed to save SMS archive: %v", err)
+				}
+			}
+			unrecorded = nil
+		}
+		for _, e := range archive.SearchWith(q, unrecorded) {
+			messages = append(messages, e.Message)
+		}
+	}
+
+	messages = api.StitchConcat(messages)
+	if err := smsexport.WriteFile(path, f, messages); err != nil {
+		return err
+	}
+
+	logger.Infof("Exported %d message(s) to %s as %s", len(messages), path, f.Description())
+	return nil
+}